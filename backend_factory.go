@@ -3,6 +3,7 @@ package krakend
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	amqp "github.com/krakend/krakend-amqp/v2"
 	cel "github.com/krakend/krakend-cel/v2"
@@ -22,6 +23,8 @@ import (
 	"github.com/luraproject/lura/v2/proxy"
 	"github.com/luraproject/lura/v2/transport/http/client"
 	httprequestexecutor "github.com/luraproject/lura/v2/transport/http/client/plugin"
+
+	"github.com/krakend/krakend-ce/v2/httpclient"
 )
 
 // NewBackendFactory creates a BackendFactory by stacking all the available middlewares:
@@ -43,8 +46,24 @@ func NewBackendFactory(logger logging.Logger, metricCollector *metrics.Metrics)
 func newRequestExecutorFactory(ctx context.Context, logger logging.Logger) func(*config.Backend) client.HTTPRequestExecutor {
 	requestExecutorFactory := func(cfg *config.Backend) client.HTTPRequestExecutor {
 		clientFactory := client.NewHTTPClient
+		cf, err := httpclient.NewHTTPClientFactory(ctx, cfg, logger)
+		switch err {
+		case nil:
+			clientFactory = cf
+		case httpclient.ErrNoConfig:
+		default:
+			// connecting without the configured certificates or CA pinning is not an option
+			logger.Error(fmt.Sprintf("[BACKEND: %s][HTTP Client] Unable to create the client: %s", cfg.URLPattern, err.Error()))
+			return func(context.Context, *http.Request) (*http.Response, error) {
+				return nil, err
+			}
+		}
 		if _, ok := cfg.ExtraConfig[oauth2client.Namespace]; ok {
-			clientFactory = oauth2client.NewHTTPClient(cfg)
+			if cf != nil {
+				clientFactory = httpclient.NewOAuth2ClientFactory(ctx, oauth2client.NewHTTPClient(cfg), cf)
+			} else {
+				clientFactory = oauth2client.NewHTTPClient(cfg)
+			}
 		}
 
 		clientFactory = httpcache.NewHTTPClient(cfg, clientFactory)
@@ -0,0 +1,70 @@
+// Package certloader loads TLS certificates from disk and keeps them updated when their
+// files are rotated.
+package certloader
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"sync/atomic"
+
+	"github.com/luraproject/lura/v2/logging"
+
+	"github.com/krakend/krakend-ce/v2/filewatch"
+)
+
+// Keypair is a certificate and its private key, reloaded every time their files change
+type Keypair struct {
+	CertFile string
+	KeyFile  string
+
+	cert atomic.Pointer[tls.Certificate]
+}
+
+// NewKeypair loads the certificate and starts watching its files until the context is cancelled.
+// If a reload fails, the previous certificate is kept.
+func NewKeypair(ctx context.Context, l logging.Logger, certFile, keyFile string) (*Keypair, error) {
+	k := &Keypair{CertFile: certFile, KeyFile: keyFile}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+
+	err := filewatch.Watch(ctx, l, []string{certFile, keyFile}, func() {
+		if err := k.Reload(); err != nil {
+			l.Error("[SERVICE: Certificates] Unable to reload the certificate", certFile, err.Error())
+			return
+		}
+		l.Info("[SERVICE: Certificates] Certificate reloaded:", certFile)
+	})
+	return k, err
+}
+
+// Reload loads the certificate from its files
+func (k *Keypair) Reload() error {
+	cert, err := tls.LoadX509KeyPair(k.CertFile, k.KeyFile)
+	if err != nil {
+		return err
+	}
+	if cert.Leaf == nil && len(cert.Certificate) > 0 {
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+			cert.Leaf = leaf
+		}
+	}
+	k.cert.Store(&cert)
+	return nil
+}
+
+// Certificate returns the last loaded certificate
+func (k *Keypair) Certificate() *tls.Certificate {
+	return k.cert.Load()
+}
+
+// GetCertificate implements the signature of the tls.Config GetCertificate callback
+func (k *Keypair) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return k.Certificate(), nil
+}
+
+// GetClientCertificate implements the signature of the tls.Config GetClientCertificate callback
+func (k *Keypair) GetClientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return k.Certificate(), nil
+}
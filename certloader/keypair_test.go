@@ -0,0 +1,77 @@
+package certloader
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewKeypair_reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	writeSelfSigned(t, certFile, keyFile, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k, err := NewKeypair(ctx, logging.NoOp, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial := k.Certificate().Leaf.SerialNumber.Int64(); serial != 1 {
+		t.Errorf("unexpected serial: %d", serial)
+	}
+
+	writeSelfSigned(t, certFile, keyFile, 2)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for k.Certificate().Leaf.SerialNumber.Int64() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the certificate was not reloaded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func writeSelfSigned(t *testing.T, certFile, keyFile string, serial int64, names ...string) {
+	if len(names) == 0 {
+		names = []string{"localhost"}
+	}
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// write the key first, so the reload triggered by the certificate finds a matching key
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,249 @@
+package certloader
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	gometrics "github.com/rcrowley/go-metrics"
+
+	"github.com/krakend/krakend-ce/v2/filewatch"
+	"github.com/krakend/krakend-ce/v2/listener"
+)
+
+// Namespace is the key used to store the server certificates config at the service ExtraConfig
+const Namespace = "server/tls"
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+var errNoCertificates = errors.New("no certificates available")
+
+// ServerConfig defines how the server certificates are loaded
+type ServerConfig struct {
+	// CertsDir is a directory with extra certificates, selected by the SNI of the clients. Every
+	// certificate (<name>.crt or <name>.pem) requires its private key in the same dir (<name>.key)
+	CertsDir string `json:"certs_dir"`
+	// DisableReload stops the watching of the certificate files
+	DisableReload bool `json:"disable_reload"`
+}
+
+// ParseServerConfig extracts the module config from the ExtraConfig
+func ParseServerConfig(cfg config.ExtraConfig) (ServerConfig, error) {
+	res := ServerConfig{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	err = json.Unmarshal(b, &res)
+	return res, err
+}
+
+// TLSModifier returns a listener.TLSModifier replacing the static server certificates with a store
+// that reloads them when rotated and selects them by SNI. The expiration date (unix time) of every
+// certificate is exported as the gauge tls.certificate.<name>.expiry at the received registry.
+func TLSModifier(l logging.Logger, registry gometrics.Registry) listener.TLSModifier {
+	return func(ctx context.Context, cfg config.ServiceConfig, tlsCfg *tls.Config) error {
+		sCfg, err := ParseServerConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			for _, c := range tlsCfg.Certificates {
+				registerExpiry(registry, certName(&c), leaf(&c))
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		s, err := NewStore(ctx, l, registry, cfg.TLS, sCfg)
+		if err != nil {
+			return err
+		}
+
+		tlsCfg.Certificates = nil
+		tlsCfg.GetCertificate = s.GetCertificate
+		return nil
+	}
+}
+
+// Store keeps the server certificates, selecting the one to use by the SNI of the client
+type Store struct {
+	keys     []*Keypair
+	dir      string
+	dirCerts atomic.Pointer[[]*tls.Certificate]
+	registry gometrics.Registry
+	mu       sync.Mutex
+	names    []string
+}
+
+// NewStore loads the certificates from the lura TLS config and from the certs dir
+func NewStore(ctx context.Context, l logging.Logger, registry gometrics.Registry, tlsCfg *config.TLS, cfg ServerConfig) (*Store, error) {
+	s := &Store{dir: cfg.CertsDir, registry: registry}
+
+	var pairs []config.TLSKeyPair
+	if tlsCfg != nil {
+		if tlsCfg.PublicKey != "" && tlsCfg.PrivateKey != "" {
+			pairs = append(pairs, config.TLSKeyPair{PublicKey: tlsCfg.PublicKey, PrivateKey: tlsCfg.PrivateKey})
+		}
+		pairs = append(pairs, tlsCfg.Keys...)
+	}
+
+	for _, p := range pairs {
+		var k *Keypair
+		var err error
+		if cfg.DisableReload {
+			k = &Keypair{CertFile: p.PublicKey, KeyFile: p.PrivateKey}
+			err = k.Reload()
+		} else {
+			k, err = NewKeypair(ctx, l, p.PublicKey, p.PrivateKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.keys = append(s.keys, k)
+		registerExpiry(registry, strings.TrimSuffix(filepath.Base(p.PublicKey), filepath.Ext(p.PublicKey)), func() *x509.Certificate {
+			return k.Certificate().Leaf
+		})
+	}
+
+	if s.dir == "" {
+		return s, nil
+	}
+
+	if err := s.loadDir(); err != nil {
+		return nil, err
+	}
+	if cfg.DisableReload {
+		return s, nil
+	}
+
+	err := filewatch.Watch(ctx, l, []string{s.dir}, func() {
+		if err := s.loadDir(); err != nil {
+			l.Error("[SERVICE: Certificates] Unable to reload the certificates from", s.dir, err.Error())
+			return
+		}
+		l.Info("[SERVICE: Certificates] Certificates reloaded from", s.dir)
+	})
+	return s, err
+}
+
+// Certificates returns all the available certificates, starting with the ones from the certs dir
+func (s *Store) Certificates() []*tls.Certificate {
+	var res []*tls.Certificate
+	if dc := s.dirCerts.Load(); dc != nil {
+		res = append(res, *dc...)
+	}
+	for _, k := range s.keys {
+		res = append(res, k.Certificate())
+	}
+	return res
+}
+
+// GetCertificate returns the first certificate supported by the client. If none of them matches,
+// the first certificate from the lura TLS config is used as the default one.
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certs := s.Certificates()
+	for _, c := range certs {
+		if hello.SupportsCertificate(c) == nil {
+			return c, nil
+		}
+	}
+	if len(s.keys) > 0 {
+		return s.keys[0].Certificate(), nil
+	}
+	if len(certs) > 0 {
+		return certs[0], nil
+	}
+	return nil, errNoCertificates
+}
+
+func (s *Store) loadDir() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	var certs []*tls.Certificate
+	var names []string
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".crt" && ext != ".pem") {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), ext)
+		keyFile := filepath.Join(s.dir, name+".key")
+		if _, err := os.Stat(keyFile); err != nil {
+			continue
+		}
+		k := &Keypair{CertFile: filepath.Join(s.dir, e.Name()), KeyFile: keyFile}
+		if err := k.Reload(); err != nil {
+			return err
+		}
+		certs = append(certs, k.Certificate())
+		names = append(names, name)
+	}
+
+	s.dirCerts.Store(&certs)
+
+	s.mu.Lock()
+	for _, n := range s.names {
+		s.registry.Unregister(expiryMetric(n))
+	}
+	for i, c := range certs {
+		registerExpiry(s.registry, names[i], leaf(c))
+	}
+	s.names = names
+	s.mu.Unlock()
+
+	return nil
+}
+
+func registerExpiry(registry gometrics.Registry, name string, f func() *x509.Certificate) {
+	if registry == nil {
+		return
+	}
+	registry.Unregister(expiryMetric(name))
+	registry.Register(expiryMetric(name), gometrics.NewFunctionalGauge(func() int64 {
+		if c := f(); c != nil {
+			return c.NotAfter.Unix()
+		}
+		return 0
+	}))
+}
+
+func expiryMetric(name string) string {
+	return "tls.certificate." + name + ".expiry"
+}
+
+func leaf(c *tls.Certificate) func() *x509.Certificate {
+	return func() *x509.Certificate {
+		if c.Leaf != nil {
+			return c.Leaf
+		}
+		if len(c.Certificate) == 0 {
+			return nil
+		}
+		l, _ := x509.ParseCertificate(c.Certificate[0])
+		return l
+	}
+}
+
+func certName(c *tls.Certificate) string {
+	if l := leaf(c)(); l != nil && l.Subject.CommonName != "" {
+		return l.Subject.CommonName
+	}
+	return "default"
+}
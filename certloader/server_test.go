@@ -0,0 +1,67 @@
+package certloader
+
+import (
+	"context"
+	"crypto/tls"
+	"path/filepath"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+func TestStore_GetCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certsDir := t.TempDir()
+
+	writeSelfSigned(t, filepath.Join(dir, "default.pem"), filepath.Join(dir, "default.key"), 1, "default.example.com")
+	writeSelfSigned(t, filepath.Join(certsDir, "a.crt"), filepath.Join(certsDir, "a.key"), 2, "a.example.com")
+	writeSelfSigned(t, filepath.Join(certsDir, "b.pem"), filepath.Join(certsDir, "b.key"), 3, "*.b.example.com")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := gometrics.NewRegistry()
+	s, err := NewStore(
+		ctx,
+		logging.NoOp,
+		registry,
+		&config.TLS{PublicKey: filepath.Join(dir, "default.pem"), PrivateKey: filepath.Join(dir, "default.key")},
+		ServerConfig{CertsDir: certsDir},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for serverName, serial := range map[string]int64{
+		"a.example.com":       2,
+		"x.b.example.com":     3,
+		"default.example.com": 1,
+		"unknown.example.com": 1,
+	} {
+		c, err := s.GetCertificate(&tls.ClientHelloInfo{
+			ServerName:        serverName,
+			SupportedVersions: []uint16{tls.VersionTLS13},
+			SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+		})
+		if err != nil {
+			t.Error(serverName, err)
+			continue
+		}
+		if got := c.Leaf.SerialNumber.Int64(); got != serial {
+			t.Errorf("%s: unexpected certificate %d", serverName, got)
+		}
+	}
+
+	for _, name := range []string{"default", "a", "b"} {
+		g, ok := registry.Get(expiryMetric(name)).(gometrics.Gauge)
+		if !ok {
+			t.Errorf("gauge for %s not registered", name)
+			continue
+		}
+		if g.Value() <= 0 {
+			t.Errorf("unexpected expiry for %s: %d", name, g.Value())
+		}
+	}
+}
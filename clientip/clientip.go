@@ -0,0 +1,125 @@
+// Package clientip resolves the IP of the clients behind a chain of trusted proxies and parses the
+// lists of IPs and CIDRs used by the security modules.
+package clientip
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+var (
+	errNoHeader       = errors.New("the trusted_proxies require a client_ip_header")
+	errNoTrustedProxy = errors.New("the client_ip_header requires the trusted_proxies")
+)
+
+// Config defines the proxies allowed to declare the IP of the client. Without them, the client IP
+// is the remote address of the connection.
+type Config struct {
+	// TrustedProxies is the list of IPs or CIDRs allowed to declare the client IP with the
+	// ClientIPHeader
+	TrustedProxies []string `json:"trusted_proxies"`
+	// ClientIPHeader is the header set by the trusted proxies, like X-Forwarded-For. There is no
+	// default, so the headers added by the clients themselves are never used by mistake.
+	ClientIPHeader string `json:"client_ip_header"`
+}
+
+// Resolver returns the IP of the clients
+type Resolver struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+// New creates a resolver with the received config
+func New(cfg Config) (*Resolver, error) {
+	trusted, err := ParsePrefixes(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	if len(trusted) > 0 && cfg.ClientIPHeader == "" {
+		return nil, errNoHeader
+	}
+	if len(trusted) == 0 && cfg.ClientIPHeader != "" {
+		return nil, errNoTrustedProxy
+	}
+	return &Resolver{trusted: trusted, header: cfg.ClientIPHeader}, nil
+}
+
+// ClientIP returns the IP of the client. The client IP header is only considered when the request
+// comes from a trusted proxy, and it is processed from right to left, skipping the trusted
+// proxies, so the values added by the client itself are never used. The returned IP is not valid
+// when the header has a value that is not an IP, so it can not match any rule.
+func (r *Resolver) ClientIP(req *http.Request) netip.Addr {
+	remote := remoteAddr(req.RemoteAddr)
+	if !remote.IsValid() || !Contains(r.trusted, remote) {
+		return remote
+	}
+
+	var hops []string
+	for _, v := range req.Header.Values(r.header) {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+
+	ip := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		var err error
+		if ip, err = netip.ParseAddr(strings.TrimSpace(hops[i])); err != nil {
+			return netip.Addr{}
+		}
+		ip = ip.Unmap()
+		if !Contains(r.trusted, ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+// FromUnixSocket reports if the request was received at a unix socket, where the connections have
+// no remote IP
+func FromUnixSocket(req *http.Request) bool {
+	addr, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
+
+// ParsePrefixes parses a list of IPs and CIDRs. The IPs are returned as single address prefixes.
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	res := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if strings.Contains(v, "/") {
+			p, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, p.Masked())
+			continue
+		}
+		ip, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, err
+		}
+		ip = ip.Unmap()
+		res = append(res, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return res, nil
+}
+
+// Contains reports if any of the prefixes contains the IP
+func Contains(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteAddr(addr string) netip.Addr {
+	if ap, err := netip.ParseAddrPort(addr); err == nil {
+		return ap.Addr().Unmap()
+	}
+	ip, _ := netip.ParseAddr(addr)
+	return ip.Unmap()
+}
@@ -0,0 +1,57 @@
+package clientip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestResolver_ClientIP(t *testing.T) {
+	r, err := New(Config{
+		TrustedProxies: []string{"10.0.0.0/8"},
+		ClientIPHeader: "X-Forwarded-For",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{remote: "1.2.3.4:1234", xff: []string{"5.6.7.8"}, want: "1.2.3.4"},
+		{remote: "10.0.0.1:1234", want: "10.0.0.1"},
+		{remote: "10.0.0.1:1234", xff: []string{"5.6.7.8"}, want: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", xff: []string{"9.9.9.9, 5.6.7.8, 10.0.0.2"}, want: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", xff: []string{"9.9.9.9", "5.6.7.8"}, want: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", xff: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{remote: "[::ffff:10.0.0.1]:1234", xff: []string{"5.6.7.8"}, want: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", xff: []string{"garbage, 10.0.0.2"}, want: "invalid IP"},
+		{remote: "10.0.0.1:1234", realIP: "9.9.9.9", want: "10.0.0.1"},
+	} {
+		req, _ := http.NewRequest("GET", "/", http.NoBody)
+		req.RemoteAddr = tc.remote
+		for _, v := range tc.xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		if tc.realIP != "" {
+			req.Header.Set("X-Real-Ip", tc.realIP)
+		}
+		if got := r.ClientIP(req).String(); got != tc.want {
+			t.Errorf("%s %v: got %s, want %s", tc.remote, tc.xff, got, tc.want)
+		}
+	}
+}
+
+func TestNew_wrongConfig(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"no header":        {TrustedProxies: []string{"10.0.0.0/8"}},
+		"no trusted proxy": {ClientIPHeader: "X-Forwarded-For"},
+		"wrong CIDR":       {TrustedProxies: []string{"10.0.0.0/33"}, ClientIPHeader: "X-Forwarded-For"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s: expecting an error", name)
+		}
+	}
+}
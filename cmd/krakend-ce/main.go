@@ -74,6 +74,10 @@ func main() {
 		cmd.VersionCommand,
 		cmd.AuditCommand,
 		krakend.NewTestPluginCmd(),
+		krakend.NewImportOpenAPICmd(),
+		krakend.NewExportOpenAPICmd(cfg),
+		krakend.NewTestPolicyCmd(cfg),
+		krakend.NewLintCmd(cfg),
 	}
 
 	cmd.DefaultRoot = cmd.NewRoot(cmd.RootCommand, commandsToLoad...)
@@ -0,0 +1,54 @@
+// Package errorbody replaces the body of the error responses produced by the gateway with the ones
+// defined at the router config, globally or per endpoint, as static json values, Go templates or
+// RFC 7807 problem details documents.
+package errorbody
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/luraproject/lura/v2/config"
+	router "github.com/luraproject/lura/v2/router/gin"
+)
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// Config is the part of the router config (service or endpoint level) defining the error bodies.
+// The keys of the maps are status codes.
+type Config struct {
+	// Bodies are json values returned as they are. When the ProblemDetails are enabled, the
+	// objects are used as templates of the problem details documents.
+	// Example: "404": { "error": "Not Found", "status": 404 }
+	Bodies map[string]interface{} `json:"error_body"`
+	// Templates are Go templates with access to .Status, .StatusText, .Method, .Path, .RequestID
+	// and .Detail. They take precedence over the Bodies. The values sent by the clients (like the
+	// path) must be encoded with the json function to be embedded in a json body.
+	// Example: "429": "{\"error\": \"slow down\", \"path\": {{json .Path}}}"
+	Templates map[string]string `json:"error_template"`
+	// ContentType of the rendered templates. Default: application/json
+	ContentType string `json:"error_content_type"`
+	// ProblemDetails renders every error response without a body or with a plain text or json one
+	// as an RFC 7807 application/problem+json document. Only available at the service level.
+	ProblemDetails bool `json:"problem_details"`
+}
+
+// ParseConfig extracts the error bodies from the router config
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[router.Namespace]
+	if !ok || e == nil {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if len(res.Bodies)+len(res.Templates) == 0 && !res.ProblemDetails {
+		return res, ErrNoConfig
+	}
+	return res, nil
+}
@@ -0,0 +1,225 @@
+package errorbody
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/core"
+	"github.com/luraproject/lura/v2/transport/http/server"
+
+	"github.com/krakend/krakend-ce/v2/problem"
+)
+
+// Renderer writes the error bodies configured for the service and its endpoints. The zero value
+// renders no error body, keeping the default responses.
+type Renderer struct {
+	global    bodies
+	endpoints map[string]bodies
+	problem   bool
+}
+
+type bodies struct {
+	static      map[int]interface{}
+	templates   map[int]*template.Template
+	contentType string
+}
+
+// TemplateData is the data available to the error templates
+type TemplateData struct {
+	Status     int
+	StatusText string
+	Method     string
+	Path       string
+	RequestID  string
+	Detail     string
+}
+
+// funcs are the functions available to the templates. json encodes a value as a json literal, so
+// the values sent by the clients can not break the json bodies.
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// New parses the error bodies of the service and its endpoints. The endpoint bodies override the
+// service ones for the same status. No renderer is returned when any of them is wrong.
+func New(cfg config.ServiceConfig) (*Renderer, error) {
+	r := &Renderer{endpoints: map[string]bodies{}}
+
+	gCfg, err := ParseConfig(cfg.ExtraConfig)
+	if err != nil && err != ErrNoConfig {
+		return nil, err
+	}
+	r.problem = gCfg.ProblemDetails
+	defaultContentType := "application/json"
+	if r.problem {
+		defaultContentType = problem.ContentType
+	}
+	if r.global, err = newBodies(gCfg, bodies{contentType: defaultContentType}); err != nil {
+		return nil, err
+	}
+
+	for _, e := range cfg.Endpoints {
+		eCfg, err := ParseConfig(e.ExtraConfig)
+		if err == ErrNoConfig {
+			continue
+		}
+		if err == nil {
+			r.endpoints[e.Method+" "+e.Endpoint], err = newBodies(eCfg, r.global)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("endpoint %s: %w", e.Endpoint, err)
+		}
+	}
+	return r, nil
+}
+
+func newBodies(cfg Config, parent bodies) (bodies, error) {
+	res := bodies{
+		static:      map[int]interface{}{},
+		templates:   map[int]*template.Template{},
+		contentType: parent.contentType,
+	}
+	for k, v := range parent.static {
+		res.static[k] = v
+	}
+	for k, v := range parent.templates {
+		res.templates[k] = v
+	}
+	if cfg.ContentType != "" {
+		res.contentType = cfg.ContentType
+	}
+
+	for k, v := range cfg.Bodies {
+		status, err := strconv.Atoi(k)
+		if err != nil {
+			return res, fmt.Errorf("invalid status code %s", k)
+		}
+		res.static[status] = v
+	}
+	for k, v := range cfg.Templates {
+		status, err := strconv.Atoi(k)
+		if err != nil {
+			return res, fmt.Errorf("invalid status code %s", k)
+		}
+		tmpl, err := template.New(k).Funcs(funcs).Parse(v)
+		if err != nil {
+			return res, err
+		}
+		res.templates[status] = tmpl
+	}
+	return res, nil
+}
+
+// Enabled returns true if there is any error body to render
+func (r *Renderer) Enabled() bool {
+	return r.problem || len(r.global.static)+len(r.global.templates) > 0 || len(r.endpoints) > 0
+}
+
+// Abort aborts the request with the error body configured for the status, if any
+func (r *Renderer) Abort(c *gin.Context, status int) {
+	c.Header(core.KrakendHeaderName, core.KrakendHeaderValue)
+	c.Header(server.CompleteResponseHeaderName, server.HeaderIncompleteResponseValue)
+	if !r.render(c, status, "") {
+		c.AbortWithStatus(status)
+		return
+	}
+	c.Abort()
+}
+
+// Handler returns a handler for the NoRoute and NoMethod cases. When there is no error body for the
+// status, the default gin response is kept.
+func (r *Renderer) Handler(status int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header(core.KrakendHeaderName, core.KrakendHeaderValue)
+		c.Header(server.CompleteResponseHeaderName, server.HeaderIncompleteResponseValue)
+		if r.render(c, status, "") {
+			c.Abort()
+		}
+	}
+}
+
+// HandlerFunc is a middleware replacing the error responses without a body or with a plain text or
+// json one by the error body configured for their status. The original body is available as the
+// detail. Error responses with any other content type are preserved.
+func (r *Renderer) HandlerFunc(c *gin.Context) {
+	w := &writer{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.Next()
+	c.Writer = w.ResponseWriter
+
+	if !w.intercepting {
+		return
+	}
+	if r.render(c, w.status, strings.TrimSpace(w.buf.String())) {
+		return
+	}
+	c.Writer.WriteHeader(w.status)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Write(w.buf.Bytes())
+}
+
+func (r *Renderer) render(c *gin.Context, status int, detail string) bool {
+	b, ok := r.endpoints[c.Request.Method+" "+c.FullPath()]
+	if !ok {
+		b = r.global
+	}
+
+	if tmpl, ok := b.templates[status]; ok {
+		data := TemplateData{
+			Status:     status,
+			StatusText: http.StatusText(status),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			RequestID:  requestID(c),
+			Detail:     detail,
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err == nil {
+			write(c, status, b.contentType, buf.Bytes())
+			return true
+		}
+	}
+
+	body, ok := b.static[status]
+	if r.problem {
+		tmpl, _ := body.(map[string]interface{})
+		body, ok = problem.Document(c.Request, status, detail, tmpl), true
+	}
+	if !ok {
+		return false
+	}
+	res, err := json.Marshal(body)
+	if err != nil {
+		return false
+	}
+	contentType := "application/json; charset=utf-8"
+	if r.problem {
+		contentType = problem.ContentType
+	}
+	write(c, status, contentType, res)
+	return true
+}
+
+func write(c *gin.Context, status int, contentType string, body []byte) {
+	c.Writer.Header().Del("Content-Length")
+	c.Writer.Header().Set("Content-Type", contentType)
+	c.Writer.WriteHeader(status)
+	c.Writer.Write(body)
+}
+
+func requestID(c *gin.Context) string {
+	if id := c.Request.Header.Get("X-Request-Id"); id != "" {
+		return id
+	}
+	return c.Writer.Header().Get("X-Request-Id")
+}
@@ -0,0 +1,159 @@
+package errorbody
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	router "github.com/luraproject/lura/v2/router/gin"
+
+	"github.com/krakend/krakend-ce/v2/problem"
+)
+
+func TestRenderer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			router.Namespace: map[string]interface{}{
+				"error_body": map[string]interface{}{
+					"404": map[string]interface{}{"error": "not found"},
+					"401": map[string]interface{}{"error": "unauthorized"},
+				},
+				"error_template": map[string]interface{}{
+					"500": `{"error":{{json .Detail}},"path":{{json .Path}},"request_id":{{json .RequestID}}}`,
+				},
+			},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/private/:id",
+				Method:   http.MethodGet,
+				ExtraConfig: config.ExtraConfig{
+					router.Namespace: map[string]interface{}{
+						"error_template": map[string]interface{}{
+							"401": `{{.Method}} {{.Status}} {{.StatusText}}`,
+						},
+						"error_content_type": "text/html",
+					},
+				},
+			},
+		},
+	}
+
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := gin.New()
+	engine.Use(r.HandlerFunc)
+	unauthorized := func(c *gin.Context) { c.AbortWithStatus(http.StatusUnauthorized) }
+	engine.GET("/public", unauthorized)
+	engine.GET("/private/:id", unauthorized)
+	engine.GET("/text", func(c *gin.Context) { c.String(http.StatusInternalServerError, `boom "quoted"`) })
+	engine.GET("/json", func(c *gin.Context) { c.JSON(http.StatusBadRequest, gin.H{"message": "bad"}) })
+	engine.GET("/json-error", func(c *gin.Context) { c.JSON(http.StatusInternalServerError, gin.H{"message": "boom"}) })
+	engine.GET("/problem", func(c *gin.Context) {
+		c.Header("Content-Type", problem.ContentType)
+		c.String(http.StatusInternalServerError, `{"title":"boom"}`)
+	})
+	engine.GET("/teapot", func(c *gin.Context) { c.String(http.StatusTeapot, "short and stout") })
+	engine.NoRoute(r.Handler(http.StatusNotFound))
+
+	for _, tc := range []struct {
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{path: "/public", status: 401, contentType: "application/json; charset=utf-8", body: `{"error":"unauthorized"}`},
+		{path: "/private/1", status: 401, contentType: "text/html", body: `GET 401 Unauthorized`},
+		{path: "/text", status: 500, contentType: "application/json", body: `{"error":"boom \"quoted\"","path":"/text","request_id":"abc"}`},
+		{path: "/json", status: 400, contentType: "application/json; charset=utf-8", body: `{"message":"bad"}`},
+		{path: "/json-error", status: 500, contentType: "application/json", body: `{"error":"{\"message\":\"boom\"}","path":"/json-error","request_id":"abc"}`},
+		{path: "/problem", status: 500, contentType: problem.ContentType, body: `{"title":"boom"}`},
+		{path: "/teapot", status: 418, contentType: "text/plain; charset=utf-8", body: `short and stout`},
+		{path: "/missing", status: 404, contentType: "application/json; charset=utf-8", body: `{"error":"not found"}`},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("X-Request-Id", "abc")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Errorf("unexpected status: %d", w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tc.contentType {
+				t.Errorf("unexpected content type: %s", ct)
+			}
+			if w.Body.String() != tc.body {
+				t.Errorf("unexpected body: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRenderer_problemDetails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r, err := New(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			router.Namespace: map[string]interface{}{
+				"problem_details": true,
+				"error_body": map[string]interface{}{
+					"403": map[string]interface{}{"type": "https://example.com/probs/forbidden"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := gin.New()
+	engine.Use(r.HandlerFunc)
+	engine.GET("/forbidden", func(c *gin.Context) { c.AbortWithStatus(http.StatusForbidden) })
+	engine.GET("/text", func(c *gin.Context) { c.String(http.StatusBadGateway, "backend down") })
+	engine.GET("/json", func(c *gin.Context) { c.JSON(http.StatusBadGateway, gin.H{"message": "backend down"}) })
+	engine.NoRoute(r.Handler(http.StatusNotFound))
+
+	for path, body := range map[string]string{
+		"/forbidden": `{"instance":"/forbidden","status":403,"title":"Forbidden","type":"https://example.com/probs/forbidden"}`,
+		"/text":      `{"detail":"backend down","instance":"/text","status":502,"title":"Bad Gateway","type":"about:blank"}`,
+		"/json":      `{"detail":"{\"message\":\"backend down\"}","instance":"/json","status":502,"title":"Bad Gateway","type":"about:blank"}`,
+		"/missing":   `{"instance":"/missing","status":404,"title":"Not Found","type":"about:blank"}`,
+	} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if ct := w.Header().Get("Content-Type"); ct != problem.ContentType {
+			t.Errorf("%s: unexpected content type: %s", path, ct)
+		}
+		if w.Body.String() != body {
+			t.Errorf("%s: unexpected body: %s", path, w.Body.String())
+		}
+	}
+}
+
+func TestNew_wrongConfig(t *testing.T) {
+	r, err := New(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			router.Namespace: map[string]interface{}{
+				"error_template": map[string]interface{}{"500": `{"error":{{json .Detail}`},
+			},
+		},
+	})
+	if err == nil || r != nil {
+		t.Errorf("unexpected result: %v, %v", r, err)
+	}
+
+	// the zero value keeps the default responses
+	r = &Renderer{}
+	engine := gin.New()
+	engine.GET("/", func(c *gin.Context) { r.Abort(c, http.StatusTeapot) })
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusTeapot || w.Body.Len() != 0 || r.Enabled() {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,96 @@
+package errorbody
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writer holds back the error responses until it knows if their body has to be replaced
+type writer struct {
+	gin.ResponseWriter
+	status       int
+	intercepting bool
+	buf          bytes.Buffer
+}
+
+func (w *writer) WriteHeader(code int) {
+	if w.ResponseWriter.Written() {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code >= 400 {
+		w.status = code
+		w.intercepting = true
+		return
+	}
+	w.intercepting = false
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *writer) WriteHeaderNow() {
+	if w.intercepting {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if !w.intercepting && !w.ResponseWriter.Written() && w.ResponseWriter.Status() >= 400 {
+		w.WriteHeader(w.ResponseWriter.Status())
+	}
+	if w.intercepting && !replaceable(w.Header().Get("Content-Type")) {
+		w.intercepting = false
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.intercepting {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *writer) Status() int {
+	if w.intercepting {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *writer) Size() int {
+	if w.intercepting {
+		return w.buf.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *writer) Written() bool {
+	return w.intercepting || w.ResponseWriter.Written()
+}
+
+func (w *writer) Flush() {
+	if w.intercepting {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap lets the http.ResponseController reach the connection of the response
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// replaceable reports if the error body is a generic one. Other formats, like the problem details
+// documents, are kept as they are.
+func replaceable(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/plain" || mediaType == "application/json"
+}
@@ -0,0 +1,97 @@
+// Package eventstream pushes the messages of a broker subscription to the clients of an endpoint
+// as server-sent events. All the clients connected to the same endpoint share a single
+// subscription, opened with the first client and closed with the last one, so every message is
+// delivered to all the connected clients matching its filter.
+//
+// The event stream is not a durable consumer: every received message is acknowledged once
+// dispatched, so the messages arriving while no client matches them (or no client is connected
+// and the subscription is still open) are lost. Use a dedicated subscription for the endpoint and
+// keep consuming the broker elsewhere when the messages must not be dropped.
+//
+// The subscriptions are opened with the gocloud.dev URLs registered by the pubsub component:
+// rabbit://queue for AMQP queues, kafka://group?topic=name for Kafka topics, gcppubsub://...
+// for Google Cloud PubSub subscriptions and so on.
+package eventstream
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key used to store the event stream config at the backend ExtraConfig
+const Namespace = "backend/eventstream"
+
+const (
+	defaultHeartbeatInterval = 15 * time.Second
+	defaultBufferSize        = 64
+)
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+var (
+	errNoSubscription = errors.New("the event stream requires a subscription_url")
+	errFilter         = errors.New("the filter requires a metadata key and either a claim or a param")
+)
+
+// Config defines the subscription feeding the event stream of an endpoint. It must be declared at
+// the only backend of the endpoint. The host of the backend is not used.
+type Config struct {
+	SubscriptionURL string `json:"subscription_url"`
+	// Event is the name of the events sent to the clients. Default: none, so they are received as
+	// message events
+	Event string `json:"event"`
+	// Filter delivers the messages to the clients matching the value of a metadata entry
+	Filter *Filter `json:"filter"`
+	// HeartbeatInterval is the time between the comments sent to keep the idle connections open.
+	// Default: 15s
+	HeartbeatInterval string `json:"heartbeat_interval"`
+	// BufferSize is the number of messages waiting to be sent to a client. The clients not keeping
+	// up are disconnected. Default: 64
+	BufferSize int `json:"buffer_size"`
+
+	heartbeatInterval time.Duration
+}
+
+// Filter compares a metadata entry of the messages (the headers of AMQP and Kafka messages or the
+// attributes of PubSub ones) with a claim of the JWT of the client or a param of the endpoint
+type Filter struct {
+	Metadata string `json:"metadata"`
+	Claim    string `json:"claim"`
+	Param    string `json:"param"`
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if res.SubscriptionURL == "" {
+		return res, errNoSubscription
+	}
+	if f := res.Filter; f != nil && (f.Metadata == "" || (f.Claim == "") == (f.Param == "")) {
+		return res, errFilter
+	}
+	res.heartbeatInterval = defaultHeartbeatInterval
+	if res.HeartbeatInterval != "" {
+		if res.heartbeatInterval, err = time.ParseDuration(res.HeartbeatInterval); err != nil {
+			return res, err
+		}
+	}
+	if res.BufferSize <= 0 {
+		res.BufferSize = defaultBufferSize
+	}
+	return res, nil
+}
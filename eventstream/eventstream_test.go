@@ -0,0 +1,174 @@
+package eventstream
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	metrics "github.com/krakend/krakend-metrics/v2"
+	metricsgin "github.com/krakend/krakend-metrics/v2/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	gometrics "github.com/rcrowley/go-metrics"
+	"gocloud.dev/pubsub"
+	_ "gocloud.dev/pubsub/mempubsub"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	url := "mem://events-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	topic, err := pubsub.OpenTopic(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer topic.Shutdown(ctx)
+
+	gin.SetMode(gin.TestMode)
+	eCfg, err := ParseConfig(config.ExtraConfig{Namespace: map[string]interface{}{
+		"subscription_url": url,
+		"event":            "chat",
+		"filter":           map[string]interface{}{"metadata": "room", "param": "room"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHub(eCfg, logging.NoOp, "")
+	engine := gin.New()
+	engine.GET("/rooms/:room/events", handler(h, nil))
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	req, _ := http.NewRequestWithContext(reqCtx, "GET", srv.URL+"/rooms/a/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+
+	for _, m := range []struct{ room, body string }{{"b", "ignored"}, {"a", "hello\nworld"}} {
+		if err := topic.Send(ctx, &pubsub.Message{Body: []byte(m.body), Metadata: map[string]string{"room": m.room}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(line, "id:") {
+			continue
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	if strings.Join(lines, "|") != "event: chat|data: hello|data: world" {
+		t.Errorf("unexpected event: %v", lines)
+	}
+
+	// the subscription is closed with the last client
+	cancel()
+	for i := 0; ; i++ {
+		h.mu.Lock()
+		idle := h.cancel == nil && len(h.clients) == 0
+		h.mu.Unlock()
+		if idle {
+			break
+		}
+		if i == 500 {
+			t.Fatal("the subscription was not closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandlerFactory_wrongConfig(t *testing.T) {
+	for name, backends := range map[string][]*config.Backend{
+		"multiple backends": {
+			{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"subscription_url": "mem://x"}}},
+			{},
+		},
+		"claim without validator": {
+			{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+				"subscription_url": "mem://x",
+				"filter":           map[string]interface{}{"metadata": "tenant", "claim": "tenant"},
+			}}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.EndpointConfig{Endpoint: "/events", Backend: backends}
+			hf := HandlerFactory(func(*config.EndpointConfig, proxy.Proxy) gin.HandlerFunc {
+				t.Error("unexpected call to the next handler factory")
+				return nil
+			}, logging.NoOp, func(*config.EndpointConfig) func(*http.Request) map[string]interface{} { return nil })
+			engine := gin.New()
+			engine.GET(cfg.Endpoint, hf(cfg, nil))
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("unexpected status code: %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestRegister_writeTimeout(t *testing.T) {
+	ctx := context.Background()
+	url := "mem://events-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	topic, err := pubsub.OpenTopic(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer topic.Shutdown(ctx)
+
+	gin.SetMode(gin.TestMode)
+	cfg := &config.EndpointConfig{
+		Endpoint: "/events",
+		Method:   "GET",
+		Backend: []*config.Backend{{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"subscription_url":   url,
+			"heartbeat_interval": "50ms",
+		}}}},
+	}
+	engine := gin.New()
+	Register(config.ServiceConfig{Endpoints: []*config.EndpointConfig{cfg}}, logging.NoOp, engine)
+	// the metrics of the router wrap the response writer of every endpoint
+	registry := gometrics.NewRegistry()
+	hf := metricsgin.NewHTTPHandlerFactory(metrics.NewRouterMetrics(&registry), HandlerFactory(nil, logging.NoOp, nil))
+	engine.GET(cfg.Endpoint, hf(cfg, nil))
+
+	gw := httptest.NewUnstartedServer(engine)
+	gw.Config.WriteTimeout = 100 * time.Millisecond
+	gw.Start()
+	defer gw.Close()
+
+	c := &http.Client{Timeout: 5 * time.Second}
+	resp, err := c.Get(gw.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// the heartbeats keep arriving after the write timeout
+	r := bufio.NewReader(resp.Body)
+	for pings := 0; pings < 4; {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("the stream was closed after %d heartbeats: %v", pings, err)
+		}
+		if line == ": ping\n" {
+			pings++
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package eventstream
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	router "github.com/luraproject/lura/v2/router/gin"
+
+	"github.com/krakend/krakend-ce/v2/streaming"
+)
+
+var (
+	errBackends  = errors.New("the event stream must be the only backend of the endpoint")
+	errValidator = errors.New("filtering by claim requires the JWT validator (auth/validator) at the endpoint")
+)
+
+// HandlerFactory checks the configuration and, if required, replaces the handler of the endpoint
+// with the event stream. It should be the innermost layer of the handler factory, so the rest of
+// the middlewares apply before subscribing. The claims used by the filters are not validated
+// again, so the endpoints filtering by claim must declare the JWT validator: claimsF returns nil
+// for the endpoints without it.
+func HandlerFactory(hf router.HandlerFactory, l logging.Logger, claimsF func(*config.EndpointConfig) func(*http.Request) map[string]interface{}) router.HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][Event stream]"
+
+		var (
+			eCfg  Config
+			err   = ErrNoConfig
+			found int
+		)
+		for _, b := range cfg.Backend {
+			if _, ok := b.ExtraConfig[Namespace]; ok {
+				found++
+				eCfg, err = ParseConfig(b.ExtraConfig)
+			}
+		}
+		if found == 0 {
+			return hf(cfg, p)
+		}
+		if found > 1 || len(cfg.Backend) > 1 {
+			err = errBackends
+		}
+
+		var claims func(*http.Request) map[string]interface{}
+		if err == nil && eCfg.Filter != nil && eCfg.Filter.Claim != "" {
+			if claimsF != nil {
+				claims = claimsF(cfg)
+			}
+			if claims == nil {
+				err = errValidator
+			}
+		}
+		if err != nil {
+			l.Error(logPrefix, err.Error())
+			return func(c *gin.Context) {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}
+
+		l.Debug(logPrefix, "Streaming the messages of", eCfg.SubscriptionURL)
+		return handler(newHub(eCfg, l, logPrefix), claims)
+	}
+}
+
+func handler(h *hub, claims func(*http.Request) map[string]interface{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cl := &client{events: make(chan []byte, h.cfg.BufferSize)}
+		if f := h.cfg.Filter; f != nil {
+			if f.Param != "" {
+				cl.filter = c.Param(f.Param)
+			} else if v, ok := claims(c.Request)[f.Claim]; ok && v != nil {
+				cl.filter = fmt.Sprint(v)
+			}
+			if cl.filter == "" {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+		}
+
+		if err := h.join(cl); err != nil {
+			h.l.Warning(h.logPrefix, "Unable to open the subscription:", err.Error())
+			c.AbortWithStatus(http.StatusBadGateway)
+			return
+		}
+		defer h.leave(cl)
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		// ask the reverse proxies in front of the gateway not to buffer the events
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.WriteHeaderNow()
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(h.cfg.heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			var ev []byte
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-heartbeat.C:
+				ev = []byte(": ping\n\n")
+			case e, ok := <-cl.events:
+				if !ok {
+					return
+				}
+				ev = e
+			}
+			if _, err := c.Writer.Write(ev); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// Register removes the write deadline of the server for the requests to the event stream
+// endpoints, as the subscriptions last longer than the write timeout of the service. It must be
+// registered at the engine, before the handler factories (like the metrics one) wrap the response
+// writer.
+func Register(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine) {
+	paths := map[string]bool{}
+	for _, e := range cfg.Endpoints {
+		for _, b := range e.Backend {
+			if _, ok := b.ExtraConfig[Namespace]; ok {
+				paths[e.Endpoint] = true
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	engine.Use(streaming.NoWriteDeadline(paths, l, "[SERVICE: Gin][Event stream]"))
+}
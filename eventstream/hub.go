@@ -0,0 +1,171 @@
+package eventstream
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/luraproject/lura/v2/logging"
+	"gocloud.dev/pubsub"
+)
+
+// hub shares the subscription of an endpoint among its clients
+type hub struct {
+	cfg       Config
+	l         logging.Logger
+	logPrefix string
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	cancel  context.CancelFunc
+	// opening is closed once the subscription being opened is ready or failed
+	opening chan struct{}
+}
+
+// client receives the events matching its filter value. The channel is closed when the client is
+// removed from the hub.
+type client struct {
+	filter string
+	events chan []byte
+}
+
+func newHub(cfg Config, l logging.Logger, logPrefix string) *hub {
+	return &hub{
+		cfg:       cfg,
+		l:         l,
+		logPrefix: logPrefix,
+		clients:   map[*client]struct{}{},
+	}
+}
+
+// join adds the client to the hub, opening the subscription if it is the first one. The lock is
+// released while opening the subscription, so the clients joining meanwhile wait for it.
+func (h *hub) join(c *client) error {
+	h.mu.Lock()
+	for h.opening != nil {
+		opening := h.opening
+		h.mu.Unlock()
+		<-opening
+		h.mu.Lock()
+	}
+
+	if h.cancel == nil {
+		opening := make(chan struct{})
+		h.opening = opening
+		h.mu.Unlock()
+
+		sub, err := pubsub.OpenSubscription(context.Background(), h.cfg.SubscriptionURL)
+
+		h.mu.Lock()
+		h.opening = nil
+		close(opening)
+		if err != nil {
+			h.mu.Unlock()
+			return err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		h.cancel = cancel
+		go h.receive(ctx, sub)
+		h.l.Debug(h.logPrefix, "Subscription opened")
+	}
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	return nil
+}
+
+// leave removes the client from the hub, closing the subscription if it was the last one
+func (h *hub) leave(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[c]; ok {
+		h.remove(c)
+	}
+	h.stopIfIdle()
+}
+
+func (h *hub) receive(ctx context.Context, sub *pubsub.Subscription) {
+	defer sub.Shutdown(context.Background())
+
+	for {
+		msg, err := sub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			h.l.Warning(h.logPrefix, "Unable to receive from the subscription:", err.Error())
+			// disconnect the clients, so the subscription is opened again when they reconnect
+			h.mu.Lock()
+			if ctx.Err() == nil {
+				for c := range h.clients {
+					h.remove(c)
+				}
+				h.stopIfIdle()
+			}
+			h.mu.Unlock()
+			return
+		}
+
+		// the messages are acknowledged once dispatched, even if no client matches them: they are
+		// dropped rather than redelivered
+		h.dispatch(msg)
+		msg.Ack()
+	}
+}
+
+func (h *hub) dispatch(msg *pubsub.Message) {
+	ev := h.format(msg)
+	var value string
+	if h.cfg.Filter != nil {
+		value = msg.Metadata[h.cfg.Filter.Metadata]
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if h.cfg.Filter != nil && c.filter != value {
+			continue
+		}
+		select {
+		case c.events <- ev:
+		default:
+			h.l.Debug(h.logPrefix, "Disconnecting a client not keeping up with the events")
+			h.remove(c)
+		}
+	}
+	h.stopIfIdle()
+}
+
+// format encodes the message as a server-sent event
+func (h *hub) format(msg *pubsub.Message) []byte {
+	var b bytes.Buffer
+	if id := strings.NewReplacer("\r", "", "\n", "").Replace(msg.LoggableID); id != "" {
+		b.WriteString("id: " + id + "\n")
+	}
+	if h.cfg.Event != "" {
+		b.WriteString("event: " + h.cfg.Event + "\n")
+	}
+	data := strings.ReplaceAll(string(msg.Body), "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+// remove must be called holding the lock
+func (h *hub) remove(c *client) {
+	delete(h.clients, c)
+	close(c.events)
+}
+
+// stopIfIdle must be called holding the lock
+func (h *hub) stopIfIdle() {
+	if len(h.clients) > 0 || h.cancel == nil {
+		return
+	}
+	h.cancel()
+	h.cancel = nil
+	h.l.Debug(h.logPrefix, "Subscription closed")
+}
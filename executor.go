@@ -15,6 +15,12 @@ import (
 	krakendbf "github.com/krakend/bloomfilter/v2/krakend"
 	asyncamqp "github.com/krakend/krakend-amqp/v2/async"
 	audit "github.com/krakend/krakend-audit"
+	"github.com/krakend/krakend-ce/v2/certloader"
+	"github.com/krakend/krakend-ce/v2/h3"
+	"github.com/krakend/krakend-ce/v2/listener"
+	"github.com/krakend/krakend-ce/v2/mtls"
+	"github.com/krakend/krakend-ce/v2/unixsock"
+	"github.com/krakend/krakend-ce/v2/vhost"
 	cel "github.com/krakend/krakend-cel/v2"
 	cmd "github.com/krakend/krakend-cobra/v2"
 	cors "github.com/krakend/krakend-cors/v2/gin"
@@ -101,11 +107,26 @@ type BackendFactory interface {
 	NewBackendFactory(context.Context, logging.Logger, *metrics.Metrics) proxy.BackendFactory
 }
 
+// EngineFactoryWithMetrics is an EngineFactory registering the metrics of the engine middlewares
+type EngineFactoryWithMetrics interface {
+	NewEngineWithMetrics(config.ServiceConfig, router.EngineOptions, *metrics.Metrics) *gin.Engine
+}
+
+// EngineFactoryWithContext is an EngineFactoryWithMetrics bound to the context of the service
+type EngineFactoryWithContext interface {
+	NewEngineWithContext(context.Context, config.ServiceConfig, router.EngineOptions, *metrics.Metrics) *gin.Engine
+}
+
 // HandlerFactory returns a KrakenD router handler factory, ready to be passed to the KrakenD RouterFactory
 type HandlerFactory interface {
 	NewHandlerFactory(logging.Logger, *metrics.Metrics, jose.RejecterFactory) router.HandlerFactory
 }
 
+// HandlerFactoryWithContext is a HandlerFactory bound to the context of the service
+type HandlerFactoryWithContext interface {
+	NewHandlerFactoryWithContext(context.Context, logging.Logger, *metrics.Metrics, jose.RejecterFactory) router.HandlerFactory
+}
+
 // LoggerFactory returns a KrakenD Logger factory, ready to be passed to the KrakenD RouterFactory
 type LoggerFactory interface {
 	NewLogger(config.ServiceConfig) (logging.Logger, io.Writer, error)
@@ -148,6 +169,9 @@ type ExecutorBuilder struct {
 	AgentStarterFactory AgentStarter
 
 	Middlewares []gin.HandlerFunc
+	// ListenerMiddlewares are the middlewares of the additional listeners, indexed by their name.
+	// The listeners not included here use the Middlewares.
+	ListenerMiddlewares map[string][]gin.HandlerFunc
 }
 
 // NewCmdExecutor returns an executor for the cmd package. The executor initializes the entire gateway by
@@ -206,30 +230,96 @@ func (e *ExecutorBuilder) NewCmdExecutor(ctx context.Context) cmd.Executor {
 
 		agentPing := make(chan string, len(cfg.AsyncAgents))
 
-		handlerF := e.HandlerFactory.NewHandlerFactory(logger, metricCollector, tokenRejecterFactory)
+		var handlerF router.HandlerFactory
+		if hf, ok := e.HandlerFactory.(HandlerFactoryWithContext); ok {
+			handlerF = hf.NewHandlerFactoryWithContext(ctx, logger, metricCollector, tokenRejecterFactory)
+		} else {
+			handlerF = e.HandlerFactory.NewHandlerFactory(logger, metricCollector, tokenRejecterFactory)
+		}
 		handlerF = otelgin.New(handlerF)
 
-		runServerChain := serverhttp.RunServerWithLoggerFactory(logger)
+		runServerChain := listener.NewRunServerWithExtensions(
+			logger,
+			[]listener.TLSModifier{
+				certloader.TLSModifier(logger, *metricCollector.Registry),
+				vhost.TLSModifier(logger),
+				mtls.TLSModifier(logger),
+			},
+			h3.Extension(logger),
+			unixsock.Extension(logger),
+		)
 		runServerChain = otellura.GlobalRunServer(logger, runServerChain)
 		runServerChain = router.RunServerFunc(e.RunServerFactory.NewRunServer(logger, runServerChain))
 
-		// setup the krakend router
-		routerFactory := router.NewFactory(router.Config{
-			Engine: e.EngineFactory.NewEngine(cfg, router.EngineOptions{
-				Logger: logger,
-				Writer: gelfWriter,
-				Health: (<-chan string)(agentPing),
-			}),
+		routerCfg := router.Config{
 			ProxyFactory:   pf,
 			Middlewares:    e.Middlewares,
 			Logger:         logger,
 			HandlerFactory: handlerF,
-			RunServer:      runServerChain,
-		})
+		}
+
+		// the service stops when any of its listeners fails
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// move the endpoints of the additional listeners to their own routers, bound to their own ports
+		var listenerRouters []func() error
+		if lCfg, err := listener.ParseConfig(cfg); err == nil {
+			defaultCfg, listeners, err := listener.Split(cfg, lCfg)
+			if err != nil {
+				logger.Error("[SERVICE: Listeners]", err.Error())
+				return
+			}
+			for name, lsCfg := range listeners {
+				logger.Info("[SERVICE: Listeners] Building the router for", name)
+				listenerRouters = append(listenerRouters, e.newListenerRouter(ctx, name, lsCfg, logger, gelfWriter, metricCollector, routerCfg, runServerChain))
+			}
+			cfg = defaultCfg
+		} else if err != listener.ErrNoConfig {
+			logger.Error("[SERVICE: Listeners]", err.Error())
+			return
+		}
+
+		// move the endpoints of the virtual hosts to their own routers, dispatched by the Host header
+		if vCfg, err := vhost.ParseConfig(cfg.ExtraConfig); err == nil {
+			defaultCfg, groups, err := vhost.Split(cfg, vCfg)
+			if err != nil {
+				logger.Error("[SERVICE: Virtual hosts]", err.Error())
+				return
+			}
+			handlers := make(map[string]http.Handler, len(groups))
+			for name, hCfg := range groups {
+				logger.Info("[SERVICE: Virtual hosts] Building the router for", name)
+				handlers[name] = e.newHostHandler(ctx, hCfg, logger, gelfWriter, metricCollector, routerCfg)
+			}
+			cfg = defaultCfg
+			runServerChain = vhost.NewRunServer(vCfg, handlers, runServerChain)
+		} else if err != vhost.ErrNoConfig {
+			logger.Error("[SERVICE: Virtual hosts]", err.Error())
+			return
+		}
+
+		// setup the krakend router
+		routerCfg.Engine = e.newEngine(ctx, cfg, router.EngineOptions{
+			Logger: logger,
+			Writer: gelfWriter,
+			Health: (<-chan string)(agentPing),
+		}, metricCollector)
+		routerCfg.RunServer = runServerChain
+		routerFactory := router.NewFactory(routerCfg)
 
 		// start the engines
 		logger.Info("Starting the KrakenD instance")
 
+		for _, run := range listenerRouters {
+			go func(run func() error) {
+				if err := run(); err != nil {
+					logger.Error("[SERVICE: Listeners] Stopping the service:", err.Error())
+					cancel()
+				}
+			}(run)
+		}
+
 		if len(cfg.AsyncAgents) == 0 {
 			routerFactory.NewWithContext(ctx).Run(cfg)
 			return
@@ -259,6 +349,57 @@ func (e *ExecutorBuilder) NewCmdExecutor(ctx context.Context) cmd.Executor {
 	}
 }
 
+// newHostHandler builds the router of a group of virtual hosts and returns its handler, without
+// starting any server
+func (e *ExecutorBuilder) newHostHandler(ctx context.Context, cfg config.ServiceConfig, logger logging.Logger, w io.Writer, m *metrics.Metrics, routerCfg router.Config) http.Handler {
+	var handler http.Handler
+	routerCfg.Engine = e.newEngine(ctx, cfg, router.EngineOptions{
+		Logger: logger,
+		Writer: w,
+	}, m)
+	routerCfg.RunServer = func(_ context.Context, _ config.ServiceConfig, h http.Handler) error {
+		handler = h
+		return nil
+	}
+	router.NewFactory(routerCfg).NewWithContext(ctx).Run(cfg)
+	return handler
+}
+
+// newListenerRouter builds the router of an additional listener and returns the function starting it,
+// returning the error of its server, if any
+func (e *ExecutorBuilder) newListenerRouter(ctx context.Context, name string, cfg config.ServiceConfig, logger logging.Logger, w io.Writer, m *metrics.Metrics, routerCfg router.Config, runServer router.RunServerFunc) func() error {
+	if mws, ok := e.ListenerMiddlewares[name]; ok {
+		routerCfg.Middlewares = mws
+	}
+	routerCfg.Engine = e.newEngine(ctx, cfg, router.EngineOptions{
+		Logger: logger,
+		Writer: w,
+	}, m)
+	// the router only logs the errors of the server
+	var runErr error
+	routerCfg.RunServer = func(ctx context.Context, cfg config.ServiceConfig, h http.Handler) error {
+		runErr = runServer(ctx, cfg, h)
+		return runErr
+	}
+	r := router.NewFactory(routerCfg).NewWithContext(ctx)
+	return func() error {
+		r.Run(cfg)
+		return runErr
+	}
+}
+
+// newEngine builds a gin engine, registering its metrics and binding it to the context of the
+// service when the engine factory supports it
+func (e *ExecutorBuilder) newEngine(ctx context.Context, cfg config.ServiceConfig, opt router.EngineOptions, m *metrics.Metrics) *gin.Engine {
+	if ef, ok := e.EngineFactory.(EngineFactoryWithContext); ok {
+		return ef.NewEngineWithContext(ctx, cfg, opt, m)
+	}
+	if ef, ok := e.EngineFactory.(EngineFactoryWithMetrics); ok {
+		return ef.NewEngineWithMetrics(cfg, opt, m)
+	}
+	return e.EngineFactory.NewEngine(cfg, opt)
+}
+
 func (e *ExecutorBuilder) checkCollaborators() {
 	if e.PluginLoader == nil {
 		e.PluginLoader = new(pluginLoader)
@@ -0,0 +1,116 @@
+// Package federation exposes a single GraphQL schema composed from the schemas of the backends of
+// an endpoint (the subgraphs). The root fields are resolved by the subgraph declaring them, and
+// the links declared at the endpoint add fields to the types of a subgraph that are resolved by
+// querying another one, batching the requests for all the parent objects.
+//
+// The subgraphs can be GraphQL services or REST backends, declaring the GraphQL types of their
+// resources and the url of every root field.
+package federation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key used to store the federation config at the endpoint and backend ExtraConfig
+const Namespace = "graphql/federation"
+
+const defaultBatchSize = 100
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// Config defines the federated schema of an endpoint
+type Config struct {
+	Links []Link `json:"links"`
+	// BatchSize is the max number of parent objects resolved by a single request to a subgraph.
+	// Default: 100
+	BatchSize int `json:"batch_size"`
+}
+
+// Link adds the Field to the Type, resolved with the Query root field of the Subgraph. The
+// Arguments of the query are taken from the fields of the parent object, and the type of the
+// new field is the one of the query.
+type Link struct {
+	Type      string            `json:"type"`
+	Field     string            `json:"field"`
+	Subgraph  string            `json:"subgraph"`
+	Query     string            `json:"query"`
+	Arguments map[string]string `json:"arguments"`
+}
+
+// Subgraph defines the schema of a backend
+type Subgraph struct {
+	Name string `json:"name"`
+	// Schema or SchemaFile contain the SDL of the subgraph
+	Schema     string `json:"schema"`
+	SchemaFile string `json:"schema_file"`
+	// Resources turns the backend into a REST subgraph, mapping every root field (Query.user,
+	// Mutation.createUser...) to a resource
+	Resources map[string]Resource `json:"resources"`
+}
+
+// Resource is the REST resource resolving a root field. The arguments of the field replace the
+// {name} placeholders of the URLPattern. The rest of them are sent as query string params, or as
+// a JSON body when the method is not GET.
+type Resource struct {
+	URLPattern string `json:"url_pattern"`
+	Method     string `json:"method"`
+	// Target is the dot separated path of the response containing the data of the field
+	Target string `json:"target"`
+}
+
+// ParseConfig extracts the endpoint config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	if err := decode(cfg, &res); err != nil {
+		return res, err
+	}
+	if res.BatchSize <= 0 {
+		res.BatchSize = defaultBatchSize
+	}
+	for i, l := range res.Links {
+		if l.Type == "" || l.Field == "" || l.Subgraph == "" || l.Query == "" {
+			return res, fmt.Errorf("the link #%d requires a type, a field, a subgraph and a query", i)
+		}
+	}
+	return res, nil
+}
+
+// ParseSubgraph extracts the subgraph config from the backend ExtraConfig, loading its schema
+func ParseSubgraph(cfg config.ExtraConfig) (Subgraph, error) {
+	res := Subgraph{}
+	if err := decode(cfg, &res); err != nil {
+		return res, err
+	}
+	if res.Name == "" {
+		return res, errors.New("the subgraph requires a name")
+	}
+	if res.SchemaFile != "" {
+		b, err := os.ReadFile(res.SchemaFile)
+		if err != nil {
+			return res, err
+		}
+		res.Schema = string(b)
+	}
+	if res.Schema == "" {
+		return res, fmt.Errorf("the subgraph %s requires a schema", res.Name)
+	}
+	return res, nil
+}
+
+func decode(cfg config.ExtraConfig, v interface{}) error {
+	e, ok := cfg[Namespace]
+	if !ok {
+		return ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,299 @@
+package federation
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+const (
+	usersSchema = `
+type Query {
+	user(id: ID!): User
+}
+type User {
+	id: ID!
+	name: String
+}`
+	ordersSchema = `
+type Query {
+	orders(userId: ID!, status: Status): [Order]
+}
+enum Status { OPEN CLOSED }
+type Order {
+	id: ID!
+	total: Float
+	userId: ID!
+}`
+)
+
+func TestHandlerFactory(t *testing.T) {
+	users := map[string]string{"1": "Alice", "2": "Bob"}
+	var userRequests atomic.Int32
+	usersBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userRequests.Add(1)
+		if r.Header.Get("Authorization") != "Bearer x" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		doc, err := parser.ParseQuery(&ast.Source{Input: req.Query})
+		if err != nil {
+			t.Errorf("unexpected query %s: %s", req.Query, err.Error())
+			return
+		}
+		data := map[string]interface{}{}
+		for _, s := range doc.Operations[0].SelectionSet {
+			f := s.(*ast.Field)
+			id := f.Arguments.ForName("id").Value.Raw
+			user := map[string]interface{}{}
+			for _, s := range f.SelectionSet {
+				switch sf := s.(*ast.Field); sf.Name {
+				case "__typename":
+					user[sf.Alias] = "User"
+				case "id":
+					user[sf.Alias] = id
+				case "name":
+					user[sf.Alias] = users[id]
+				}
+			}
+			data[f.Alias] = user
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer usersBackend.Close()
+
+	ordersBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.RequestURI() {
+		case "/users/1/orders?status=OPEN":
+			io.WriteString(w, `{"data":[{"id":"a","total":10,"userId":"1"},{"id":"b","total":5,"userId":"2"},{"id":"c","total":1,"userId":"1"}]}`)
+		case "/users/2/orders":
+			io.WriteString(w, `{"data":[{"id":"b","total":5,"userId":"2"}]}`)
+		default:
+			t.Errorf("unexpected request: %s", r.URL.RequestURI())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ordersBackend.Close()
+
+	gw := gateway(t, usersBackend.URL, ordersBackend.URL)
+	defer gw.Close()
+
+	for _, tc := range []struct {
+		name, query, expected string
+		requests              int32
+	}{
+		{
+			name:     "links batched",
+			query:    `{ orders(userId: "1", status: OPEN) { id total buyer: user { name } } }`,
+			expected: `{"data":{"orders":[{"id":"a","total":10,"buyer":{"name":"Alice"}},{"id":"b","total":5,"buyer":{"name":"Bob"}},{"id":"c","total":1,"buyer":{"name":"Alice"}}]}}`,
+			requests: 1,
+		},
+		{
+			name:     "fragments and nested links",
+			query:    `query Q($id: ID!) { kind: __typename user(id: $id) { ...F orders { id user { id } } } } fragment F on User { name }`,
+			expected: `{"data":{"kind":"Query","user":{"name":"Bob","orders":[{"id":"b","user":{"id":"2"}}]}}}`,
+			requests: 2,
+		},
+		{
+			name:  "introspection",
+			query: `{ __schema { queryType { name } } __type(name: "Order") { kind fields { name type { kind name ofType { name } } } } }`,
+			expected: `{"data":{"__schema":{"queryType":{"name":"Query"}},"__type":{"kind":"OBJECT","fields":[` +
+				`{"name":"id","type":{"kind":"NON_NULL","name":null,"ofType":{"name":"ID"}}},` +
+				`{"name":"total","type":{"kind":"SCALAR","name":"Float","ofType":null}},` +
+				`{"name":"userId","type":{"kind":"NON_NULL","name":null,"ofType":{"name":"ID"}}},` +
+				`{"name":"user","type":{"kind":"OBJECT","name":"User","ofType":null}}]}}}`,
+		},
+		{
+			name:     "invalid query",
+			query:    `{ user(id: "1") { unknown } }`,
+			expected: `Cannot query field \"unknown\" on type \"User\".`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			userRequests.Store(0)
+			body := `{"query":` + quote(tc.query) + `,"variables":{"id":"2"}}`
+			req, _ := http.NewRequest("POST", gw.URL+"/graphql", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer x")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(b), tc.expected) {
+				t.Errorf("unexpected response: %s", b)
+			}
+			if n := userRequests.Load(); n != tc.requests {
+				t.Errorf("unexpected number of requests to the users subgraph: %d", n)
+			}
+		})
+	}
+}
+
+func TestGateway_timeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer backend.Close()
+
+	cfg := &config.EndpointConfig{
+		Endpoint: "/graphql",
+		Timeout:  50 * time.Millisecond,
+		Backend: []*config.Backend{
+			{Host: []string{backend.URL}, ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"name": "users", "schema": usersSchema}}},
+		},
+	}
+	g, err := New(context.Background(), cfg, Config{BatchSize: defaultBatchSize}, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	b := g.Execute(context.Background(), Request{Query: `{ user(id: "1") { name } }`}, http.Header{})
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("the operation took %s", d)
+	}
+	if !strings.Contains(string(b), `"errors"`) {
+		t.Errorf("unexpected response: %s", b)
+	}
+}
+
+func TestNew_conflicts(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/graphql",
+		Backend: []*config.Backend{
+			{Host: []string{"http://a"}, ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"name": "a", "schema": usersSchema}}},
+			{Host: []string{"http://b"}, ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"name": "b", "schema": usersSchema}}},
+		},
+	}
+	_, err := New(context.Background(), cfg, Config{}, logging.NoOp)
+	if err == nil || err.Error() != "Query.user is declared by the subgraphs a and b" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func gateway(t *testing.T, usersHost, ordersHost string) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.EndpointConfig{
+		Endpoint:      "/graphql",
+		Method:        "POST",
+		HeadersToPass: []string{"Authorization"},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"links": []interface{}{
+				map[string]interface{}{"type": "Order", "field": "user", "subgraph": "users", "query": "user", "arguments": map[string]interface{}{"id": "userId"}},
+				map[string]interface{}{"type": "User", "field": "orders", "subgraph": "orders", "query": "orders", "arguments": map[string]interface{}{"userId": "id"}},
+			},
+		}},
+		Backend: []*config.Backend{
+			{
+				Host:        []string{usersHost},
+				URLPattern:  "/graphql",
+				ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"name": "users", "schema": usersSchema}},
+			},
+			{
+				Host: []string{ordersHost},
+				ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+					"name":   "orders",
+					"schema": ordersSchema,
+					"resources": map[string]interface{}{
+						"Query.orders": map[string]interface{}{"url_pattern": "/users/{userId}/orders", "target": "data"},
+					},
+				}},
+			},
+		},
+	}
+	hf := HandlerFactory(context.Background(), func(*config.EndpointConfig, proxy.Proxy) gin.HandlerFunc {
+		t.Error("unexpected call to the next handler factory")
+		return nil
+	}, logging.NoOp)
+
+	engine := gin.New()
+	engine.POST(cfg.Endpoint, hf(cfg, nil))
+	return httptest.NewServer(engine)
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func TestGateway_HandlerFunc_getMutation(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/graphql",
+		Backend: []*config.Backend{
+			{Host: []string{"http://127.0.0.1:1"}, ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"name": "users", "schema": usersSchema}}},
+		},
+	}
+	g, err := New(context.Background(), cfg, Config{BatchSize: defaultBatchSize}, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET(cfg.Endpoint, g.HandlerFunc)
+
+	for query, status := range map[string]int{
+		`{ __typename }`:                   http.StatusOK,
+		`mutation { deleteUser(id: "1") }`: http.StatusMethodNotAllowed,
+		`query Q { __typename } mutation M { deleteUser(id: "1") }&operationName=M`: http.StatusMethodNotAllowed,
+	} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest("GET", "/graphql?query="+strings.ReplaceAll(query, " ", "+"), nil))
+		if w.Code != status {
+			t.Errorf("%s: unexpected status code %d: %s", query, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestGateway_compressedSubgraph(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Connection") != "" || r.Header.Get("X-Hop") != "" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			io.WriteString(w, `{"data":{"user":{"name":"Alice"}}}`)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		io.WriteString(gz, `{"data":{"user":{"name":"Alice"}}}`)
+		gz.Close()
+	}))
+	defer backend.Close()
+
+	cfg := &config.EndpointConfig{
+		Endpoint:      "/graphql",
+		HeadersToPass: []string{"*"},
+		Backend: []*config.Backend{
+			{Host: []string{backend.URL}, ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"name": "users", "schema": usersSchema}}},
+		},
+	}
+	g, err := New(context.Background(), cfg, Config{BatchSize: defaultBatchSize}, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.Header{}
+	h.Set("Accept-Encoding", "gzip, deflate")
+	h.Set("Connection", "keep-alive, X-Hop")
+	h.Set("X-Hop", "1")
+	b := g.Execute(context.Background(), Request{Query: `{ user(id: "1") { name } }`}, h)
+	if string(b) != `{"data":{"user":{"name":"Alice"}}}` {
+		t.Errorf("unexpected response: %s", b)
+	}
+}
@@ -0,0 +1,537 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/validator"
+
+	"github.com/krakend/krakend-ce/v2/httpclient"
+)
+
+var errNoSubgraphs = errors.New("the federated endpoint requires at least a subgraph")
+
+// Request is the body of the requests to the federated endpoint and to the GraphQL subgraphs
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Error is a GraphQL error
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Gateway resolves the operations against the federated schema
+type Gateway struct {
+	*composition
+	batchSize int
+	headers   []string
+	timeout   time.Duration
+	l         logging.Logger
+	logPrefix string
+}
+
+type subgraph struct {
+	Subgraph
+	hosts   []string
+	pattern string
+	client  *http.Client
+	next    atomic.Uint64
+	types   map[string]bool
+}
+
+// New composes the schema of the endpoint from the subgraphs declared at its backends. The
+// backends without the subgraph config are ignored. Every subgraph is reached with the client
+// declared at its http client config, if any, or with a client limited to the backend timeout. The
+// client certificates are reloaded until the context is cancelled.
+func New(ctx context.Context, cfg *config.EndpointConfig, fCfg Config, l logging.Logger) (*Gateway, error) {
+	var subgraphs []*subgraph
+	for _, b := range cfg.Backend {
+		sCfg, err := ParseSubgraph(b.ExtraConfig)
+		if err == ErrNoConfig {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(b.Host) == 0 {
+			return nil, fmt.Errorf("the subgraph %s requires a host", sCfg.Name)
+		}
+		sg := &subgraph{
+			Subgraph: sCfg,
+			hosts:    b.Host,
+			pattern:  b.URLPattern,
+			client:   &http.Client{Timeout: b.Timeout},
+		}
+		cf, err := httpclient.NewHTTPClientFactory(ctx, b, l)
+		if err == nil {
+			sg.client = cf(ctx)
+		} else if err != httpclient.ErrNoConfig {
+			return nil, err
+		}
+		subgraphs = append(subgraphs, sg)
+	}
+	if len(subgraphs) == 0 {
+		return nil, errNoSubgraphs
+	}
+
+	c, err := compose(subgraphs, fCfg.Links)
+	if err != nil {
+		return nil, err
+	}
+	for key, sg := range c.owners {
+		if sg.Resources != nil && sg.Resources[key].URLPattern == "" {
+			return nil, fmt.Errorf("the subgraph %s has no resource for %s", sg.Name, key)
+		}
+	}
+	return &Gateway{
+		composition: c,
+		batchSize:   fCfg.BatchSize,
+		headers:     cfg.HeadersToPass,
+		timeout:     cfg.Timeout,
+		l:           l,
+		logPrefix:   "[ENDPOINT: " + cfg.Endpoint + "][Federation]",
+	}, nil
+}
+
+// Execute resolves the operation and returns the encoded response. The headers are forwarded to
+// the subgraphs, and the whole operation is limited to the timeout of the endpoint. The
+// introspection fields are resolved against the federated schema.
+func (g *Gateway) Execute(ctx context.Context, req Request, h http.Header) []byte {
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	doc, errs := gqlparser.LoadQuery(g.schema, req.Query)
+	if len(errs) > 0 {
+		b, _ := json.Marshal(map[string]interface{}{"errors": errs})
+		return b
+	}
+	op := doc.Operations.ForName(req.OperationName)
+	if op == nil {
+		return encodeErrors(Error{Message: "unknown operation " + req.OperationName})
+	}
+	if op.Operation == ast.Subscription {
+		return encodeErrors(Error{Message: "subscriptions are not supported"})
+	}
+	vars, err := validator.VariableValues(g.schema, op, req.Variables)
+	if err != nil {
+		b, _ := json.Marshal(map[string]interface{}{"errors": []error{err}})
+		return b
+	}
+
+	header := http.Header{}
+	for _, k := range g.headers {
+		if k == "*" {
+			header = h.Clone()
+			break
+		}
+		if v, ok := h[http.CanonicalHeaderKey(k)]; ok {
+			header[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+	httpclient.RemoveHopHeaders(header)
+	// the transport only decompresses the responses when it asks for them by itself
+	header.Del("Accept-Encoding")
+	header.Set("Content-Type", "application/json")
+
+	e := &execution{Gateway: g, vars: vars, header: header}
+	return e.run(ctx, op)
+}
+
+func encodeErrors(errs ...Error) []byte {
+	b, _ := json.Marshal(map[string]interface{}{"data": nil, "errors": errs})
+	return b
+}
+
+// execution holds the state of an operation
+type execution struct {
+	*Gateway
+	vars   map[string]interface{}
+	header http.Header
+
+	mu     sync.Mutex
+	errors []interface{}
+}
+
+func (e *execution) run(ctx context.Context, op *ast.OperationDefinition) []byte {
+	root := e.schema.Query
+	if op.Operation == ast.Mutation {
+		root = e.schema.Mutation
+	}
+	fields := e.collectFields(op.SelectionSet, root.Name)
+	data := map[string]interface{}{}
+
+	var groups [][]*ast.Field
+	owners := map[*subgraph]int{}
+	for _, f := range fields {
+		switch f.Name {
+		case "__typename":
+			continue
+		case "__schema", "__type":
+			data[f.Alias] = e.introspect(f)
+			continue
+		}
+		sg := e.owners[root.Name+"."+f.Name]
+		// the mutations are executed one by one, in the order of the operation
+		if i, ok := owners[sg]; ok && op.Operation != ast.Mutation {
+			groups[i] = append(groups[i], f)
+			continue
+		}
+		owners[sg] = len(groups)
+		groups = append(groups, []*ast.Field{f})
+	}
+
+	results := make([]map[string]interface{}, len(groups))
+	if op.Operation == ast.Mutation {
+		for i, fs := range groups {
+			results[i] = e.fetchRoot(ctx, op.Operation, root.Name, fs)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i, fs := range groups {
+			wg.Add(1)
+			go func(i int, fs []*ast.Field) {
+				defer wg.Done()
+				results[i] = e.fetchRoot(ctx, op.Operation, root.Name, fs)
+			}(i, fs)
+		}
+		wg.Wait()
+	}
+	for _, r := range results {
+		for k, v := range r {
+			data[k] = v
+		}
+	}
+
+	e.resolve(ctx, op.SelectionSet, root.Name, []map[string]interface{}{data})
+
+	var b bytes.Buffer
+	b.WriteString(`{"data":`)
+	e.writeObject(&b, op.SelectionSet, root.Name, data)
+	if len(e.errors) > 0 {
+		b.WriteString(`,"errors":`)
+		errs, _ := json.Marshal(e.errors)
+		b.Write(errs)
+	}
+	b.WriteString("}")
+	return b.Bytes()
+}
+
+// fetchRoot resolves the root fields owned by the same subgraph
+func (e *execution) fetchRoot(ctx context.Context, op ast.Operation, root string, fields []*ast.Field) map[string]interface{} {
+	sg := e.owners[root+"."+fields[0].Name]
+	if sg.Resources != nil {
+		res := map[string]interface{}{}
+		for _, f := range fields {
+			v, err := e.fetchResource(ctx, sg, sg.Resources[root+"."+f.Name], f.ArgumentMap(e.vars))
+			if err != nil {
+				e.addError(sg, err, f.Alias)
+				continue
+			}
+			res[f.Alias] = v
+		}
+		return res
+	}
+
+	var b strings.Builder
+	b.WriteString(string(op) + " {")
+	for _, f := range fields {
+		b.WriteString(" " + f.Alias + ": " + f.Name)
+		b.WriteString(e.arguments(f.Definition.Arguments, f.ArgumentMap(e.vars)))
+		b.WriteString(e.selection(f.SelectionSet, f.Definition.Type.Name(), sg))
+	}
+	b.WriteString(" }")
+	res, err := e.fetchGraphQL(ctx, sg, b.String())
+	if err != nil {
+		e.addError(sg, err, fields[0].Alias)
+	}
+	return res
+}
+
+// resolve walks the objects of the type, all of them returned for the selection set, resolving
+// the links found at any level
+func (e *execution) resolve(ctx context.Context, selSet ast.SelectionSet, typeName string, objs []map[string]interface{}) {
+	if len(objs) == 0 {
+		return
+	}
+	byType := map[string][]map[string]interface{}{}
+	var types []string
+	for _, o := range objs {
+		t := typeName
+		if e.isAbstract(typeName) {
+			t, _ = o["__typename"].(string)
+		}
+		if _, ok := byType[t]; !ok {
+			types = append(types, t)
+		}
+		byType[t] = append(byType[t], o)
+	}
+
+	for _, t := range types {
+		objs := byType[t]
+		for _, f := range e.collectFields(selSet, t) {
+			if f.Definition == nil {
+				continue
+			}
+			if l := e.link(t, f.Name); l != nil {
+				e.fetchLink(ctx, l, f, objs)
+			}
+			if len(f.SelectionSet) == 0 {
+				continue
+			}
+			values := make([]interface{}, len(objs))
+			for i, o := range objs {
+				values[i] = o[f.Alias]
+			}
+			e.resolve(ctx, f.SelectionSet, f.Definition.Type.Name(), children(values))
+		}
+	}
+}
+
+// fetchLink resolves the link field of the parent objects, requesting every distinct set of
+// arguments once and batching them in a single request to the GraphQL subgraphs
+func (e *execution) fetchLink(ctx context.Context, l *link, f *ast.Field, parents []map[string]interface{}) {
+	query := e.schema.Query.Fields.ForName(l.Query)
+	var (
+		keys    []string
+		args    = map[string]map[string]interface{}{}
+		parentK = make([]string, len(parents))
+	)
+	for i, p := range parents {
+		a := map[string]interface{}{}
+		for arg, field := range l.Arguments {
+			v, ok := p[keyPrefix+field]
+			if !ok {
+				v = p[field]
+			}
+			a[arg] = v
+		}
+		k := e.arguments(query.Arguments, a)
+		parentK[i] = k
+		if _, ok := args[k]; !ok {
+			args[k] = a
+			keys = append(keys, k)
+		}
+	}
+
+	results := map[string]interface{}{}
+	sg := l.target
+	if sg.Resources != nil {
+		for _, k := range keys {
+			v, err := e.fetchResource(ctx, sg, sg.Resources["Query."+l.Query], args[k])
+			if err != nil {
+				e.addError(sg, err, f.Alias)
+				continue
+			}
+			results[k] = v
+		}
+	} else {
+		sel := e.selection(f.SelectionSet, l.typ.Name(), sg)
+		for start := 0; start < len(keys); start += e.batchSize {
+			end := start + e.batchSize
+			if end > len(keys) {
+				end = len(keys)
+			}
+			var b strings.Builder
+			b.WriteString("query {")
+			for i, k := range keys[start:end] {
+				fmt.Fprintf(&b, " _%d: %s%s%s", i, l.Query, k, sel)
+			}
+			b.WriteString(" }")
+			res, err := e.fetchGraphQL(ctx, sg, b.String())
+			if err != nil {
+				e.addError(sg, err, f.Alias)
+			}
+			for i, k := range keys[start:end] {
+				results[k] = res[fmt.Sprintf("_%d", i)]
+			}
+		}
+	}
+
+	for i, p := range parents {
+		p[f.Alias] = results[parentK[i]]
+	}
+}
+
+func (e *execution) fetchGraphQL(ctx context.Context, sg *subgraph, query string) (map[string]interface{}, error) {
+	body, _ := json.Marshal(Request{Query: query})
+	var res struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []interface{}          `json:"errors"`
+	}
+	if err := e.do(ctx, sg, http.MethodPost, sg.url(""), bytes.NewReader(body), &res); err != nil {
+		return nil, err
+	}
+	if len(res.Errors) > 0 {
+		e.mu.Lock()
+		e.errors = append(e.errors, res.Errors...)
+		e.mu.Unlock()
+	}
+	return res.Data, nil
+}
+
+// fetchResource requests the resource of a REST subgraph with the arguments, returning the target
+// of the response
+func (e *execution) fetchResource(ctx context.Context, sg *subgraph, r Resource, args map[string]interface{}) (interface{}, error) {
+	path := r.URLPattern
+	rest := map[string]interface{}{}
+	for k, v := range args {
+		placeholder := "{" + k + "}"
+		if strings.Contains(path, placeholder) {
+			path = strings.ReplaceAll(path, placeholder, url.PathEscape(fmt.Sprint(v)))
+			continue
+		}
+		if v != nil {
+			rest[k] = v
+		}
+	}
+
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if len(rest) > 0 {
+		if method == http.MethodGet {
+			q := url.Values{}
+			for k, v := range rest {
+				q.Set(k, fmt.Sprint(v))
+			}
+			path += "?" + q.Encode()
+		} else {
+			b, _ := json.Marshal(rest)
+			body = bytes.NewReader(b)
+		}
+	}
+
+	var res interface{}
+	if err := e.do(ctx, sg, method, sg.url(path), body, &res); err != nil {
+		return nil, err
+	}
+	if r.Target == "" {
+		return res, nil
+	}
+	for _, step := range strings.Split(r.Target, ".") {
+		m, ok := res.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		res = m[step]
+	}
+	return res, nil
+}
+
+func (e *execution) do(ctx context.Context, sg *subgraph, method, u string, body io.Reader, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header = e.header.Clone()
+	resp, err := sg.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	d := json.NewDecoder(resp.Body)
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func (e *execution) addError(sg *subgraph, err error, path ...interface{}) {
+	e.l.Warning(e.logPrefix, "Subgraph", sg.Name+":", err.Error())
+	e.mu.Lock()
+	e.errors = append(e.errors, Error{Message: "unable to reach the subgraph " + sg.Name, Path: path})
+	e.mu.Unlock()
+}
+
+// url returns the url of the path (the url pattern of the backend if empty) at the next host
+func (s *subgraph) url(path string) string {
+	if path == "" {
+		path = s.pattern
+	}
+	return s.hosts[int((s.next.Add(1)-1)%uint64(len(s.hosts)))] + path
+}
+
+// writeObject encodes the object with the fields of the selection set, in the order of the query
+func (e *execution) writeObject(b *bytes.Buffer, selSet ast.SelectionSet, typeName string, o map[string]interface{}) {
+	if e.isAbstract(typeName) {
+		if t, ok := o["__typename"].(string); ok {
+			typeName = t
+		}
+	}
+	b.WriteString("{")
+	for i, f := range e.collectFields(selSet, typeName) {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		k, _ := json.Marshal(f.Alias)
+		b.Write(k)
+		b.WriteString(":")
+		if f.Name == "__typename" {
+			t, _ := json.Marshal(typeName)
+			b.Write(t)
+			continue
+		}
+		e.writeValue(b, f, o[f.Alias])
+	}
+	b.WriteString("}")
+}
+
+func (e *execution) writeValue(b *bytes.Buffer, f *ast.Field, v interface{}) {
+	switch x := v.(type) {
+	case []interface{}:
+		b.WriteString("[")
+		for i, el := range x {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			e.writeValue(b, f, el)
+		}
+		b.WriteString("]")
+		return
+	case map[string]interface{}:
+		if len(f.SelectionSet) > 0 && f.Definition != nil {
+			e.writeObject(b, f.SelectionSet, f.Definition.Type.Name(), x)
+			return
+		}
+	}
+	res, _ := json.Marshal(v)
+	b.Write(res)
+}
+
+// children flattens the objects contained in the values, at any level of nested lists
+func children(values []interface{}) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, v := range values {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			res = append(res, x)
+		case []interface{}:
+			res = append(res, children(x)...)
+		}
+	}
+	return res
+}
@@ -0,0 +1,100 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	router "github.com/luraproject/lura/v2/router/gin"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// HandlerFactory checks the configuration and, if required, replaces the handler of the endpoint
+// with the federated one. It should be the innermost layer of the handler factory, so the rest of
+// the middlewares apply before querying the subgraphs. The client certificates of the subgraphs
+// are reloaded until the context is cancelled.
+func HandlerFactory(ctx context.Context, hf router.HandlerFactory, l logging.Logger) router.HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][Federation]"
+
+		fCfg, err := ParseConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			return hf(cfg, p)
+		}
+		if err != nil {
+			l.Error(logPrefix, err.Error())
+			return abort
+		}
+
+		g, err := New(ctx, cfg, fCfg, l)
+		if err != nil {
+			l.Error(logPrefix, "Unable to compose the schema:", err.Error())
+			return abort
+		}
+
+		l.Debug(logPrefix, "Serving the schema composed from", len(g.owners), "root fields")
+		return g.HandlerFunc
+	}
+}
+
+// HandlerFunc decodes the operation from the JSON body of the request, or from the query string
+// of the GET requests, and writes the GraphQL response. The mutations sent with GET are rejected.
+func (g *Gateway) HandlerFunc(c *gin.Context) {
+	var req Request
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if v := c.Query("variables"); v != "" {
+			if err := unmarshal([]byte(v), &req.Variables); err != nil {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+		}
+	} else {
+		d := json.NewDecoder(c.Request.Body)
+		d.UseNumber()
+		if err := d.Decode(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Query == "" {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	// the GET requests can not change anything, as they can be sent cross-site
+	if c.Request.Method == http.MethodGet && isMutation(req) {
+		c.Header("Allow", http.MethodPost)
+		c.AbortWithStatus(http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", g.Execute(c.Request.Context(), req, c.Request.Header))
+}
+
+// isMutation reports if the operation to execute is a mutation. The invalid queries are reported
+// when executed.
+func isMutation(req Request) bool {
+	doc, err := parser.ParseQuery(&ast.Source{Input: req.Query})
+	if err != nil {
+		return false
+	}
+	op := doc.Operations.ForName(req.OperationName)
+	return op != nil && op.Operation == ast.Mutation
+}
+
+func unmarshal(b []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func abort(c *gin.Context) {
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
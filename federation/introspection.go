@@ -0,0 +1,295 @@
+package federation
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// typeRef is a type of the introspection: a named type, or a list or non null wrapper of another
+type typeRef struct {
+	kind   string
+	def    *ast.Definition
+	ofType *typeRef
+}
+
+// introspect resolves the __schema and __type root fields against the federated schema. The
+// values are keyed by the alias of the fields, as the responses of the subgraphs.
+func (e *execution) introspect(f *ast.Field) interface{} {
+	if f.Name == "__type" {
+		name, _ := f.ArgumentMap(e.vars)["name"].(string)
+		def := e.schema.Types[name]
+		if def == nil {
+			return nil
+		}
+		return e.introspectType(f.SelectionSet, &typeRef{kind: string(def.Kind), def: def})
+	}
+
+	return e.object(f.SelectionSet, "__Schema", func(f *ast.Field) interface{} {
+		switch f.Name {
+		case "description":
+			return nullable(e.schema.Description)
+		case "types":
+			names := make([]string, 0, len(e.schema.Types))
+			for name := range e.schema.Types {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			res := make([]interface{}, len(names))
+			for i, name := range names {
+				def := e.schema.Types[name]
+				res[i] = e.introspectType(f.SelectionSet, &typeRef{kind: string(def.Kind), def: def})
+			}
+			return res
+		case "queryType":
+			return e.introspectDefinition(f.SelectionSet, e.schema.Query)
+		case "mutationType":
+			return e.introspectDefinition(f.SelectionSet, e.schema.Mutation)
+		case "directives":
+			names := make([]string, 0, len(e.schema.Directives))
+			for name := range e.schema.Directives {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			res := make([]interface{}, len(names))
+			for i, name := range names {
+				res[i] = e.introspectDirective(f.SelectionSet, e.schema.Directives[name])
+			}
+			return res
+		}
+		return nil
+	})
+}
+
+func (e *execution) introspectDefinition(selSet ast.SelectionSet, def *ast.Definition) interface{} {
+	if def == nil {
+		return nil
+	}
+	return e.introspectType(selSet, &typeRef{kind: string(def.Kind), def: def})
+}
+
+func (e *execution) introspectType(selSet ast.SelectionSet, t *typeRef) interface{} {
+	if t == nil {
+		return nil
+	}
+	return e.object(selSet, "__Type", func(f *ast.Field) interface{} {
+		if f.Name == "kind" {
+			return t.kind
+		}
+		if f.Name == "ofType" {
+			return e.introspectType(f.SelectionSet, t.ofType)
+		}
+		if t.def == nil {
+			return nil
+		}
+		includeDeprecated, _ := f.ArgumentMap(e.vars)["includeDeprecated"].(bool)
+		switch f.Name {
+		case "name":
+			return t.def.Name
+		case "description":
+			return nullable(t.def.Description)
+		case "fields":
+			if t.def.Kind != ast.Object && t.def.Kind != ast.Interface {
+				return nil
+			}
+			res := []interface{}{}
+			for _, fd := range t.def.Fields {
+				if strings.HasPrefix(fd.Name, "__") || (!includeDeprecated && deprecated(fd.Directives)) {
+					continue
+				}
+				res = append(res, e.introspectField(f.SelectionSet, fd))
+			}
+			return res
+		case "interfaces":
+			if t.def.Kind != ast.Object && t.def.Kind != ast.Interface {
+				return nil
+			}
+			res := []interface{}{}
+			for _, name := range t.def.Interfaces {
+				res = append(res, e.introspectDefinition(f.SelectionSet, e.schema.Types[name]))
+			}
+			return res
+		case "possibleTypes":
+			if !t.def.IsAbstractType() {
+				return nil
+			}
+			res := []interface{}{}
+			for _, def := range e.schema.GetPossibleTypes(t.def) {
+				res = append(res, e.introspectDefinition(f.SelectionSet, def))
+			}
+			return res
+		case "enumValues":
+			if t.def.Kind != ast.Enum {
+				return nil
+			}
+			res := []interface{}{}
+			for _, v := range t.def.EnumValues {
+				if !includeDeprecated && deprecated(v.Directives) {
+					continue
+				}
+				res = append(res, e.object(f.SelectionSet, "__EnumValue", func(f *ast.Field) interface{} {
+					return enumValueField(f.Name, v)
+				}))
+			}
+			return res
+		case "inputFields":
+			if t.def.Kind != ast.InputObject {
+				return nil
+			}
+			res := []interface{}{}
+			for _, fd := range t.def.Fields {
+				if !includeDeprecated && deprecated(fd.Directives) {
+					continue
+				}
+				res = append(res, e.introspectInputValue(f.SelectionSet, fd.Name, fd.Description, fd.Type, fd.DefaultValue, fd.Directives))
+			}
+			return res
+		case "isOneOf":
+			return t.def.Kind == ast.InputObject && t.def.Directives.ForName("oneOf") != nil
+		}
+		return nil
+	})
+}
+
+func (e *execution) introspectField(selSet ast.SelectionSet, fd *ast.FieldDefinition) interface{} {
+	return e.object(selSet, "__Field", func(f *ast.Field) interface{} {
+		switch f.Name {
+		case "name":
+			return fd.Name
+		case "description":
+			return nullable(fd.Description)
+		case "args":
+			includeDeprecated, _ := f.ArgumentMap(e.vars)["includeDeprecated"].(bool)
+			res := []interface{}{}
+			for _, a := range fd.Arguments {
+				if !includeDeprecated && deprecated(a.Directives) {
+					continue
+				}
+				res = append(res, e.introspectInputValue(f.SelectionSet, a.Name, a.Description, a.Type, a.DefaultValue, a.Directives))
+			}
+			return res
+		case "type":
+			return e.introspectType(f.SelectionSet, e.typeRef(fd.Type))
+		case "isDeprecated":
+			return deprecated(fd.Directives)
+		case "deprecationReason":
+			return deprecationReason(fd.Directives)
+		}
+		return nil
+	})
+}
+
+func (e *execution) introspectInputValue(selSet ast.SelectionSet, name, description string, t *ast.Type, defaultValue *ast.Value, directives ast.DirectiveList) interface{} {
+	return e.object(selSet, "__InputValue", func(f *ast.Field) interface{} {
+		switch f.Name {
+		case "name":
+			return name
+		case "description":
+			return nullable(description)
+		case "type":
+			return e.introspectType(f.SelectionSet, e.typeRef(t))
+		case "defaultValue":
+			if defaultValue == nil {
+				return nil
+			}
+			return defaultValue.String()
+		case "isDeprecated":
+			return deprecated(directives)
+		case "deprecationReason":
+			return deprecationReason(directives)
+		}
+		return nil
+	})
+}
+
+func (e *execution) introspectDirective(selSet ast.SelectionSet, d *ast.DirectiveDefinition) interface{} {
+	return e.object(selSet, "__Directive", func(f *ast.Field) interface{} {
+		switch f.Name {
+		case "name":
+			return d.Name
+		case "description":
+			return nullable(d.Description)
+		case "locations":
+			res := make([]interface{}, len(d.Locations))
+			for i, l := range d.Locations {
+				res[i] = string(l)
+			}
+			return res
+		case "args":
+			res := []interface{}{}
+			for _, a := range d.Arguments {
+				res = append(res, e.introspectInputValue(f.SelectionSet, a.Name, a.Description, a.Type, a.DefaultValue, a.Directives))
+			}
+			return res
+		case "isRepeatable":
+			return d.IsRepeatable
+		}
+		return nil
+	})
+}
+
+// object resolves the fields of the selection set for an introspection type
+func (e *execution) object(selSet ast.SelectionSet, typeName string, field func(*ast.Field) interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	for _, f := range e.collectFields(selSet, typeName) {
+		if f.Name != "__typename" {
+			res[f.Alias] = field(f)
+		}
+	}
+	return res
+}
+
+// typeRef returns the introspection type of a field or an argument, unwrapping the lists and the
+// non null types
+func (e *execution) typeRef(t *ast.Type) *typeRef {
+	if t.NonNull {
+		nullable := *t
+		nullable.NonNull = false
+		return &typeRef{kind: "NON_NULL", ofType: e.typeRef(&nullable)}
+	}
+	if t.Elem != nil {
+		return &typeRef{kind: "LIST", ofType: e.typeRef(t.Elem)}
+	}
+	def := e.schema.Types[t.NamedType]
+	if def == nil {
+		return nil
+	}
+	return &typeRef{kind: string(def.Kind), def: def}
+}
+
+func enumValueField(name string, v *ast.EnumValueDefinition) interface{} {
+	switch name {
+	case "name":
+		return v.Name
+	case "description":
+		return nullable(v.Description)
+	case "isDeprecated":
+		return deprecated(v.Directives)
+	case "deprecationReason":
+		return deprecationReason(v.Directives)
+	}
+	return nil
+}
+
+func deprecated(directives ast.DirectiveList) bool {
+	return directives.ForName("deprecated") != nil
+}
+
+func deprecationReason(directives ast.DirectiveList) interface{} {
+	d := directives.ForName("deprecated")
+	if d == nil {
+		return nil
+	}
+	if a := d.Arguments.ForName("reason"); a != nil && a.Value != nil {
+		return a.Value.Raw
+	}
+	return "No longer supported"
+}
+
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
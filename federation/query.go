@@ -0,0 +1,236 @@
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// keyPrefix is the alias of the fields requested to the subgraphs for resolving the links
+const keyPrefix = "_fed_"
+
+// collectFields flattens the selection set for an object of the type, expanding the fragments,
+// applying the @skip and @include directives and merging the fields with the same response key
+func (e *execution) collectFields(selSet ast.SelectionSet, typeName string) []*ast.Field {
+	var (
+		res   []*ast.Field
+		index = map[string]int{}
+	)
+	var collect func(ast.SelectionSet)
+	collect = func(ss ast.SelectionSet) {
+		for _, s := range ss {
+			switch s := s.(type) {
+			case *ast.Field:
+				if !e.included(s.Directives) {
+					continue
+				}
+				i, ok := index[s.Alias]
+				if !ok {
+					index[s.Alias] = len(res)
+					res = append(res, s)
+					continue
+				}
+				merged := *res[i]
+				merged.SelectionSet = append(append(ast.SelectionSet{}, merged.SelectionSet...), s.SelectionSet...)
+				res[i] = &merged
+			case *ast.InlineFragment:
+				if e.included(s.Directives) && e.applies(s.TypeCondition, typeName) {
+					collect(s.SelectionSet)
+				}
+			case *ast.FragmentSpread:
+				if e.included(s.Directives) && s.Definition != nil && e.applies(s.Definition.TypeCondition, typeName) {
+					collect(s.Definition.SelectionSet)
+				}
+			}
+		}
+	}
+	collect(selSet)
+	return res
+}
+
+func (e *execution) included(directives ast.DirectiveList) bool {
+	if d := directives.ForName("skip"); d != nil {
+		if v, _ := d.ArgumentMap(e.vars)["if"].(bool); v {
+			return false
+		}
+	}
+	if d := directives.ForName("include"); d != nil {
+		if v, _ := d.ArgumentMap(e.vars)["if"].(bool); !v {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *execution) applies(condition, typeName string) bool {
+	if condition == "" || condition == typeName {
+		return true
+	}
+	def, ok := e.schema.Types[condition]
+	if !ok {
+		return false
+	}
+	for _, t := range e.schema.GetPossibleTypes(def) {
+		if t.Name == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+// link returns the link adding the field to the type or to one of its interfaces, if any
+func (e *execution) link(typeName, field string) *link {
+	if l, ok := e.links[typeName+"."+field]; ok {
+		return l
+	}
+	if def, ok := e.schema.Types[typeName]; ok {
+		for _, i := range def.Interfaces {
+			if l, ok := e.links[i+"."+field]; ok {
+				return l
+			}
+		}
+	}
+	return nil
+}
+
+func (e *execution) isAbstract(typeName string) bool {
+	def, ok := e.schema.Types[typeName]
+	return ok && def.IsAbstractType()
+}
+
+// selection serializes the selection set for an object of the type returned by the subgraph. The
+// links are replaced by the fields they take their arguments from, and the abstract types
+// request the __typename and the fields of every possible type known by the subgraph.
+func (e *execution) selection(selSet ast.SelectionSet, typeName string, sg *subgraph) string {
+	if len(selSet) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(" {")
+	if !e.isAbstract(typeName) {
+		e.writeFields(&b, selSet, typeName, sg)
+	} else {
+		b.WriteString(" __typename")
+		for _, t := range e.schema.GetPossibleTypes(e.schema.Types[typeName]) {
+			if !sg.types[t.Name] {
+				continue
+			}
+			b.WriteString(" ... on " + t.Name + " {")
+			e.writeFields(&b, selSet, t.Name, sg)
+			b.WriteString(" }")
+		}
+	}
+	b.WriteString(" }")
+	return b.String()
+}
+
+func (e *execution) writeFields(b *strings.Builder, selSet ast.SelectionSet, typeName string, sg *subgraph) {
+	b.WriteString(" __typename")
+	for _, f := range e.collectFields(selSet, typeName) {
+		if f.Name == "__typename" {
+			continue
+		}
+		if l := e.link(typeName, f.Name); l != nil {
+			for _, field := range sortedValues(l.Arguments) {
+				b.WriteString(" " + keyPrefix + field + ": " + field)
+			}
+			continue
+		}
+		b.WriteString(" " + f.Alias + ": " + f.Name)
+		b.WriteString(e.arguments(f.Definition.Arguments, f.ArgumentMap(e.vars)))
+		b.WriteString(e.selection(f.SelectionSet, f.Definition.Type.Name(), sg))
+	}
+}
+
+// arguments serializes the argument values as literals
+func (e *execution) arguments(defs ast.ArgumentDefinitionList, args map[string]interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		var t *ast.Type
+		if d := defs.ForName(name); d != nil {
+			t = d.Type
+		}
+		parts = append(parts, name+": "+e.literal(args[name], t))
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// literal serializes the value as a GraphQL literal of the type
+func (e *execution) literal(v interface{}, t *ast.Type) string {
+	if v == nil {
+		return "null"
+	}
+	if l, ok := v.([]interface{}); ok {
+		var elem *ast.Type
+		if t != nil {
+			elem = t.Elem
+		}
+		parts := make([]string, len(l))
+		for i, x := range l {
+			parts[i] = e.literal(x, elem)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	if t != nil && t.Elem != nil {
+		return e.literal(v, t.Elem)
+	}
+
+	var def *ast.Definition
+	if t != nil {
+		def = e.schema.Types[t.NamedType]
+	}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			var ft *ast.Type
+			if def != nil {
+				if f := def.Fields.ForName(k); f != nil {
+					ft = f.Type
+				}
+			}
+			parts[i] = k + ": " + e.literal(x[k], ft)
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case string:
+		if def != nil && def.Kind == ast.Enum {
+			return x
+		}
+		b, _ := json.Marshal(x)
+		return string(b)
+	case json.Number, bool, int, int32, int64, float32, float64:
+		return fmt.Sprint(x)
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+func sortedValues(m map[string]string) []string {
+	res := make([]string, 0, len(m))
+	seen := map[string]bool{}
+	for _, v := range m {
+		if !seen[v] {
+			seen[v] = true
+			res = append(res, v)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,153 @@
+package federation
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// link is a Link bound to its subgraph and the type of the query resolving it
+type link struct {
+	Link
+	target *subgraph
+	typ    *ast.Type
+}
+
+// composition is the federated schema with the owner of every root field and the links
+type composition struct {
+	schema *ast.Schema
+	owners map[string]*subgraph
+	links  map[string]*link
+}
+
+// compose merges the schemas of the subgraphs. The types declared by several subgraphs get the
+// fields of all of them, but every root field must be declared by a single subgraph.
+func compose(subgraphs []*subgraph, links []Link) (*composition, error) {
+	c := &composition{
+		owners: map[string]*subgraph{},
+		links:  map[string]*link{},
+	}
+	defs := map[string]*ast.Definition{}
+	doc := &ast.SchemaDocument{}
+	directives := map[string]struct{}{}
+
+	for _, sg := range subgraphs {
+		sd, err := parser.ParseSchema(&ast.Source{Name: sg.Name, Input: sg.Schema})
+		if err != nil {
+			return nil, fmt.Errorf("subgraph %s: %w", sg.Name, err)
+		}
+		if len(sd.Schema) > 0 || len(sd.SchemaExtension) > 0 {
+			return nil, fmt.Errorf("subgraph %s: custom root operation types are not supported", sg.Name)
+		}
+		sg.types = map[string]bool{}
+		for _, d := range sd.Definitions {
+			sg.types[d.Name] = true
+		}
+		for _, d := range sd.Directives {
+			if _, ok := directives[d.Name]; !ok {
+				directives[d.Name] = struct{}{}
+				doc.Directives = append(doc.Directives, d)
+			}
+		}
+
+		for _, def := range append(sd.Definitions, sd.Extensions...) {
+			if def.Name == "Subscription" {
+				return nil, fmt.Errorf("subgraph %s: subscriptions are not supported", sg.Name)
+			}
+			merged, ok := defs[def.Name]
+			if !ok {
+				d := *def
+				d.Fields, d.EnumValues, d.Types, d.Interfaces = nil, nil, nil, nil
+				merged = &d
+				defs[def.Name] = merged
+				doc.Definitions = append(doc.Definitions, merged)
+			}
+			mergeDefinition(merged, def)
+
+			if def.Name != "Query" && def.Name != "Mutation" {
+				continue
+			}
+			for _, f := range def.Fields {
+				key := def.Name + "." + f.Name
+				if owner, ok := c.owners[key]; ok && owner != sg {
+					return nil, fmt.Errorf("%s is declared by the subgraphs %s and %s", key, owner.Name, sg.Name)
+				}
+				c.owners[key] = sg
+			}
+		}
+	}
+
+	for _, l := range links {
+		key := "Query." + l.Query
+		target := c.owners[key]
+		if target == nil || target.Name != l.Subgraph {
+			return nil, fmt.Errorf("link %s.%s: %s is not declared by the subgraph %s", l.Type, l.Field, key, l.Subgraph)
+		}
+		parent, ok := defs[l.Type]
+		if !ok || (parent.Kind != ast.Object && parent.Kind != ast.Interface) {
+			return nil, fmt.Errorf("link %s.%s: unknown object type %s", l.Type, l.Field, l.Type)
+		}
+		if parent.Fields.ForName(l.Field) != nil {
+			return nil, fmt.Errorf("link %s.%s: the field is already declared", l.Type, l.Field)
+		}
+		query := defs["Query"].Fields.ForName(l.Query)
+		for arg, field := range l.Arguments {
+			if query.Arguments.ForName(arg) == nil {
+				return nil, fmt.Errorf("link %s.%s: unknown argument %s of %s", l.Type, l.Field, arg, key)
+			}
+			if parent.Fields.ForName(field) == nil {
+				return nil, fmt.Errorf("link %s.%s: unknown field %s", l.Type, l.Field, field)
+			}
+		}
+		parent.Fields = append(parent.Fields, &ast.FieldDefinition{Name: l.Field, Type: query.Type})
+		c.links[l.Type+"."+l.Field] = &link{Link: l, target: target, typ: query.Type}
+	}
+
+	var buf bytes.Buffer
+	formatter.NewFormatter(&buf).FormatSchemaDocument(doc)
+	schema, err := gqlparser.LoadSchema(&ast.Source{Name: Namespace, Input: buf.String()})
+	if err != nil {
+		return nil, err
+	}
+	c.schema = schema
+	return c, nil
+}
+
+// mergeDefinition adds the fields, values, members and interfaces of the def missing at the
+// merged definition
+func mergeDefinition(merged, def *ast.Definition) {
+	for _, f := range def.Fields {
+		if strings.HasPrefix(f.Name, "__") || merged.Fields.ForName(f.Name) != nil {
+			continue
+		}
+		merged.Fields = append(merged.Fields, f)
+	}
+	for _, v := range def.EnumValues {
+		if merged.EnumValues.ForName(v.Name) == nil {
+			merged.EnumValues = append(merged.EnumValues, v)
+		}
+	}
+	merged.Types = appendMissing(merged.Types, def.Types)
+	merged.Interfaces = appendMissing(merged.Interfaces, def.Interfaces)
+}
+
+func appendMissing(dst, src []string) []string {
+	for _, s := range src {
+		found := false
+		for _, d := range dst {
+			if d == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, s)
+		}
+	}
+	return dst
+}
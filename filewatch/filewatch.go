@@ -0,0 +1,84 @@
+// Package filewatch notifies the changes of a set of files, so the components loading them can
+// reload their contents without restarting the service.
+package filewatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// debounce is the time to wait for more events before notifying a change, so the files replaced
+// in several steps (write + chmod or rename + create) trigger a single reload
+const debounce = 100 * time.Millisecond
+
+// Watch calls onChange every time any of the received files (or the files inside the received
+// directories) is created, written, renamed or removed, until the context is cancelled.
+// The parent directories are watched instead of the files themselves, so atomic replacements
+// and symlink swaps (like the ones done by kubernetes when updating a secret) are detected.
+func Watch(ctx context.Context, l logging.Logger, paths []string, onChange func()) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	watched := map[string]struct{}{}
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			w.Close()
+			return err
+		}
+		dir := filepath.Dir(abs)
+		if isDir(abs) {
+			dir = abs
+		}
+		if _, ok := watched[dir]; ok {
+			continue
+		}
+		if err := w.Add(dir); err != nil {
+			w.Close()
+			return err
+		}
+		watched[dir] = struct{}{}
+	}
+
+	go func() {
+		defer w.Close()
+
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if e.Has(fsnotify.Chmod) && !e.Has(fsnotify.Write) {
+					continue
+				}
+				timer = time.After(debounce)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				l.Warning("[SERVICE: File watcher]", err.Error())
+			case <-timer:
+				timer = nil
+				onChange()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
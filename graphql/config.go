@@ -0,0 +1,127 @@
+// Package graphql protects the GraphQL backends against expensive operations. The operations the
+// clients send to the endpoints with a backend declared with the backend/graphql namespace are
+// parsed and rejected when they exceed the max depth, complexity or number of aliases, and the
+// persisted queries let the clients send the hash of a known query (automatic persisted queries)
+// or restrict the backend to the queries of an allowlist.
+//
+// The operations are checked at the endpoint, before the proxy stack builds the requests to the
+// backends. Only the backends in pass-through mode, the ones without a type of operation, receive
+// the operation of the client: when the backend declares its own query, the client can not choose
+// the operation and the protections do not apply.
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/luraproject/lura/v2/config"
+	luragraphql "github.com/luraproject/lura/v2/transport/http/client/graphql"
+)
+
+// Namespace is the key of the GraphQL backend config at the backend ExtraConfig. The protections
+// are declared next to the options of the backend. Once parsed, the config is stored under the
+// namespace of lura, as backend/graphql is an alias of it.
+const Namespace = "backend/graphql"
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// ErrStaticOperation is returned when the backend with protections declares its own operation
+var ErrStaticOperation = errors.New("the protections require the pass-through mode, but the backend declares its own operation")
+
+var defaultListArguments = []string{"first", "last", "limit"}
+
+// Config defines the protections of a GraphQL backend
+type Config struct {
+	Limits           *Limits           `json:"limits"`
+	PersistedQueries *PersistedQueries `json:"persisted_queries"`
+	// Type is the operation declared by the backend, if any. The backends with an operation
+	// are not in pass-through mode.
+	Type string `json:"type"`
+}
+
+// Limits defines the max cost of the operations. The zero values disable the limit.
+type Limits struct {
+	// MaxDepth is the max number of nested selection sets
+	MaxDepth int `json:"max_depth"`
+	// MaxComplexity is the max number of fields requested. The cost of the fields with a list
+	// argument is multiplied by its value.
+	MaxComplexity int `json:"max_complexity"`
+	// MaxAliases is the max number of aliased fields
+	MaxAliases int `json:"max_aliases"`
+	// ListArguments are the arguments limiting the size of the lists. Default: first, last and
+	// limit
+	ListArguments []string `json:"list_arguments"`
+}
+
+// PersistedQueries defines the queries the operations can refer to by their SHA-256 hash
+type PersistedQueries struct {
+	// File is a JSON object with the queries by their hex encoded SHA-256 hash
+	File string `json:"file"`
+	// OnlyPersisted rejects the operations not found in the file
+	OnlyPersisted bool `json:"only_persisted"`
+
+	queries map[string]string
+}
+
+// ParseConfig extracts the module config from the ExtraConfig, loading the persisted queries
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[luragraphql.Namespace]
+	if !ok {
+		if e, ok = cfg[Namespace]; !ok {
+			return res, ErrNoConfig
+		}
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if res.Limits == nil && res.PersistedQueries == nil {
+		return res, ErrNoConfig
+	}
+	if res.Type != "" {
+		return res, ErrStaticOperation
+	}
+	if res.Limits != nil && len(res.Limits.ListArguments) == 0 {
+		res.Limits.ListArguments = defaultListArguments
+	}
+	if pq := res.PersistedQueries; pq != nil {
+		if pq.File == "" {
+			return res, errors.New("the persisted queries require a file")
+		}
+		if pq.queries, err = loadQueries(pq.File); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func loadQueries(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var queries map[string]string
+	if err := json.Unmarshal(b, &queries); err != nil {
+		return nil, err
+	}
+	for hash, q := range queries {
+		if hashQuery(q) != hash {
+			return nil, fmt.Errorf("the hash %s of the persisted queries does not match its query", hash)
+		}
+	}
+	return queries, nil
+}
+
+func hashQuery(q string) string {
+	sum := sha256.Sum256([]byte(q))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// cost is the cost of an operation
+type cost struct {
+	depth      int
+	complexity int
+	aliases    int
+}
+
+// analyzer computes the cost of the operations of a query document. The document is not
+// validated against a schema, so the fragments are looked up by name.
+type analyzer struct {
+	doc           *ast.QueryDocument
+	vars          map[string]interface{}
+	listArguments []string
+}
+
+func (a *analyzer) cost(op *ast.OperationDefinition) cost {
+	c := cost{}
+	c.complexity = a.walk(op.SelectionSet, 1, map[string]bool{}, &c)
+	return c
+}
+
+// walk returns the complexity of the selection set at the depth, updating the max depth and the
+// number of aliases
+func (a *analyzer) walk(selSet ast.SelectionSet, depth int, fragments map[string]bool, c *cost) int {
+	total := 0
+	for _, s := range selSet {
+		switch s := s.(type) {
+		case *ast.Field:
+			if s.Alias != s.Name {
+				c.aliases++
+			}
+			if s.Name == "__typename" {
+				continue
+			}
+			if depth > c.depth {
+				c.depth = depth
+			}
+			children := a.walk(s.SelectionSet, depth+1, fragments, c)
+			total = saturate(int64(total) + 1 + int64(a.multiplier(s))*int64(children))
+		case *ast.InlineFragment:
+			total = saturate(int64(total) + int64(a.walk(s.SelectionSet, depth, fragments, c)))
+		case *ast.FragmentSpread:
+			// the cycles are invalid, but the backend is not reached before validating them
+			if fragments[s.Name] {
+				continue
+			}
+			f := a.doc.Fragments.ForName(s.Name)
+			if f == nil {
+				continue
+			}
+			fragments[s.Name] = true
+			total = saturate(int64(total) + int64(a.walk(f.SelectionSet, depth, fragments, c)))
+			delete(fragments, s.Name)
+		}
+	}
+	return total
+}
+
+// multiplier returns the value of the first list argument of the field, or 1
+func (a *analyzer) multiplier(f *ast.Field) int {
+	for _, name := range a.listArguments {
+		arg := f.Arguments.ForName(name)
+		if arg == nil {
+			continue
+		}
+		v, err := arg.Value.Value(a.vars)
+		if err != nil {
+			continue
+		}
+		if n, ok := toInt(v); ok && n > 0 {
+			return n
+		}
+	}
+	return 1
+}
+
+func toInt(v interface{}) (int, bool) {
+	var s string
+	switch x := v.(type) {
+	case int64:
+		return saturate(x), true
+	case int:
+		return x, true
+	case float64:
+		return saturate(int64(x)), true
+	case json.Number:
+		s = x.String()
+	case string:
+		s = x
+	default:
+		s = fmt.Sprint(x)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return saturate(n), err == nil
+}
+
+func saturate(n int64) int {
+	if n > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(n)
+}
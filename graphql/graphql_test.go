@@ -0,0 +1,204 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	luragraphql "github.com/luraproject/lura/v2/transport/http/client/graphql"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+const persisted = `{ user(id: 1) { name } }`
+
+func TestProxyFactory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "queries.json")
+	b, _ := json.Marshal(map[string]string{hashQuery(persisted): persisted})
+	if err := os.WriteFile(file, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent string
+	bf := func(*config.Backend) proxy.Proxy {
+		return func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+			if r.Body != nil {
+				b, _ := io.ReadAll(r.Body)
+				sent = string(b)
+			} else {
+				sent = r.Query.Get("query")
+			}
+			return &proxy.Response{Data: map[string]interface{}{"data": "ok"}, IsComplete: true}, nil
+		}
+	}
+
+	limits := map[string]interface{}{"max_depth": 3, "max_complexity": 20, "max_aliases": 1}
+	for _, tc := range []struct {
+		name     string
+		cfg      map[string]interface{}
+		body     string
+		query    url.Values
+		expected string
+		sent     string
+		reason   string
+	}{
+		{
+			name:     "allowed",
+			cfg:      map[string]interface{}{"limits": limits},
+			body:     `{"query":"query Q($n: Int) { users(first: $n) { id friends { name } } }","variables":{"n":5}}`,
+			sent:     `{"query":"query Q($n: Int) { users(first: $n) { id friends { name } } }","variables":{"n":5}}`,
+			expected: "ok",
+		},
+		{
+			name:     "too deep",
+			cfg:      map[string]interface{}{"limits": limits},
+			body:     `{"query":"{ a { b { c { d } } } }"}`,
+			expected: "the query depth 4 exceeds the max depth 3",
+			reason:   "depth",
+		},
+		{
+			name:     "too complex",
+			cfg:      map[string]interface{}{"limits": limits},
+			body:     `{"query":"query Q($n: Int) { users(first: $n) { ...F } } fragment F on User { id name }","variables":{"n":10}}`,
+			expected: "the query complexity 21 exceeds the max complexity 20",
+			reason:   "complexity",
+		},
+		{
+			name:     "too many aliases",
+			cfg:      map[string]interface{}{"limits": limits},
+			query:    url.Values{"query": {"{ a: user { id } b: user { id } }"}},
+			expected: "the query has 2 aliases, exceeding the max of 1",
+			reason:   "aliases",
+		},
+		{
+			name:     "invalid query",
+			cfg:      map[string]interface{}{"limits": limits},
+			body:     `{"query":"{ user "}`,
+			expected: "GRAPHQL_PARSE_FAILED",
+			reason:   "parse",
+		},
+		{
+			name:     "persisted query by hash",
+			cfg:      map[string]interface{}{"persisted_queries": map[string]interface{}{"file": file}},
+			body:     `{"variables":{},"extensions":{"persistedQuery":{"version":1,"sha256Hash":"` + hashQuery(persisted) + `"}}}`,
+			sent:     `{"query":"{ user(id: 1) { name } }","variables":{}}`,
+			expected: "ok",
+		},
+		{
+			name:     "unknown hash",
+			cfg:      map[string]interface{}{"persisted_queries": map[string]interface{}{"file": file}},
+			query:    url.Values{"extensions": {`{"persistedQuery":{"version":1,"sha256Hash":"abc"}}`}},
+			expected: "PERSISTED_QUERY_NOT_FOUND",
+			reason:   "persisted_query",
+		},
+		{
+			name:     "not persisted",
+			cfg:      map[string]interface{}{"persisted_queries": map[string]interface{}{"file": file, "only_persisted": true}},
+			body:     `{"query":"{ user(id: 2) { name } }"}`,
+			expected: "PERSISTED_QUERY_NOT_SUPPORTED",
+			reason:   "persisted_query",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sent = ""
+			registry := gometrics.NewRegistry()
+			p, err := ProxyFactory(logging.NoOp, registry, proxy.NewDefaultFactory(bf, logging.NoOp)).New(endpoint(tc.cfg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &proxy.Request{Method: "POST", Query: tc.query, Params: map[string]string{}, Headers: map[string][]string{}}
+			if tc.body != "" {
+				r.Body = io.NopCloser(strings.NewReader(tc.body))
+			}
+			resp, err := p(context.Background(), r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := json.Marshal(resp.Data)
+			if !strings.Contains(string(b), tc.expected) {
+				t.Errorf("unexpected response: %s", b)
+			}
+			if sent != tc.sent {
+				t.Errorf("unexpected request sent to the backend: %s", sent)
+			}
+			if tc.reason == "" {
+				return
+			}
+			if c := gometrics.GetOrRegisterCounter("graphql./graphql.rejected."+tc.reason, registry); c.Count() != 1 {
+				t.Errorf("unexpected number of rejections: %d", c.Count())
+			}
+		})
+	}
+}
+
+func TestProxyFactory_staticOperation(t *testing.T) {
+	var sent string
+	bf := func(*config.Backend) proxy.Proxy {
+		return func(_ context.Context, r *proxy.Request) (*proxy.Response, error) {
+			b, _ := io.ReadAll(r.Body)
+			sent = string(b)
+			return &proxy.Response{Data: map[string]interface{}{"data": "ok"}, IsComplete: true}, nil
+		}
+	}
+
+	// the backend declares its own query, so the operation of the client never reaches it
+	cfg := endpoint(map[string]interface{}{
+		"type":   "query",
+		"query":  "{ a { b { c { d } } } }",
+		"limits": map[string]interface{}{"max_depth": 1},
+	})
+	p, err := ProxyFactory(logging.NoOp, nil, proxy.NewDefaultFactory(bf, logging.NoOp)).New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &proxy.Request{
+		Method:  "POST",
+		Body:    io.NopCloser(strings.NewReader(`{"query":"{ x { y { z } } }"}`)),
+		Params:  map[string]string{},
+		Headers: map[string][]string{},
+	}
+	resp, err := p(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["data"] != "ok" {
+		t.Errorf("unexpected response: %v", resp.Data)
+	}
+	if sent != `{"query":"{ a { b { c { d } } } }"}` {
+		t.Errorf("unexpected request sent to the backend: %s", sent)
+	}
+}
+
+func TestProxyFactory_wrongConfig(t *testing.T) {
+	cfg := endpoint(map[string]interface{}{"persisted_queries": map[string]interface{}{}})
+	bf := func(*config.Backend) proxy.Proxy { return proxy.NoopProxy }
+	if _, err := ProxyFactory(logging.NoOp, nil, proxy.NewDefaultFactory(bf, logging.NoOp)).New(cfg); err == nil {
+		t.Error("error expected")
+	}
+}
+
+// endpoint returns an endpoint with a GraphQL backend, as parsed by the config of lura
+func endpoint(gCfg map[string]interface{}) *config.EndpointConfig {
+	return &config.EndpointConfig{
+		Endpoint: "/graphql",
+		Method:   "POST",
+		Timeout:  time.Second,
+		Backend: []*config.Backend{
+			{
+				URLPattern:     "/graphql",
+				Host:           []string{"http://graphql.example.com"},
+				Method:         "POST",
+				ParentEndpoint: "/graphql",
+				ExtraConfig:    config.ExtraConfig{luragraphql.Namespace: gCfg},
+			},
+		},
+	}
+}
@@ -0,0 +1,254 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// the codes of the errors, as used by the most common GraphQL servers
+const (
+	codeParseFailed           = "GRAPHQL_PARSE_FAILED"
+	codeValidationFailed      = "GRAPHQL_VALIDATION_FAILED"
+	codePersistedNotFound     = "PERSISTED_QUERY_NOT_FOUND"
+	codePersistedNotSupported = "PERSISTED_QUERY_NOT_SUPPORTED"
+)
+
+// rejection is an operation not sent to the backend
+type rejection struct {
+	reason  string
+	code    string
+	message string
+}
+
+// ProxyFactory checks the GraphQL operations of the clients before the proxy stack sends them to
+// the backends with limits or persisted queries. The rejected operations get a response with the
+// GraphQL errors instead of reaching the backends, and they are counted in the registry, if any,
+// as graphql.<endpoint>.rejected.<reason>.
+func ProxyFactory(l logging.Logger, registry gometrics.Registry, next proxy.Factory) proxy.Factory {
+	return proxy.FactoryFunc(func(cfg *config.EndpointConfig) (proxy.Proxy, error) {
+		p, err := next.New(cfg)
+		if err != nil {
+			return p, err
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][GraphQL]"
+		for _, remote := range cfg.Backend {
+			gCfg, err := ParseConfig(remote.ExtraConfig)
+			switch err {
+			case nil:
+			case ErrNoConfig:
+				continue
+			case ErrStaticOperation:
+				l.Warning(logPrefix, "Ignoring the protections of the backend", remote.URLPattern+":", err.Error())
+				continue
+			default:
+				l.Error(logPrefix, err.Error())
+				return nil, err
+			}
+
+			l.Debug(logPrefix, "Checking the GraphQL operations sent to", remote.URLPattern)
+			g := &guard{
+				cfg:       gCfg,
+				next:      p,
+				prefix:    "graphql." + cfg.Endpoint + ".rejected.",
+				registry:  registry,
+				l:         l,
+				logPrefix: logPrefix,
+			}
+			p = g.proxy
+		}
+		return p, nil
+	})
+}
+
+type guard struct {
+	cfg       Config
+	next      proxy.Proxy
+	prefix    string
+	registry  gometrics.Registry
+	l         logging.Logger
+	logPrefix string
+}
+
+// operation is a GraphQL request, as sent by POST or GET
+type operation struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    struct {
+		PersistedQuery *struct {
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+func (g *guard) proxy(ctx context.Context, r *proxy.Request) (*proxy.Response, error) {
+	op, raw, err := decode(r)
+	if err != nil {
+		return g.reject(rejection{"parse", codeParseFailed, "unable to decode the GraphQL request: " + err.Error()})
+	}
+
+	query, rej := g.resolve(op)
+	if rej != nil {
+		return g.reject(*rej)
+	}
+	if rej := g.check(query, op); rej != nil {
+		return g.reject(*rej)
+	}
+
+	if query != op.Query {
+		r = withQuery(r, raw, query)
+	}
+	return g.next(ctx, r)
+}
+
+// resolve returns the query of the operation, looking it up by its hash if required
+func (g *guard) resolve(op operation) (string, *rejection) {
+	pq := g.cfg.PersistedQueries
+	if pq == nil {
+		return op.Query, nil
+	}
+	if ext := op.Extensions.PersistedQuery; ext != nil && op.Query == "" {
+		q, ok := pq.queries[ext.SHA256Hash]
+		if !ok {
+			return "", &rejection{"persisted_query", codePersistedNotFound, "PersistedQueryNotFound"}
+		}
+		return q, nil
+	}
+	if ext := op.Extensions.PersistedQuery; ext != nil && ext.SHA256Hash != hashQuery(op.Query) {
+		return "", &rejection{"persisted_query", codeValidationFailed, "provided sha does not match query"}
+	}
+	if pq.OnlyPersisted {
+		if _, ok := pq.queries[hashQuery(op.Query)]; !ok {
+			return "", &rejection{"persisted_query", codePersistedNotSupported, "only persisted queries are allowed"}
+		}
+	}
+	return op.Query, nil
+}
+
+// check parses the query and compares the cost of the operation with the limits
+func (g *guard) check(query string, op operation) *rejection {
+	lim := g.cfg.Limits
+	if lim == nil {
+		return nil
+	}
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return &rejection{"parse", codeParseFailed, err.Error()}
+	}
+	def := doc.Operations.ForName(op.OperationName)
+	if def == nil {
+		return &rejection{"parse", codeValidationFailed, "unknown operation " + strconv.Quote(op.OperationName)}
+	}
+
+	a := &analyzer{doc: doc, vars: op.Variables, listArguments: lim.ListArguments}
+	c := a.cost(def)
+	switch {
+	case lim.MaxDepth > 0 && c.depth > lim.MaxDepth:
+		return &rejection{"depth", codeValidationFailed, fmt.Sprintf("the query depth %d exceeds the max depth %d", c.depth, lim.MaxDepth)}
+	case lim.MaxComplexity > 0 && c.complexity > lim.MaxComplexity:
+		return &rejection{"complexity", codeValidationFailed, fmt.Sprintf("the query complexity %d exceeds the max complexity %d", c.complexity, lim.MaxComplexity)}
+	case lim.MaxAliases > 0 && c.aliases > lim.MaxAliases:
+		return &rejection{"aliases", codeValidationFailed, fmt.Sprintf("the query has %d aliases, exceeding the max of %d", c.aliases, lim.MaxAliases)}
+	}
+	return nil
+}
+
+// reject counts the rejection and returns the GraphQL errors as the response of the endpoint
+func (g *guard) reject(rej rejection) (*proxy.Response, error) {
+	g.l.Debug(g.logPrefix, "Operation rejected:", rej.message)
+	if g.registry != nil {
+		gometrics.GetOrRegisterCounter(g.prefix+rej.reason, g.registry).Inc(1)
+	}
+
+	errs := []interface{}{
+		map[string]interface{}{
+			"message":    rej.message,
+			"extensions": map[string]interface{}{"code": rej.code},
+		},
+	}
+	b, _ := json.Marshal(map[string]interface{}{"errors": errs})
+	return &proxy.Response{
+		Data:       map[string]interface{}{"errors": errs},
+		IsComplete: false,
+		Metadata: proxy.Metadata{
+			StatusCode: http.StatusOK,
+			Headers:    map[string][]string{"Content-Type": {"application/json"}},
+		},
+		Io: bytes.NewReader(b),
+	}, nil
+}
+
+// decode extracts the operation from the body of the request or, if empty, from its query string.
+// The raw fields of the body are returned, so it can be rewritten.
+func decode(r *proxy.Request) (operation, map[string]json.RawMessage, error) {
+	var op operation
+	if r.Body != nil {
+		b, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return op, nil, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		if len(bytes.TrimSpace(b)) > 0 {
+			var raw map[string]json.RawMessage
+			if err := json.Unmarshal(b, &raw); err != nil {
+				return op, nil, err
+			}
+			d := json.NewDecoder(bytes.NewReader(b))
+			d.UseNumber()
+			return op, raw, d.Decode(&op)
+		}
+	}
+
+	op.Query = r.Query.Get("query")
+	op.OperationName = r.Query.Get("operationName")
+	for k, v := range map[string]interface{}{"variables": &op.Variables, "extensions": &op.Extensions} {
+		if s := r.Query.Get(k); s != "" {
+			d := json.NewDecoder(bytes.NewBufferString(s))
+			d.UseNumber()
+			if err := d.Decode(v); err != nil {
+				return op, nil, err
+			}
+		}
+	}
+	return op, nil, nil
+}
+
+// withQuery returns a copy of the request with the persisted query instead of its hash
+func withQuery(r *proxy.Request, raw map[string]json.RawMessage, query string) *proxy.Request {
+	res := *r
+	if raw == nil {
+		res.Query = make(map[string][]string, len(r.Query))
+		for k, v := range r.Query {
+			res.Query[k] = v
+		}
+		res.Query.Set("query", query)
+		res.Query.Del("extensions")
+		return &res
+	}
+
+	raw["query"], _ = json.Marshal(query)
+	delete(raw, "extensions")
+	b, _ := json.Marshal(raw)
+	res.Body = io.NopCloser(bytes.NewReader(b))
+	res.Headers = make(map[string][]string, len(r.Headers))
+	for k, v := range r.Headers {
+		res.Headers[k] = v
+	}
+	if _, ok := res.Headers["Content-Length"]; ok {
+		res.Headers["Content-Length"] = []string{strconv.Itoa(len(b))}
+	}
+	return &res
+}
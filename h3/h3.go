@@ -0,0 +1,115 @@
+// Package h3 starts an HTTP/3 (QUIC) listener along with the HTTP/1.1 and HTTP/2 one, sharing its
+// handler and TLS configuration, and advertises it with the Alt-Svc header.
+package h3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/quic-go/quic-go/http3"
+
+	"github.com/krakend/krakend-ce/v2/listener"
+)
+
+// Namespace is the key used to store the HTTP/3 config at the service ExtraConfig
+const Namespace = "server/http3"
+
+const defaultAltSvcMaxAge = 86400
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+var errNoTLS = errors.New("HTTP/3 requires TLS")
+
+// Config defines the HTTP/3 listener
+type Config struct {
+	// Port is the UDP port to listen on. Default: the port of the service
+	Port int `json:"port"`
+	// AltSvcMaxAge is the number of seconds the clients can remember the HTTP/3 endpoint. Default: 86400
+	AltSvcMaxAge int `json:"alt_svc_max_age"`
+	// DisableAltSvc stops advertising the HTTP/3 listener
+	DisableAltSvc bool `json:"disable_alt_svc"`
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if res.AltSvcMaxAge <= 0 {
+		res.AltSvcMaxAge = defaultAltSvcMaxAge
+	}
+	return res, nil
+}
+
+// Extension returns a listener.Extension starting the HTTP/3 listener when it is configured
+func Extension(l logging.Logger) listener.Extension {
+	return func(_ context.Context, cfg config.ServiceConfig, s *http.Server) (func(context.Context) error, error) {
+		hCfg, err := ParseConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.TLSConfig == nil {
+			return nil, errNoTLS
+		}
+
+		port := hCfg.Port
+		if port == 0 {
+			port = cfg.Port
+		}
+		h3s := &http3.Server{
+			Addr:           fmt.Sprintf("%s:%d", cfg.Address, port),
+			Handler:        s.Handler,
+			TLSConfig:      s.TLSConfig,
+			MaxHeaderBytes: s.MaxHeaderBytes,
+			IdleTimeout:    s.IdleTimeout,
+		}
+		if !hCfg.DisableAltSvc {
+			s.Handler = AltSvc(s.Handler, port, hCfg.AltSvcMaxAge)
+		}
+
+		return func(ctx context.Context) error {
+			done := make(chan error, 1)
+			go func() {
+				l.Info("[SERVICE: HTTP/3] Listening on UDP", h3s.Addr)
+				done <- h3s.ListenAndServe()
+			}()
+
+			select {
+			case err := <-done:
+				return err
+			case <-ctx.Done():
+				return h3s.Shutdown(context.Background())
+			}
+		}, nil
+	}
+}
+
+// AltSvc returns a handler advertising the HTTP/3 listener in the responses sent over TCP
+func AltSvc(next http.Handler, port, maxAge int) http.Handler {
+	value := `h3=":` + strconv.Itoa(port) + `"; ma=` + strconv.Itoa(maxAge)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			w.Header().Add("Alt-Svc", value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,127 @@
+package h3
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestExtension(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("UDP is not available:", err)
+	}
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+	pc.Close()
+
+	cert, pool := selfSigned(t)
+	s := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Proto)
+		}),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	cfg := config.ServiceConfig{
+		Address:     "127.0.0.1",
+		Port:        port,
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"alt_svc_max_age": 60}},
+	}
+
+	run, err := Extension(logging.NoOp)(context.Background(), cfg, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if altSvc := w.Header().Get("Alt-Svc"); altSvc != `h3=":`+strconv.Itoa(port)+`"; ma=60` {
+		t.Errorf("unexpected Alt-Svc header: %s", altSvc)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run(ctx) }()
+
+	tr := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "localhost"}}
+	defer tr.Close()
+	client := &http.Client{Transport: tr, Timeout: time.Second}
+
+	var body string
+	for i := 0; i < 20; i++ {
+		resp, err := client.Get("https://127.0.0.1:" + strconv.Itoa(port) + "/")
+		if err != nil {
+			time.Sleep(50 * time.Millisecond)
+			continue
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		body = string(b)
+		if resp.Header.Get("Alt-Svc") != "" {
+			t.Error("the Alt-Svc header should not be sent over HTTP/3")
+		}
+		break
+	}
+	if body != "HTTP/3.0" {
+		t.Errorf("unexpected response: %q", body)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the listener did not stop")
+	}
+}
+
+func TestExtension_noTLS(t *testing.T) {
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{}}}
+	if _, err := Extension(logging.NoOp)(context.Background(), cfg, &http.Server{}); err != errNoTLS {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
@@ -1,7 +1,9 @@
 package krakend
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 
 	botdetector "github.com/krakend/krakend-botdetector/v2/gin"
 	jose "github.com/krakend/krakend-jose/v2"
@@ -17,17 +19,40 @@ import (
 	"github.com/luraproject/lura/v2/transport/http/server"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/krakend/krakend-ce/v2/eventstream"
+	"github.com/krakend/krakend-ce/v2/federation"
+	"github.com/krakend/krakend-ce/v2/ipfilter"
+	"github.com/krakend/krakend-ce/v2/mtls"
+	"github.com/krakend/krakend-ce/v2/opa"
+	"github.com/krakend/krakend-ce/v2/policy"
+	"github.com/krakend/krakend-ce/v2/streaming"
+	"github.com/krakend/krakend-ce/v2/websocket"
 )
 
 // NewHandlerFactory returns a HandlerFactory with a rate-limit and a metrics collector middleware injected
 func NewHandlerFactory(logger logging.Logger, metricCollector *metrics.Metrics, rejecter jose.RejecterFactory) router.HandlerFactory {
+	return NewHandlerFactoryWithContext(context.Background(), logger, metricCollector, rejecter)
+}
+
+// NewHandlerFactoryWithContext returns a HandlerFactory like NewHandlerFactory, releasing the
+// resources of the middlewares (like the watchers of the IP lists) when the context is cancelled
+func NewHandlerFactoryWithContext(ctx context.Context, logger logging.Logger, metricCollector *metrics.Metrics, rejecter jose.RejecterFactory) router.HandlerFactory {
 	handlerFactory := router.CustomErrorEndpointHandler(logger, server.DefaultToHTTPError)
+	handlerFactory = websocket.HandlerFactory(ctx, handlerFactory, logger, *metricCollector.Registry)
+	handlerFactory = streaming.HandlerFactory(ctx, handlerFactory, logger)
+	handlerFactory = eventstream.HandlerFactory(handlerFactory, logger, jwtClaims)
+	handlerFactory = federation.HandlerFactory(ctx, handlerFactory, logger)
 	handlerFactory = ratelimit.NewRateLimiterMw(logger, handlerFactory)
 	handlerFactory = lua.HandlerFactory(logger, handlerFactory)
+	handlerFactory = policy.HandlerFactory(handlerFactory, logger, jwtClaims)
+	handlerFactory = opa.HandlerFactory(handlerFactory, logger, jwtClaims)
 	handlerFactory = ginjose.HandlerFactory(handlerFactory, logger, rejecter)
+	handlerFactory = mtls.HandlerFactory(handlerFactory, logger)
 	handlerFactory = metricCollector.NewHTTPHandlerFactory(handlerFactory)
 	handlerFactory = opencensus.New(handlerFactory)
 	handlerFactory = botdetector.New(handlerFactory, logger)
+	handlerFactory = ipfilter.HandlerFactory(ctx, handlerFactory, logger)
 
 	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
 		logger.Debug(fmt.Sprintf("[ENDPOINT: %s] Building the http handler", cfg.Endpoint))
@@ -35,8 +60,23 @@ func NewHandlerFactory(logger logging.Logger, metricCollector *metrics.Metrics,
 	}
 }
 
+// jwtClaims decodes the token from the header or cookie defined at the JWT validator config of the
+// endpoint. The token is validated by the validator before reaching the consumers of the claims, so
+// it returns nil when the endpoint has no validator.
+func jwtClaims(cfg *config.EndpointConfig) func(*http.Request) map[string]interface{} {
+	scfg, err := jose.GetSignatureConfig(cfg)
+	if err != nil {
+		return nil
+	}
+	return policy.JWTClaims(scfg.AuthHeaderName, scfg.CookieKey)
+}
+
 type handlerFactory struct{}
 
 func (handlerFactory) NewHandlerFactory(l logging.Logger, m *metrics.Metrics, r jose.RejecterFactory) router.HandlerFactory {
 	return NewHandlerFactory(l, m, r)
 }
+
+func (handlerFactory) NewHandlerFactoryWithContext(ctx context.Context, l logging.Logger, m *metrics.Metrics, r jose.RejecterFactory) router.HandlerFactory {
+	return NewHandlerFactoryWithContext(ctx, l, m, r)
+}
@@ -0,0 +1,170 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/transport/http/client"
+
+	"github.com/krakend/krakend-ce/v2/certloader"
+)
+
+// NewHTTPClientFactory returns a client factory with a dedicated transport for the backend, if the
+// backend declares its own transport settings or any unix socket host. The returned factory always
+// returns the same client, so the connections are reused across requests.
+// The client certificates are reloaded from disk when rotated, until the context is cancelled.
+func NewHTTPClientFactory(ctx context.Context, cfg *config.Backend, l logging.Logger) (client.HTTPClientFactory, error) {
+	sockets := unixSockets(cfg.Host)
+	bCfg, err := ParseConfig(cfg.ExtraConfig)
+	if err == ErrNoConfig && len(sockets) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bCfg.H2C {
+		for _, h := range cfg.Host {
+			if strings.HasPrefix(strings.ToLower(h), "https://") {
+				return nil, ErrH2CWithTLS
+			}
+		}
+	}
+
+	t, err := newTransport(ctx, bCfg, l)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &http.Client{Transport: t}
+	if len(sockets) > 0 {
+		c.Transport = newUnixTransport(t, sockets)
+	}
+	return func(_ context.Context) *http.Client { return c }, nil
+}
+
+// NewTLSConfig returns the TLS config of the connections to the backend, for the protocols not
+// using the http client, like WebSocket. It is nil when the backend declares no client TLS settings.
+// The client certificates are reloaded from disk when rotated, until the context is cancelled.
+func NewTLSConfig(ctx context.Context, cfg *config.Backend, l logging.Logger) (*tls.Config, error) {
+	bCfg, err := ParseConfig(cfg.ExtraConfig)
+	if err == ErrNoConfig {
+		return nil, nil
+	}
+	if err != nil || bCfg.ClientTLS == nil {
+		return nil, err
+	}
+	return newTLSConfig(ctx, nil, bCfg.ClientTLS, l)
+}
+
+func newTransport(ctx context.Context, cfg Config, l logging.Logger) (*http.Transport, error) {
+	var t *http.Transport
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+		t = dt.Clone()
+	} else {
+		t = &http.Transport{Proxy: http.ProxyFromEnvironment}
+	}
+
+	if cfg.H2C {
+		// without HTTP/1.1, the transport talks HTTP/2 with prior knowledge to the http:// backends
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP2(true)
+		t.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	if cfg.ClientTLS == nil {
+		return t, nil
+	}
+
+	tlsCfg, err := newTLSConfig(ctx, t.TLSClientConfig, cfg.ClientTLS, l)
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tlsCfg
+	return t, nil
+}
+
+func newTLSConfig(ctx context.Context, base *tls.Config, cfg *ClientTLS, l logging.Logger) (*tls.Config, error) {
+	tlsCfg := &tls.Config{}
+	if base != nil {
+		tlsCfg = base.Clone()
+	}
+
+	if cfg.AllowInsecureConnections {
+		tlsCfg.InsecureSkipVerify = true // skipcq: GSC-G402
+	}
+	if cfg.ServerName != "" {
+		tlsCfg.ServerName = cfg.ServerName
+	}
+	if len(cfg.CipherSuites) > 0 {
+		tlsCfg.CipherSuites = cfg.CipherSuites
+	}
+
+	var err error
+	if cfg.MinVersion != "" {
+		if tlsCfg.MinVersion, err = parseTLSVersion(cfg.MinVersion); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.MaxVersion != "" {
+		if tlsCfg.MaxVersion, err = parseTLSVersion(cfg.MaxVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.CaCerts) > 0 || cfg.DisableSystemCaPool {
+		pool := x509.NewCertPool()
+		if !cfg.DisableSystemCaPool {
+			if sp, err := x509.SystemCertPool(); err == nil {
+				pool = sp
+			}
+		}
+		for _, path := range cfg.CaCerts {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(b) {
+				return nil, errors.New("no certificates found at " + path)
+			}
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if len(cfg.ClientCerts) == 0 {
+		return tlsCfg, nil
+	}
+
+	keypairs := make([]*certloader.Keypair, len(cfg.ClientCerts))
+	for i, c := range cfg.ClientCerts {
+		k, err := certloader.NewKeypair(ctx, l, c.Certificate, c.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		keypairs[i] = k
+	}
+	tlsCfg.Certificates = nil
+	tlsCfg.GetClientCertificate = selectClientCertificate(keypairs)
+
+	return tlsCfg, nil
+}
+
+// selectClientCertificate returns the first certificate accepted by the server, or the first one
+// if none of them matches the server requirements, so the server can report the failure
+func selectClientCertificate(keypairs []*certloader.Keypair) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return func(cri *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		for _, k := range keypairs {
+			c := k.Certificate()
+			if cri.SupportsCertificate(c) == nil {
+				return c, nil
+			}
+		}
+		return keypairs[0].Certificate(), nil
+	}
+}
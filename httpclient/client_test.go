@@ -0,0 +1,178 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/oauth2"
+)
+
+func TestNewHTTPClientFactory_clientTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	clientCert, clientKey := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	writeSelfSigned(t, serverCert, serverKey, "127.0.0.1")
+	writeSelfSigned(t, clientCert, clientKey, "gateway")
+
+	srvPair, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	b, _ := os.ReadFile(clientCert)
+	clientCAs.AppendCertsFromPEM(b)
+
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName+" "+r.Header.Get("Authorization"))
+	}))
+	s.TLS = &tls.Config{
+		Certificates: []tls.Certificate{srvPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	s.StartTLS()
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newBackend := func(clientTLS map[string]interface{}) *config.Backend {
+		return &config.Backend{
+			Host:        []string{s.URL},
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"client_tls": clientTLS}},
+		}
+	}
+
+	cf, err := NewHTTPClientFactory(ctx, newBackend(map[string]interface{}{
+		"ca_certs":     []interface{}{serverCert},
+		"client_certs": []interface{}{map[string]interface{}{"certificate": clientCert, "private_key": clientKey}},
+	}), logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBody(t, cf(ctx), s.URL, "gateway ")
+
+	auth := &http.Client{Transport: &oauth2.Transport{Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "secret"})}}
+	oauth := NewOAuth2ClientFactory(ctx, func(context.Context) *http.Client { return auth }, cf)
+	assertBody(t, oauth(ctx), s.URL, "gateway Bearer secret")
+
+	// the server certificate is not trusted without the pinned CA
+	cf, err = NewHTTPClientFactory(ctx, newBackend(map[string]interface{}{
+		"client_certs": []interface{}{map[string]interface{}{"certificate": clientCert, "private_key": clientKey}},
+	}), logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cf(ctx).Get(s.URL); err == nil {
+		t.Error("expecting an error for an untrusted server")
+	}
+
+	// the server rejects the connections without a client certificate
+	cf, err = NewHTTPClientFactory(ctx, newBackend(map[string]interface{}{"ca_certs": []interface{}{serverCert}}), logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cf(ctx).Get(s.URL); err == nil {
+		t.Error("expecting an error without a client certificate")
+	}
+
+	for _, clientTLS := range []map[string]interface{}{
+		{"ca_certs": []interface{}{filepath.Join(dir, "missing.pem")}},
+		{"ca_certs": []interface{}{serverKey}},
+		{"client_certs": []interface{}{map[string]interface{}{"certificate": clientCert, "private_key": serverKey}}},
+		{"min_version": "TLS14"},
+	} {
+		if _, err := NewHTTPClientFactory(ctx, newBackend(clientTLS), logging.NoOp); err == nil {
+			t.Errorf("expecting an error for %v", clientTLS)
+		}
+	}
+}
+
+func assertBody(t *testing.T, c *http.Client, url, expected string) {
+	t.Helper()
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != expected {
+		t.Errorf("unexpected response: %q", string(b))
+	}
+}
+
+func writeSelfSigned(t *testing.T, certFile, keyFile, name string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewHTTPClientFactory_h2c(t *testing.T) {
+	h2s := &http2.Server{}
+	s := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}), h2s))
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for h2cEnabled, expected := range map[bool]string{false: "HTTP/1.1", true: "HTTP/2.0"} {
+		cf, err := NewHTTPClientFactory(ctx, &config.Backend{
+			Host:        []string{s.URL},
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"h2c": h2cEnabled}},
+		}, logging.NoOp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertBody(t, cf(ctx), s.URL, expected)
+	}
+
+	_, err := NewHTTPClientFactory(ctx, &config.Backend{
+		Host:        []string{s.URL, "HTTPS://example.com"},
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"h2c": true}},
+	}, logging.NoOp)
+	if err != ErrH2CWithTLS {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
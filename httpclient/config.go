@@ -0,0 +1,82 @@
+// Package httpclient builds the http clients used to reach each backend, according to the
+// transport settings declared at its own config.
+package httpclient
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key used to store the http client config at the backend ExtraConfig struct
+const Namespace = "backend/http/client"
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// ErrH2CWithTLS is returned when the h2c transport is enabled for a backend with https:// hosts
+var ErrH2CWithTLS = errors.New("h2c can not be enabled for https:// hosts")
+
+// Config defines the transport settings of a backend
+type Config struct {
+	ClientTLS *ClientTLS `json:"client_tls"`
+	// H2C sends the requests to http:// backends over cleartext HTTP/2 with prior knowledge, as
+	// gRPC does, so the backend must accept HTTP/2 connections without negotiating them. It is
+	// rejected for https:// backends, as they negotiate HTTP/2 over TLS by themselves.
+	H2C bool `json:"h2c"`
+}
+
+// ClientTLS defines the TLS settings used to connect to the backend. Unset values keep the ones of
+// the default transport of the process (http.DefaultTransport), the one the transport of the
+// backend is cloned from.
+type ClientTLS struct {
+	AllowInsecureConnections bool         `json:"allow_insecure_connections"`
+	CaCerts                  []string     `json:"ca_certs"`
+	DisableSystemCaPool      bool         `json:"disable_system_ca_pool"`
+	ServerName               string       `json:"server_name"`
+	MinVersion               string       `json:"min_version"`
+	MaxVersion               string       `json:"max_version"`
+	CipherSuites             []uint16     `json:"cipher_suites"`
+	ClientCerts              []ClientCert `json:"client_certs"`
+}
+
+// ClientCert is the pair of files with a client certificate and its private key
+type ClientCert struct {
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	err = json.Unmarshal(b, &res)
+	return res, err
+}
+
+var versions = map[string]uint16{
+	"SSL3.0": tls.VersionSSL30, // skipcq: GSC-G402
+	"TLS10":  tls.VersionTLS10,
+	"TLS11":  tls.VersionTLS11,
+	"TLS12":  tls.VersionTLS12,
+	"TLS13":  tls.VersionTLS13,
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if res, ok := versions[v]; ok {
+		return res, nil
+	}
+	return 0, errors.New("unknown TLS version: " + v)
+}
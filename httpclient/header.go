@@ -0,0 +1,35 @@
+package httpclient
+
+import (
+	"net/http"
+	"strings"
+)
+
+// hopHeaders are the headers of a single connection, not forwarded by the proxies (RFC 9110)
+var hopHeaders = []string{
+	"Connection",
+	"Content-Length",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// RemoveHopHeaders deletes the headers of a single connection, including the ones listed at its
+// Connection header, so the rest can be forwarded
+func RemoveHopHeaders(h http.Header) {
+	for _, v := range h.Values("Connection") {
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				h.Del(k)
+			}
+		}
+	}
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+}
@@ -0,0 +1,22 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/transport/http/client"
+	"golang.org/x/oauth2"
+)
+
+// NewOAuth2ClientFactory sends the requests of the oauth2 client credentials client through the
+// transport of the backend, so the requests carry the token and use the client TLS settings of the
+// backend. The tokens are still requested with the transport of the oauth2 client.
+func NewOAuth2ClientFactory(ctx context.Context, auth, backend client.HTTPClientFactory) client.HTTPClientFactory {
+	a := auth(ctx)
+	t, ok := a.Transport.(*oauth2.Transport)
+	if !ok {
+		return auth
+	}
+	c := &http.Client{Transport: &oauth2.Transport{Source: t.Source, Base: backend(ctx).Transport}}
+	return func(_ context.Context) *http.Client { return c }
+}
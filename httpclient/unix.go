@@ -0,0 +1,101 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UnixScheme is the scheme of the backend hosts reached through a unix domain socket, like
+// unix:///var/run/app.sock. The lura host sanitizer does not accept them, so these backends must
+// declare "disable_host_sanitize": true.
+const UnixScheme = "unix://"
+
+// UnixSocket returns the path of the socket of a unix host, reporting if the host is one
+func UnixSocket(host string) (string, bool) {
+	if !strings.HasPrefix(host, UnixScheme) {
+		return "", false
+	}
+	return strings.TrimRight(strings.TrimPrefix(host, UnixScheme), "/"), true
+}
+
+// unixSockets returns the paths of the sockets declared as hosts of the backend
+func unixSockets(hosts []string) []string {
+	var res []string
+	for _, h := range hosts {
+		if s, ok := UnixSocket(h); ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// unixTransport sends the requests for the unix hosts through their sockets. The path of the
+// socket is the longest prefix of the URL path matching a declared socket. The request is then
+// sent to a host name identifying the socket, so every socket gets its own connection pool.
+type unixTransport struct {
+	next    http.RoundTripper
+	sockets []string
+}
+
+// newUnixTransport wraps the transport so it dials the sockets
+func newUnixTransport(t *http.Transport, sockets []string) *unixTransport {
+	sockets = append([]string{}, sockets...)
+	sort.SliceStable(sockets, func(i, j int) bool { return len(sockets[i]) > len(sockets[j]) })
+
+	addrs := make(map[string]string, len(sockets))
+	for i, s := range sockets {
+		addrs[unixHost(i)+":80"] = s
+	}
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if s, ok := addrs[addr]; ok {
+			return (&net.Dialer{}).DialContext(ctx, "unix", s)
+		}
+		return dial(ctx, network, addr)
+	}
+	// the proxies of the environment do not apply to the sockets
+	proxy := t.Proxy
+	t.Proxy = func(r *http.Request) (*url.URL, error) {
+		if _, ok := addrs[r.URL.Host+":80"]; ok || proxy == nil {
+			return nil, nil
+		}
+		return proxy(r)
+	}
+
+	return &unixTransport{next: t, sockets: sockets}
+}
+
+func (t *unixTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Scheme != "unix" {
+		return t.next.RoundTrip(r)
+	}
+	for i, s := range t.sockets {
+		if r.URL.Path != s && !strings.HasPrefix(r.URL.Path, s+"/") {
+			continue
+		}
+		r = r.Clone(r.Context())
+		r.URL.Scheme = "http"
+		r.URL.Host = unixHost(i)
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, s)
+		r.URL.RawPath = ""
+		if r.URL.Path == "" {
+			r.URL.Path = "/"
+		}
+		r.Host = "localhost"
+		return t.next.RoundTrip(r)
+	}
+	return nil, fmt.Errorf("no unix socket declared for %s", r.URL.Path)
+}
+
+func unixHost(i int) string {
+	return "unix-socket-" + strconv.Itoa(i)
+}
@@ -0,0 +1,44 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestNewHTTPClientFactory_unixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backend.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("unix sockets are not available:", err)
+	}
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host+r.URL.RequestURI())
+	})}
+	go s.Serve(ln)
+	defer s.Close()
+
+	cf, err := NewHTTPClientFactory(context.Background(), &config.Backend{Host: []string{"unix://" + path}}, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := cf(context.Background()).Get("unix://" + path + "/users/1?q=a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "localhost/users/1?q=a" {
+		t.Errorf("unexpected response: %q", string(b))
+	}
+
+	if _, err := cf(context.Background()).Get("unix:///var/run/unknown.sock/users"); err == nil {
+		t.Error("expecting an error for an unknown socket")
+	}
+}
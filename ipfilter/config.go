@@ -0,0 +1,55 @@
+// Package ipfilter accepts or rejects the requests depending on the IP of the client, checking it
+// against lists of CIDRs and, optionally, against the country resolved with a MaxMind database.
+package ipfilter
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/luraproject/lura/v2/config"
+
+	"github.com/krakend/krakend-ce/v2/clientip"
+)
+
+// Namespace is the key used to store the IP filter config at the ExtraConfig struct
+const Namespace = "security/ipfilter"
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// Config defines the rules of the filter. Deny rules are checked first. When there are allow rules,
+// the IP must match at least one of them. The requests received at the unix socket of the service
+// are not filtered, as their peers have no IP and the permissions of the socket restrict them.
+type Config struct {
+	// Allow and Deny are lists of IPs or CIDRs
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+	// AllowFiles and DenyFiles contain an IP or CIDR per line (empty lines and lines starting with
+	// # are ignored). They are reloaded when changed.
+	AllowFiles []string `json:"allow_files"`
+	DenyFiles  []string `json:"deny_files"`
+	// AllowCountries and DenyCountries are lists of ISO 3166-1 country codes, resolved with the
+	// MaxMind database at GeoIPDB
+	AllowCountries []string `json:"allow_countries"`
+	DenyCountries  []string `json:"deny_countries"`
+	GeoIPDB        string   `json:"geoip_db"`
+	// Config defines the trusted proxies and the header declaring the client IP
+	clientip.Config
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	return res, nil
+}
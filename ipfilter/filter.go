@@ -0,0 +1,186 @@
+package ipfilter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/krakend/krakend-ce/v2/clientip"
+	"github.com/krakend/krakend-ce/v2/filewatch"
+)
+
+// Filter decides if a client IP is allowed
+type Filter struct {
+	cfg            Config
+	lists          atomic.Pointer[lists]
+	allowCountries map[string]struct{}
+	denyCountries  map[string]struct{}
+	geo            *maxminddb.Reader
+	resolver       *clientip.Resolver
+}
+
+type lists struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// New creates a filter with the received config. The list files are watched until the context
+// is cancelled.
+func New(ctx context.Context, cfg Config, l logging.Logger) (*Filter, error) {
+	f := &Filter{
+		cfg:            cfg,
+		allowCountries: toSet(cfg.AllowCountries),
+		denyCountries:  toSet(cfg.DenyCountries),
+	}
+
+	var err error
+	if f.resolver, err = clientip.New(cfg.Config); err != nil {
+		return nil, err
+	}
+
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+
+	if len(f.allowCountries)+len(f.denyCountries) > 0 {
+		if cfg.GeoIPDB == "" {
+			return nil, fmt.Errorf("country rules require a geoip_db")
+		}
+		if f.geo, err = maxminddb.Open(cfg.GeoIPDB); err != nil {
+			return nil, err
+		}
+		go func() {
+			<-ctx.Done()
+			f.geo.Close()
+		}()
+	}
+
+	files := append(append([]string{}, cfg.AllowFiles...), cfg.DenyFiles...)
+	if len(files) == 0 {
+		return f, nil
+	}
+
+	err = filewatch.Watch(ctx, l, files, func() {
+		if err := f.reload(); err != nil {
+			l.Error("[SERVICE: IP filter] Unable to reload the lists:", err.Error())
+			return
+		}
+		l.Info("[SERVICE: IP filter] Lists reloaded")
+	})
+	return f, err
+}
+
+// Allowed checks the IP against the deny rules and then, if there are allow rules, against them.
+// Invalid IPs are always rejected, as they can not be checked against the deny rules.
+func (f *Filter) Allowed(ip netip.Addr) bool {
+	if !ip.IsValid() {
+		return false
+	}
+	ip = ip.Unmap()
+
+	ls := f.lists.Load()
+	hasAllowRules := len(ls.allow) > 0 || len(f.allowCountries) > 0
+
+	if clientip.Contains(ls.deny, ip) {
+		return false
+	}
+
+	country := f.country(ip)
+	if _, ok := f.denyCountries[country]; ok && country != "" {
+		return false
+	}
+
+	if !hasAllowRules || clientip.Contains(ls.allow, ip) {
+		return true
+	}
+	_, ok := f.allowCountries[country]
+	return ok && country != ""
+}
+
+// ClientIP returns the IP of the client, resolved with the trusted proxies of the config
+func (f *Filter) ClientIP(r *http.Request) netip.Addr {
+	return f.resolver.ClientIP(r)
+}
+
+func (f *Filter) country(ip netip.Addr) string {
+	if f.geo == nil {
+		return ""
+	}
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := f.geo.Lookup(net.IP(ip.AsSlice()), &record); err != nil {
+		return ""
+	}
+	return strings.ToUpper(record.Country.ISOCode)
+}
+
+func (f *Filter) reload() error {
+	allow, err := loadPrefixes(f.cfg.Allow, f.cfg.AllowFiles)
+	if err != nil {
+		return err
+	}
+	deny, err := loadPrefixes(f.cfg.Deny, f.cfg.DenyFiles)
+	if err != nil {
+		return err
+	}
+	f.lists.Store(&lists{allow: allow, deny: deny})
+	return nil
+}
+
+func loadPrefixes(values, files []string) ([]netip.Prefix, error) {
+	res, err := clientip.ParsePrefixes(values)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		fromFile, err := readList(path)
+		if err != nil {
+			return nil, err
+		}
+		prefixes, err := clientip.ParsePrefixes(fromFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		res = append(res, prefixes...)
+	}
+	return res, nil
+}
+
+func readList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var res []string
+	s := bufio.NewScanner(file)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		res = append(res, line)
+	}
+	return res, s.Err()
+}
+
+func toSet(values []string) map[string]struct{} {
+	res := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		res[strings.ToUpper(v)] = struct{}{}
+	}
+	return res
+}
@@ -0,0 +1,72 @@
+package ipfilter
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestFilter_Allowed(t *testing.T) {
+	dir := t.TempDir()
+	denyFile := filepath.Join(dir, "deny.txt")
+	if err := os.WriteFile(denyFile, []byte("# blocked\n192.168.1.10\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f, err := New(ctx, Config{
+		Allow:     []string{"192.168.1.0/24", "::1"},
+		DenyFiles: []string{denyFile},
+	}, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, want := range map[string]bool{
+		"192.168.1.1":  true,
+		"192.168.1.10": false,
+		"192.168.2.1":  false,
+		"::1":          true,
+	} {
+		if got := f.Allowed(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("%s: got %v, want %v", ip, got, want)
+		}
+	}
+	if f.Allowed(netip.Addr{}) {
+		t.Error("invalid IPs must be rejected")
+	}
+
+	if err := os.WriteFile(denyFile, []byte("192.168.1.1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for f.Allowed(netip.MustParseAddr("192.168.1.1")) {
+		if time.Now().After(deadline) {
+			t.Fatal("the deny list was not reloaded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !f.Allowed(netip.MustParseAddr("192.168.1.10")) {
+		t.Error("192.168.1.10 should be allowed after the reload")
+	}
+}
+
+func TestFilter_invalidIP(t *testing.T) {
+	f, err := New(context.Background(), Config{Deny: []string{"5.6.7.8"}}, logging.NoOp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Allowed(netip.MustParseAddr("1.2.3.4")) {
+		t.Error("1.2.3.4 should be allowed")
+	}
+	if f.Allowed(netip.Addr{}) {
+		t.Error("invalid IPs must be rejected even without allow rules")
+	}
+}
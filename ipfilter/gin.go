@@ -0,0 +1,99 @@
+package ipfilter
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	router "github.com/luraproject/lura/v2/router/gin"
+
+	"github.com/krakend/krakend-ce/v2/clientip"
+)
+
+const logPrefix = "[SERVICE: Gin][IPFilter]"
+
+// Register checks the configuration and, if required, registers an IP filter middleware at the gin
+// engine. The list files of the filter are watched until the context is cancelled.
+func Register(ctx context.Context, cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine) {
+	fCfg, err := ParseConfig(cfg.ExtraConfig)
+	if err == ErrNoConfig {
+		return
+	}
+	if err != nil {
+		l.Warning(logPrefix, err.Error())
+		return
+	}
+	f, err := New(ctx, fCfg, l)
+	if err != nil {
+		l.Error(logPrefix, "Unable to create the IP filter, rejecting all the requests:", err.Error())
+		engine.Use(erroredHandler)
+		return
+	}
+
+	l.Debug(logPrefix, "The IP filter has been registered successfully")
+	engine.Use(middleware(f, l, logPrefix))
+}
+
+// HandlerFactory checks the configuration and, if required, wraps the handler factory with an IP
+// filter middleware. The list files of the filters are watched until the context is cancelled.
+func HandlerFactory(ctx context.Context, hf router.HandlerFactory, l logging.Logger) router.HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		next := hf(cfg, p)
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][IPFilter]"
+
+		fCfg, err := ParseConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			return next
+		}
+		if err != nil {
+			l.Warning(logPrefix, err.Error())
+			return next
+		}
+		f, err := New(ctx, fCfg, l)
+		if err != nil {
+			l.Error(logPrefix, "Unable to create the IP filter, rejecting all the requests:", err.Error())
+			return erroredHandler
+		}
+
+		l.Debug(logPrefix, "The IP filter has been registered successfully")
+		return handler(f, next, l, logPrefix)
+	}
+}
+
+func middleware(f *Filter, l logging.Logger, logPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rejected(f, c, l, logPrefix) {
+			return
+		}
+		c.Next()
+	}
+}
+
+func handler(f *Filter, next gin.HandlerFunc, l logging.Logger, logPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rejected(f, c, l, logPrefix) {
+			return
+		}
+		next(c)
+	}
+}
+
+func rejected(f *Filter, c *gin.Context, l logging.Logger, logPrefix string) bool {
+	if clientip.FromUnixSocket(c.Request) {
+		return false
+	}
+	ip := f.ClientIP(c.Request)
+	if f.Allowed(ip) {
+		return false
+	}
+	l.Debug(logPrefix, "IP rejected:", ip.String())
+	c.AbortWithStatus(http.StatusForbidden)
+	return true
+}
+
+func erroredHandler(c *gin.Context) {
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
@@ -0,0 +1,60 @@
+package ipfilter
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestRegister_unixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "krakend.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("unix sockets are not available:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	Register(ctx, config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"allow": []interface{}{"10.0.0.0/8"}}},
+	}, logging.NoOp, engine)
+	engine.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	s := &http.Server{Handler: engine}
+	go s.Serve(ln)
+	defer s.Close()
+
+	c := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := c.Get("http://unix/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status code at the unix socket: %d", resp.StatusCode)
+	}
+
+	for _, remoteAddr := range []string{"192.168.1.1:1234", "@", ""} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%q: unexpected status code: %d", remoteAddr, w.Code)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Package limits protects the gateway against oversized requests and slow clients, enforcing the
+// max size of the headers and the body and a min upload rate for the body. The time allowed to
+// send the headers is controlled by the read_header_timeout of the service.
+package limits
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key used to store the limits config at the ExtraConfig struct
+const Namespace = "router/limits"
+
+const defaultUploadGracePeriod = 5 * time.Second
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// Config defines the limits of the requests. At the endpoint level, the non-zero values override
+// the ones defined at the service level.
+type Config struct {
+	// MaxBodySize is the max number of bytes of the body
+	MaxBodySize int64 `json:"max_body_size"`
+	// MaxHeaderCount is the max number of header values
+	MaxHeaderCount int `json:"max_header_count"`
+	// MaxHeaderSize is the max number of bytes of all the header names and values
+	MaxHeaderSize int `json:"max_header_size"`
+	// MinUploadRate is the min number of bytes per second the client must send after the
+	// UploadGracePeriod (default: 5s). It requires a MaxBodySize, as the body is read in advance.
+	MinUploadRate     int64  `json:"min_upload_rate"`
+	UploadGracePeriod string `json:"upload_grace_period"`
+
+	gracePeriod time.Duration
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if res.UploadGracePeriod != "" {
+		if res.gracePeriod, err = time.ParseDuration(res.UploadGracePeriod); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// merge returns a copy of the config with the non-zero values of the override
+func (c Config) merge(override Config) Config {
+	if override.MaxBodySize > 0 {
+		c.MaxBodySize = override.MaxBodySize
+	}
+	if override.MaxHeaderCount > 0 {
+		c.MaxHeaderCount = override.MaxHeaderCount
+	}
+	if override.MaxHeaderSize > 0 {
+		c.MaxHeaderSize = override.MaxHeaderSize
+	}
+	if override.MinUploadRate > 0 {
+		c.MinUploadRate = override.MinUploadRate
+	}
+	if override.gracePeriod > 0 {
+		c.gracePeriod = override.gracePeriod
+	}
+	return c
+}
+
+func (c Config) validate() error {
+	if c.MinUploadRate > 0 && c.MaxBodySize <= 0 {
+		return errors.New("min_upload_rate requires a max_body_size")
+	}
+	return nil
+}
+
+func (c Config) enabled() bool {
+	return c.MaxBodySize > 0 || c.MaxHeaderCount > 0 || c.MaxHeaderSize > 0
+}
@@ -0,0 +1,159 @@
+package limits
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+const logPrefix = "[SERVICE: Gin][Limits]"
+
+// ErrorRenderer aborts the request with the received status
+type ErrorRenderer func(c *gin.Context, status int)
+
+// Register checks the configuration of the service and its endpoints and, if required, registers a
+// middleware enforcing the limits at the gin engine. The endpoint limits are selected with the
+// route matched by gin.
+func Register(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine, errF ErrorRenderer) {
+	global, err := ParseConfig(cfg.ExtraConfig)
+	if err != nil && err != ErrNoConfig {
+		l.Warning(logPrefix, err.Error())
+		return
+	}
+	if err := global.validate(); err != nil {
+		l.Warning(logPrefix, err.Error())
+		return
+	}
+
+	endpoints := map[string]Config{}
+	for _, e := range cfg.Endpoints {
+		eCfg, err := ParseConfig(e.ExtraConfig)
+		if err == ErrNoConfig {
+			continue
+		}
+		prefix := "[ENDPOINT: " + e.Endpoint + "][Limits]"
+		if err != nil {
+			l.Warning(prefix, err.Error())
+			continue
+		}
+		eCfg = global.merge(eCfg)
+		if err := eCfg.validate(); err != nil {
+			l.Warning(prefix, err.Error())
+			continue
+		}
+		endpoints[e.Method+" "+e.Endpoint] = eCfg
+	}
+
+	if !global.enabled() && len(endpoints) == 0 {
+		return
+	}
+	if errF == nil {
+		errF = func(c *gin.Context, status int) { c.AbortWithStatus(status) }
+	}
+
+	l.Debug(logPrefix, "The request limits have been registered successfully")
+	engine.Use(func(c *gin.Context) {
+		lCfg, ok := endpoints[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			lCfg = global
+		}
+		if status := check(lCfg, c); status != 0 {
+			if status != http.StatusRequestHeaderFieldsTooLarge {
+				c.Header("Connection", "close")
+			}
+			errF(c, status)
+			return
+		}
+		c.Next()
+	})
+}
+
+// check applies the limits to the request and returns the status to reject it with, if any. The
+// declared Content-Length is checked first, so the oversized requests are rejected without reading
+// any byte of their body.
+func check(cfg Config, c *gin.Context) int {
+	r := c.Request
+	if cfg.MaxBodySize > 0 && r.ContentLength > cfg.MaxBodySize {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	if cfg.MaxHeaderCount > 0 || cfg.MaxHeaderSize > 0 {
+		count, size := 0, 0
+		for k, vs := range r.Header {
+			for _, v := range vs {
+				count++
+				size += len(k) + len(v)
+			}
+		}
+		if (cfg.MaxHeaderCount > 0 && count > cfg.MaxHeaderCount) || (cfg.MaxHeaderSize > 0 && size > cfg.MaxHeaderSize) {
+			return http.StatusRequestHeaderFieldsTooLarge
+		}
+	}
+
+	if cfg.MaxBodySize <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return 0
+	}
+	if r.ContentLength >= 0 && cfg.MinUploadRate <= 0 {
+		r.Body = http.MaxBytesReader(c.Writer, r.Body, cfg.MaxBodySize)
+		return 0
+	}
+	return readBody(cfg, c)
+}
+
+// readBody reads the body in advance, so the requests with a body of unknown length can be rejected
+// before reaching the handlers and the min upload rate can be enforced
+func readBody(cfg Config, c *gin.Context) int {
+	r := c.Request
+	grace := cfg.gracePeriod
+	if grace <= 0 {
+		grace = defaultUploadGracePeriod
+	}
+	rc := http.NewResponseController(c.Writer)
+	start := time.Now()
+
+	var buf bytes.Buffer
+	body := io.LimitReader(r.Body, cfg.MaxBodySize+1)
+	chunk := make([]byte, 32*1024)
+	for {
+		if cfg.MinUploadRate > 0 {
+			rc.SetReadDeadline(start.Add(grace + uploadTime(buf.Len(), cfg.MinUploadRate)))
+		}
+		n, err := body.Read(chunk)
+		buf.Write(chunk[:n])
+		if int64(buf.Len()) > cfg.MaxBodySize {
+			return http.StatusRequestEntityTooLarge
+		}
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return http.StatusRequestTimeout
+		}
+		if err != nil {
+			return http.StatusBadRequest
+		}
+		if cfg.MinUploadRate > 0 && time.Since(start) > grace+uploadTime(buf.Len(), cfg.MinUploadRate) {
+			return http.StatusRequestTimeout
+		}
+	}
+	if cfg.MinUploadRate > 0 {
+		rc.SetReadDeadline(time.Time{})
+	}
+
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(buf.Bytes()))
+	r.ContentLength = int64(buf.Len())
+	return 0
+}
+
+// uploadTime returns the time a client uploading at the min rate needs to send the bytes
+func uploadTime(n int, rate int64) time.Duration {
+	return time.Duration(float64(n) / float64(rate) * float64(time.Second))
+}
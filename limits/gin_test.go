@@ -0,0 +1,111 @@
+package limits
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestRegister(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"max_body_size":    10,
+				"max_header_count": 3,
+			},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/upload/:id",
+				Method:   http.MethodPost,
+				ExtraConfig: config.ExtraConfig{
+					Namespace: map[string]interface{}{
+						"max_body_size":       100,
+						"min_upload_rate":     1024 * 1024,
+						"upload_grace_period": "10ms",
+					},
+				},
+			},
+		},
+	}
+
+	engine := gin.New()
+	Register(cfg, logging.NoOp, engine, nil)
+	echo := func(c *gin.Context) {
+		b, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusOK, string(b))
+	}
+	engine.POST("/echo", echo)
+	engine.POST("/upload/:id", echo)
+
+	for _, tc := range []struct {
+		name    string
+		path    string
+		body    io.Reader
+		headers int
+		length  int64
+		status  int
+	}{
+		{name: "ok", path: "/echo", body: strings.NewReader("hello"), status: http.StatusOK},
+		{name: "too large", path: "/echo", body: strings.NewReader("hello world!"), status: http.StatusRequestEntityTooLarge},
+		{name: "declared too large", path: "/upload/1", body: unreadBody{t}, length: 101, status: http.StatusRequestEntityTooLarge},
+		{name: "chunked too large", path: "/echo", body: io.MultiReader(strings.NewReader("hello world!")), status: http.StatusRequestEntityTooLarge},
+		{name: "too many headers", path: "/echo", body: strings.NewReader("hello"), headers: 4, status: http.StatusRequestHeaderFieldsTooLarge},
+		{name: "endpoint override", path: "/upload/1", body: strings.NewReader("hello world!"), status: http.StatusOK},
+		{name: "slow client", path: "/upload/1", body: &slowReader{data: "hello world!"}, status: http.StatusRequestTimeout},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, tc.body)
+			if _, ok := tc.body.(*strings.Reader); !ok {
+				req.ContentLength = -1
+			}
+			if tc.length > 0 {
+				req.ContentLength = tc.length
+			}
+			for i := 0; i < tc.headers; i++ {
+				req.Header.Add("X-Header", "value")
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Errorf("unexpected status: %d", w.Code)
+			}
+		})
+	}
+}
+
+// unreadBody fails the test when the body is read
+type unreadBody struct {
+	t *testing.T
+}
+
+func (r unreadBody) Read([]byte) (int, error) {
+	r.t.Error("the body of the oversized request was read")
+	return 0, io.EOF
+}
+
+type slowReader struct {
+	data string
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, io.EOF
+	}
+	time.Sleep(20 * time.Millisecond)
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
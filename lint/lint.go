@@ -0,0 +1,197 @@
+// Package lint checks the configuration for the semantic mistakes the JSON schema can not catch,
+// like settings ignored by the gateway or routes it refuses to register.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key used to store the lint config at the service and endpoint ExtraConfig
+const Namespace = "lint"
+
+// Severity is how bad a finding is
+type Severity string
+
+const (
+	// SeverityError flags configurations that fail or are surely broken
+	SeverityError Severity = "error"
+	// SeverityWarning flags settings the gateway ignores or that probably do not behave as expected
+	SeverityWarning Severity = "warning"
+	// SeverityInfo flags configurations worth a look
+	SeverityInfo Severity = "info"
+)
+
+var severityLevels = map[Severity]int{SeverityInfo: 0, SeverityWarning: 1, SeverityError: 2}
+
+// AtLeast reports if the severity is as bad as the other one
+func (s Severity) AtLeast(other Severity) bool {
+	return severityLevels[s] >= severityLevels[other]
+}
+
+// Valid reports if the severity is a known one
+func (s Severity) Valid() bool {
+	_, ok := severityLevels[s]
+	return ok
+}
+
+// Rule is a check of the catalog
+type Rule struct {
+	ID          string   `json:"id"`
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description"`
+	check       func(*document) []Finding
+}
+
+// Finding is a mistake found by a rule
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// Endpoint is the method and the path of the endpoint, if the finding is located at one
+	Endpoint string `json:"endpoint,omitempty"`
+	// Backend is the position of the backend at the endpoint, if the finding is located at one
+	Backend *int   `json:"backend,omitempty"`
+	Message string `json:"message"`
+}
+
+// String returns the finding in a single line
+func (f Finding) String() string {
+	location := ""
+	if f.Endpoint != "" {
+		location = " " + f.Endpoint
+	}
+	if f.Backend != nil {
+		location += fmt.Sprintf(" (backend %d)", *f.Backend)
+	}
+	return fmt.Sprintf("[%s] %s%s: %s", strings.ToUpper(string(f.Severity)), f.Rule, location, f.Message)
+}
+
+// Config tunes the rules. At the service, it disables rules or overrides their severity for the
+// whole configuration. At an endpoint, it disables rules for the endpoint and its backends.
+type Config struct {
+	Disable  []string            `json:"disable"`
+	Severity map[string]Severity `json:"severity"`
+}
+
+func parseConfig(extra config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := extra[Namespace]
+	if !ok {
+		return res, nil
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	for id, s := range res.Severity {
+		if !s.Valid() {
+			return res, fmt.Errorf("unknown severity %q for the rule %s", s, id)
+		}
+	}
+	return res, nil
+}
+
+// Rules returns the catalog of rules, sorted by id
+func Rules() []Rule {
+	res := make([]Rule, len(catalog))
+	copy(res, catalog)
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// Lint checks the parsed configuration with every rule not disabled, returning the findings
+// sorted by severity, endpoint and rule. The configuration should not be initialized, as the
+// initialization rewrites some of the settings the rules check, like the params of the paths.
+func Lint(cfg config.ServiceConfig) ([]Finding, error) {
+	doc, err := newDocument(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []Finding{}
+	for _, r := range catalog {
+		if contains(doc.lint.Disable, r.ID) {
+			continue
+		}
+		severity := r.Severity
+		if s, ok := doc.lint.Severity[r.ID]; ok {
+			severity = s
+		}
+		for _, f := range r.check(doc) {
+			if f.Endpoint != "" && contains(doc.disabled[f.Endpoint], r.ID) {
+				continue
+			}
+			f.Rule = r.ID
+			f.Severity = severity
+			findings = append(findings, f)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return severityLevels[a.Severity] > severityLevels[b.Severity]
+		}
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		return a.Rule < b.Rule
+	})
+	return findings, nil
+}
+
+// document is the configuration, with the lint configs of the service and the endpoints
+type document struct {
+	cfg       config.ServiceConfig
+	lint      Config
+	endpoints []endpoint
+	// disabled are the rules disabled at every endpoint
+	disabled map[string][]string
+}
+
+type endpoint struct {
+	key    string
+	method string
+	path   string
+	cfg    *config.EndpointConfig
+}
+
+func newDocument(cfg config.ServiceConfig) (*document, error) {
+	lint, err := parseConfig(cfg.ExtraConfig)
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{cfg: cfg, lint: lint, disabled: map[string][]string{}}
+
+	for _, e := range cfg.Endpoints {
+		method := strings.ToUpper(e.Method)
+		if method == "" {
+			method = "GET"
+		}
+		ep := endpoint{key: method + " " + e.Endpoint, method: method, path: e.Endpoint, cfg: e}
+
+		eCfg, err := parseConfig(e.ExtraConfig)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ep.key, err)
+		}
+		doc.disabled[ep.key] = append(doc.disabled[ep.key], eCfg.Disable...)
+		doc.endpoints = append(doc.endpoints, ep)
+	}
+	return doc, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
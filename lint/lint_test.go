@@ -0,0 +1,82 @@
+package lint
+
+import (
+	"path/filepath"
+	"testing"
+
+	koanf "github.com/krakend/krakend-koanf"
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestLint(t *testing.T) {
+	// the config as parsed by the lint command, before the initialization
+	cfg, err := koanf.New().ParseWithoutInit("./testdata/krakend.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := Lint(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	expected := []string{
+		"[ERROR] sequential-reference GET /carts/{id} (backend 1): {resp0_user} is never replaced, as the endpoint is not sequential",
+		"[ERROR] sequential-reference GET /orders/{id} (backend 0): {resp0_user} references the backend 0, which is not called before this one",
+		"[ERROR] sequential-reference GET /orders/{id} (backend 1): {resp2_x} references the backend 2, but the endpoint has 2 backends",
+		"[ERROR] duplicate-route GET /users/{name}: the endpoint conflicts with GET /users/{id}, differing only in the param names",
+		`[WARNING] noop-manipulation DELETE /users/{id} (backend 0): the "allow" setting is ignored with the no-op encoding`,
+		"[INFO] unused-plugin: the plugin " + filepath.Join("testdata", "plugins", "forgotten.so") + " is not used by any extra config",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("unexpected findings:\n%v", got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("unexpected finding #%d:\n%s\n%s", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestLint_disabled(t *testing.T) {
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{"disable": []interface{}{"duplicate-route"}},
+		},
+		Endpoints: []*config.EndpointConfig{{Endpoint: "/a"}, {Endpoint: "/a"}},
+	}
+	findings, err := Lint(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("unexpected findings: %v", findings)
+	}
+
+	cfg.ExtraConfig = config.ExtraConfig{
+		Namespace: map[string]interface{}{"severity": map[string]interface{}{"duplicate-route": "fatal"}},
+	}
+	if _, err := Lint(cfg); err == nil {
+		t.Error("expecting an error for an unknown severity")
+	}
+}
+
+func TestRouteShape(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		expected string
+	}{
+		{"/users/{id}", "/users/:"},
+		{"/users/:id", "/users/:"},
+		{"/users/{id}/orders/{order}", "/users/:/orders/:"},
+		{"/files/*path", "/files/*"},
+		{"/users/me", "/users/me"},
+	} {
+		if got := RouteShape(tc.path); got != tc.expected {
+			t.Errorf("%s: unexpected shape %s", tc.path, got)
+		}
+	}
+}
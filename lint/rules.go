@@ -0,0 +1,223 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+const encodingNoop = "no-op"
+
+var catalog = []Rule{
+	{
+		ID:          "noop-manipulation",
+		Severity:    SeverityWarning,
+		Description: "The no-op encoding proxies the response as is, ignoring the allow, deny, mapping, group and target settings.",
+		check:       checkNoopManipulation,
+	},
+	{
+		ID:          "duplicate-route",
+		Severity:    SeverityError,
+		Description: "Two endpoints with the same method and path, even with different param names, can not be registered together.",
+		check:       checkDuplicateRoute,
+	},
+	{
+		ID:          "sequential-reference",
+		Severity:    SeverityError,
+		Description: "A {respN_...} reference is only replaced at sequential endpoints, with the response of a previous backend.",
+		check:       checkSequentialReference,
+	},
+	{
+		ID:          "unused-plugin",
+		Severity:    SeverityWarning,
+		Description: "A plugin in the plugin folder not used by any extra config is loaded for nothing.",
+		check:       checkUnusedPlugin,
+	},
+}
+
+func checkNoopManipulation(doc *document) []Finding {
+	serviceNoop := doc.cfg.OutputEncoding == encodingNoop
+	var res []Finding
+	for _, e := range doc.endpoints {
+		noop := e.cfg.OutputEncoding == encodingNoop || (e.cfg.OutputEncoding == "" && serviceNoop)
+		for i, b := range e.cfg.Backend {
+			if !noop && b.Encoding != encodingNoop {
+				continue
+			}
+			settings := []struct {
+				key   string
+				empty bool
+			}{
+				{"allow", len(b.AllowList) == 0},
+				{"deny", len(b.DenyList) == 0},
+				{"mapping", len(b.Mapping) == 0},
+				{"group", b.Group == ""},
+				{"target", b.Target == ""},
+			}
+			for _, setting := range settings {
+				if setting.empty {
+					continue
+				}
+				res = append(res, Finding{
+					Endpoint: e.key,
+					Backend:  index(i),
+					Message:  fmt.Sprintf("the %q setting is ignored with the no-op encoding", setting.key),
+				})
+			}
+		}
+	}
+	return res
+}
+
+func checkDuplicateRoute(doc *document) []Finding {
+	seen := map[string]endpoint{}
+	var res []Finding
+	for _, e := range doc.endpoints {
+		key := e.method + " " + RouteShape(e.path)
+		first, ok := seen[key]
+		if !ok {
+			seen[key] = e
+			continue
+		}
+		msg := "the endpoint is declared twice"
+		if first.path != e.path {
+			msg = fmt.Sprintf("the endpoint conflicts with %s, differing only in the param names", first.key)
+		}
+		res = append(res, Finding{Endpoint: e.key, Message: msg})
+	}
+	return res
+}
+
+// RouteShape replaces the names of the params of the path, as the router ignores them, so two
+// paths with the same shape can not be registered together. The params are declared as {name} in
+// the configuration and as :name once initialized, and the catch-all ones as *name.
+func RouteShape(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || (strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}")) {
+			segments[i] = ":"
+		} else if strings.HasPrefix(s, "*") {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// reResponseReference matches the references to the responses of the previous backends, as
+// declared in the configuration ({resp0_id}) or once initialized ({{.Resp0_id}})
+var reResponseReference = regexp.MustCompile(`\{\{?\.?[Rr]esp(\d+)_([^}]*)\}\}?`)
+
+// proxyNamespaces are the keys of the proxy config, as declared and once normalized
+var proxyNamespaces = []string{"proxy", "github.com/devopsfaith/krakend/proxy"}
+
+func checkSequentialReference(doc *document) []Finding {
+	var res []Finding
+	for _, e := range doc.endpoints {
+		sequential := false
+		for _, ns := range proxyNamespaces {
+			proxyCfg, _ := e.cfg.ExtraConfig[ns].(map[string]interface{})
+			if s, ok := proxyCfg["sequential"].(bool); ok {
+				sequential = s
+			}
+		}
+		backends := len(e.cfg.Backend)
+		for i, b := range e.cfg.Backend {
+			for _, m := range reResponseReference.FindAllStringSubmatch(b.URLPattern, -1) {
+				n, _ := strconv.Atoi(m[1])
+				ref := "resp" + m[1] + "_" + m[2]
+				var msg string
+				switch {
+				case !sequential:
+					msg = fmt.Sprintf("{%s} is never replaced, as the endpoint is not sequential", ref)
+				case n >= backends:
+					msg = fmt.Sprintf("{%s} references the backend %d, but the endpoint has %d backends", ref, n, backends)
+				case n >= i:
+					msg = fmt.Sprintf("{%s} references the backend %d, which is not called before this one", ref, n)
+				default:
+					continue
+				}
+				res = append(res, Finding{Endpoint: e.key, Backend: index(i), Message: msg})
+			}
+		}
+	}
+	return res
+}
+
+// pluginNamespaces are the extra configs naming the plugins they use, as declared and once
+// normalized
+var pluginNamespaces = []string{
+	"plugin/http-server",
+	"plugin/http-client",
+	"plugin/req-resp-modifier",
+	"github_com/devopsfaith/krakend/transport/http/server/handler",
+	"github.com/devopsfaith/krakend/transport/http/client/executor",
+	"github.com/devopsfaith/krakend/proxy/plugin",
+}
+
+func checkUnusedPlugin(doc *document) []Finding {
+	if doc.cfg.Plugin == nil || doc.cfg.Plugin.Folder == "" {
+		return nil
+	}
+	folder := doc.cfg.Plugin.Folder
+	pattern := doc.cfg.Plugin.Pattern
+	if pattern == "" {
+		pattern = "*.so"
+	}
+	files, err := filepath.Glob(filepath.Join(folder, pattern))
+	if err != nil {
+		return []Finding{{Message: fmt.Sprintf("invalid plugin pattern %q: %s", pattern, err.Error())}}
+	}
+
+	used := map[string]bool{}
+	collectPluginNames(doc.cfg.ExtraConfig, used)
+	for _, e := range doc.cfg.Endpoints {
+		collectPluginNames(e.ExtraConfig, used)
+		for _, b := range e.Backend {
+			collectPluginNames(b.ExtraConfig, used)
+		}
+	}
+	for _, a := range doc.cfg.AsyncAgents {
+		collectPluginNames(a.ExtraConfig, used)
+		for _, b := range a.Backend {
+			collectPluginNames(b.ExtraConfig, used)
+		}
+	}
+
+	var res []Finding
+	for _, f := range files {
+		if info, err := os.Stat(f); err != nil || info.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		if !used[name] {
+			res = append(res, Finding{Message: fmt.Sprintf("the plugin %s is not used by any extra config", f)})
+		}
+	}
+	return res
+}
+
+// collectPluginNames adds the names of the plugins used by the extra config
+func collectPluginNames(extra config.ExtraConfig, names map[string]bool) {
+	for _, ns := range pluginNamespaces {
+		p, _ := extra[ns].(map[string]interface{})
+		switch name := p["name"].(type) {
+		case string:
+			names[name] = true
+		case []interface{}:
+			for _, n := range name {
+				if s, ok := n.(string); ok {
+					names[s] = true
+				}
+			}
+		}
+	}
+}
+
+func index(i int) *int {
+	return &i
+}
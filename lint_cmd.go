@@ -0,0 +1,128 @@
+package krakend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	cmd "github.com/krakend/krakend-cobra/v2"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/spf13/cobra"
+
+	"github.com/krakend/krakend-ce/v2/lint"
+)
+
+var (
+	lintFormat string
+	lintFailOn string
+	lintList   bool
+
+	lintCmd = &cobra.Command{
+		Use:     "lint [flags] [config]",
+		Short:   "Checks the configuration for semantic mistakes the schema validation can not catch.",
+		Example: "krakend lint --format json --fail-on warning ./krakend.json",
+	}
+
+	lintFormatFlag cmd.FlagBuilder
+	lintFailOnFlag cmd.FlagBuilder
+	lintListFlag   cmd.FlagBuilder
+)
+
+func init() {
+	lintFormatFlag = cmd.StringFlagBuilder(&lintFormat, "format", "", "text", "Output format: text or json.")
+	lintFailOnFlag = cmd.StringFlagBuilder(&lintFailOn, "fail-on", "", string(lint.SeverityError), "Lowest severity of the findings making the command fail: error, warning or info.")
+	lintListFlag = cmd.BoolFlagBuilder(&lintList, "list", "", false, "Print the catalog of rules.")
+}
+
+// NewLintCmd returns the command checking the configuration parsed with the given parser with the
+// lint rules
+func NewLintCmd(parser config.Parser) cmd.Command {
+	lintCmd.Run = func(ccmd *cobra.Command, args []string) {
+		lintFunc(ccmd, args, parser)
+	}
+	return cmd.NewCommand(lintCmd, lintFormatFlag, lintFailOnFlag, lintListFlag)
+}
+
+// rawParser is a parser able to skip the initialization of the configuration, keeping the
+// settings it replaces
+type rawParser interface {
+	ParseWithoutInit(configFile string) (config.ServiceConfig, error)
+}
+
+func lintFunc(ccmd *cobra.Command, args []string, parser config.Parser) {
+	if lintFormat != "text" && lintFormat != "json" {
+		ccmd.Println(fmt.Sprintf("[KO] Unknown output format %q.", lintFormat))
+		os.Exit(1)
+	}
+	failOn := lint.Severity(lintFailOn)
+	if !failOn.Valid() {
+		ccmd.Println(fmt.Sprintf("[KO] Unknown severity %q.", lintFailOn))
+		os.Exit(1)
+	}
+
+	if lintList {
+		rules := lint.Rules()
+		if lintFormat == "json" {
+			printLintJSON(ccmd, rules)
+			return
+		}
+		for _, r := range rules {
+			ccmd.Println(fmt.Sprintf("%s (%s): %s", r.ID, r.Severity, r.Description))
+		}
+		return
+	}
+
+	if len(args) != 1 {
+		ccmd.Println("The path to the configuration file is required.")
+		os.Exit(1)
+	}
+	parse := parser.Parse
+	if p, ok := parser.(rawParser); ok {
+		parse = p.ParseWithoutInit
+	}
+	cfg, err := parse(args[0])
+	if err != nil {
+		ccmd.Println(fmt.Sprintf("[KO] Unable to parse the configuration: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	findings, err := lint.Lint(cfg)
+	if err != nil {
+		ccmd.Println(fmt.Sprintf("[KO] Unable to lint the configuration: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, f := range findings {
+		if f.Severity.AtLeast(failOn) {
+			failed = true
+		}
+	}
+
+	if lintFormat == "json" {
+		printLintJSON(ccmd, findings)
+	} else {
+		for _, f := range findings {
+			ccmd.Println(f.String())
+		}
+		if !failed {
+			ccmd.Println(fmt.Sprintf("[OK] %d findings, none %s or worse.", len(findings), failOn))
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func printLintJSON(ccmd *cobra.Command, v interface{}) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(v); err != nil {
+		ccmd.Println(fmt.Sprintf("[KO] Unable to encode the output: %s", err.Error()))
+		os.Exit(1)
+	}
+	ccmd.Print(buf.String())
+}
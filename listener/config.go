@@ -0,0 +1,197 @@
+package listener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key used to store the additional listeners at the service ExtraConfig and the
+// listeners publishing an endpoint at its ExtraConfig
+const Namespace = "server/listeners"
+
+// Default is the name of the listener bound to the port of the service
+const Default = "default"
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// serverNamespaces are the settings of the server of the service. The additional listeners do not
+// inherit them, as they run their own servers, but they can declare them at their ExtraConfig.
+var serverNamespaces = []string{"server/tls", "server/http3", "server/unix_socket"}
+
+// Config defines the listeners bound along with the one of the service
+type Config struct {
+	Listeners []Listener `json:"listeners"`
+}
+
+// Listener is an additional port with its own router, serving the endpoints that opt in with
+// "server/listeners": { "listeners": ["<name>"] }. Add "default" to the list to keep publishing the
+// endpoint at the port of the service too.
+type Listener struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+	// TLS replaces the TLS config of the service. When empty, the service one is used.
+	TLS *TLS `json:"tls"`
+	// DebugEndpoint and EchoEndpoint replace the service settings publishing the /__debug/ and
+	// /__echo/ endpoints
+	DebugEndpoint *bool `json:"debug_endpoint"`
+	EchoEndpoint  *bool `json:"echo_endpoint"`
+	// ExtraConfig replaces the service ExtraConfig entries with the same key when building the engine
+	// of the listener. A null value removes the entry, so a component can be disabled. The server
+	// settings (server/tls, server/http3 and server/unix_socket) are not inherited from
+	// the service, so they must be declared here to apply to the listener.
+	ExtraConfig config.ExtraConfig `json:"extra_config"`
+}
+
+// TLS is the TLS config of a listener, with the keys of the tls section of the service
+type TLS struct {
+	IsDisabled               bool         `json:"disabled"`
+	PublicKey                string       `json:"public_key"`
+	PrivateKey               string       `json:"private_key"`
+	CaCerts                  []string     `json:"ca_certs"`
+	MinVersion               string       `json:"min_version"`
+	MaxVersion               string       `json:"max_version"`
+	CurvePreferences         []uint16     `json:"curve_preferences"`
+	PreferServerCipherSuites bool         `json:"prefer_server_cipher_suites"`
+	CipherSuites             []uint16     `json:"cipher_suites"`
+	EnableMTLS               bool         `json:"enable_mtls"`
+	DisableSystemCaPool      bool         `json:"disable_system_ca_pool"`
+	Keys                     []TLSKeyPair `json:"keys"`
+}
+
+// TLSKeyPair is a certificate of a listener with its private key
+type TLSKeyPair struct {
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+func (t *TLS) config() *config.TLS {
+	res := &config.TLS{
+		IsDisabled:               t.IsDisabled,
+		PublicKey:                t.PublicKey,
+		PrivateKey:               t.PrivateKey,
+		CaCerts:                  t.CaCerts,
+		MinVersion:               t.MinVersion,
+		MaxVersion:               t.MaxVersion,
+		CurvePreferences:         t.CurvePreferences,
+		PreferServerCipherSuites: t.PreferServerCipherSuites,
+		CipherSuites:             t.CipherSuites,
+		EnableMTLS:               t.EnableMTLS,
+		DisableSystemCaPool:      t.DisableSystemCaPool,
+	}
+	for _, k := range t.Keys {
+		res.Keys = append(res.Keys, config.TLSKeyPair{PublicKey: k.PublicKey, PrivateKey: k.PrivateKey})
+	}
+	return res
+}
+
+type endpointConfig struct {
+	Listeners []string `json:"listeners"`
+}
+
+// ParseConfig extracts the listeners from the service config
+func ParseConfig(cfg config.ServiceConfig) (Config, error) {
+	res := Config{}
+	if err := decode(cfg.ExtraConfig, &res); err != nil {
+		return res, err
+	}
+
+	names := map[string]struct{}{Default: {}}
+	ports := map[int]struct{}{cfg.Port: {}}
+	for i, l := range res.Listeners {
+		if l.Name == "" || l.Port == 0 {
+			return res, fmt.Errorf("the listener #%d requires a name and a port", i)
+		}
+		if _, ok := names[l.Name]; ok {
+			return res, fmt.Errorf("duplicated listener name: %s", l.Name)
+		}
+		names[l.Name] = struct{}{}
+		if _, ok := ports[l.Port]; ok {
+			return res, fmt.Errorf("listener %s: port %d already in use", l.Name, l.Port)
+		}
+		ports[l.Port] = struct{}{}
+	}
+	return res, nil
+}
+
+// Split returns the service config with the endpoints published at the default listener and the
+// service config of every additional listener, indexed by its name
+func Split(cfg config.ServiceConfig, lCfg Config) (config.ServiceConfig, map[string]config.ServiceConfig, error) {
+	listeners := make(map[string]config.ServiceConfig, len(lCfg.Listeners))
+	for _, l := range lCfg.Listeners {
+		sCfg := cfg
+		sCfg.Endpoints = nil
+		sCfg.Port = l.Port
+		if l.Address != "" {
+			sCfg.Address = l.Address
+		}
+		if l.TLS != nil {
+			sCfg.TLS = l.TLS.config()
+		}
+		if l.DebugEndpoint != nil {
+			sCfg.Debug = *l.DebugEndpoint
+		}
+		if l.EchoEndpoint != nil {
+			sCfg.Echo = *l.EchoEndpoint
+		}
+		sCfg.ExtraConfig = make(config.ExtraConfig, len(cfg.ExtraConfig)+len(l.ExtraConfig))
+		for k, v := range cfg.ExtraConfig {
+			sCfg.ExtraConfig[k] = v
+		}
+		for _, k := range serverNamespaces {
+			delete(sCfg.ExtraConfig, k)
+		}
+		for k, v := range l.ExtraConfig {
+			if v == nil {
+				delete(sCfg.ExtraConfig, k)
+				continue
+			}
+			sCfg.ExtraConfig[k] = v
+		}
+		delete(sCfg.ExtraConfig, Namespace)
+		listeners[l.Name] = sCfg
+	}
+
+	defaultCfg := cfg
+	defaultCfg.Endpoints = nil
+	for _, e := range cfg.Endpoints {
+		var eCfg endpointConfig
+		err := decode(e.ExtraConfig, &eCfg)
+		if err == ErrNoConfig {
+			defaultCfg.Endpoints = append(defaultCfg.Endpoints, e)
+			continue
+		}
+		if err != nil {
+			return defaultCfg, nil, fmt.Errorf("endpoint %s: %w", e.Endpoint, err)
+		}
+		for _, name := range eCfg.Listeners {
+			if name == Default {
+				defaultCfg.Endpoints = append(defaultCfg.Endpoints, e)
+				continue
+			}
+			sCfg, ok := listeners[name]
+			if !ok {
+				return defaultCfg, nil, fmt.Errorf("endpoint %s: unknown listener %s", e.Endpoint, name)
+			}
+			sCfg.Endpoints = append(sCfg.Endpoints, e)
+			listeners[name] = sCfg
+		}
+	}
+	return defaultCfg, listeners, nil
+}
+
+func decode(cfg config.ExtraConfig, v interface{}) error {
+	e, ok := cfg[Namespace]
+	if !ok {
+		return ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
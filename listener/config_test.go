@@ -0,0 +1,86 @@
+package listener
+
+import (
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestSplit(t *testing.T) {
+	cfg := config.ServiceConfig{
+		Port: 8080,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"listeners": []interface{}{
+					map[string]interface{}{
+						"name":           "internal",
+						"port":           9090,
+						"debug_endpoint": true,
+						"tls":            map[string]interface{}{"disabled": true},
+						"extra_config":   map[string]interface{}{"foo": nil, "bar": "internal", "server/tls": "internal"},
+					},
+				},
+			},
+			"foo":          "public",
+			"bar":          "public",
+			"server/tls":   "public",
+			"server/http3": "public",
+		},
+		TLS: &config.TLS{PublicKey: "cert.pem", PrivateKey: "key.pem"},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users"},
+			{Endpoint: "/__stats", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"listeners": []string{"internal"}}}},
+			{Endpoint: "/status", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"listeners": []string{"default", "internal"}}}},
+		},
+	}
+
+	lCfg, err := ParseConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, listeners, err := Split(cfg, lCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(def.Endpoints) != 2 || def.Endpoints[0].Endpoint != "/users" || def.Endpoints[1].Endpoint != "/status" {
+		t.Errorf("unexpected default endpoints: %v", def.Endpoints)
+	}
+	internal := listeners["internal"]
+	if len(internal.Endpoints) != 2 || internal.Endpoints[0].Endpoint != "/__stats" {
+		t.Errorf("unexpected internal endpoints: %v", internal.Endpoints)
+	}
+	if internal.Port != 9090 || !internal.Debug || def.Debug || internal.TLS == nil || !internal.TLS.IsDisabled {
+		t.Errorf("unexpected internal listener: %+v", internal)
+	}
+	if _, ok := internal.ExtraConfig["foo"]; ok {
+		t.Error("the entry should be removed")
+	}
+	if internal.ExtraConfig["bar"] != "internal" || cfg.ExtraConfig["foo"] != "public" || cfg.ExtraConfig["bar"] != "public" {
+		t.Error("unexpected extra config")
+	}
+	if _, ok := internal.ExtraConfig["server/http3"]; ok {
+		t.Error("the server settings of the service should not be inherited")
+	}
+	if internal.ExtraConfig["server/tls"] != "internal" {
+		t.Error("the server settings of the listener should be kept")
+	}
+
+	cfg.Endpoints[1].ExtraConfig[Namespace] = map[string]interface{}{"listeners": []string{"admin"}}
+	if _, _, err := Split(cfg, lCfg); err == nil {
+		t.Error("expecting an error for an unknown listener")
+	}
+}
+
+func TestParseConfig_duplicatedPort(t *testing.T) {
+	cfg := config.ServiceConfig{
+		Port: 8080,
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"listeners": []interface{}{map[string]interface{}{"name": "internal", "port": 8080}},
+			},
+		},
+	}
+	if _, err := ParseConfig(cfg); err == nil {
+		t.Error("expecting an error for a port in use")
+	}
+}
@@ -0,0 +1,103 @@
+// Package listener runs the http servers of the gateway, letting other components adjust
+// the TLS configuration before the servers start accepting connections. Besides the port of the
+// service, the endpoints can be published at additional listeners, each one with its own router.
+package listener
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	serverhttp "github.com/luraproject/lura/v2/transport/http/server"
+)
+
+// TLSModifier updates the TLS configuration of the server before it starts listening. The context
+// is cancelled when the server stops.
+type TLSModifier func(context.Context, config.ServiceConfig, *tls.Config) error
+
+// Extension prepares an additional listener sharing the handler and the TLS configuration of the
+// server, once all the TLSModifiers have been applied. It can wrap the handler of the server and
+// returns the function running the listener until the context is cancelled, or nil if there is
+// nothing to run.
+type Extension func(context.Context, config.ServiceConfig, *http.Server) (func(context.Context) error, error)
+
+// NewRunServer returns a function that starts the service like the lura default one, but applies all
+// the injected TLSModifiers to the server TLS configuration (if any) before starting the listener
+func NewRunServer(l logging.Logger, modifiers ...TLSModifier) func(context.Context, config.ServiceConfig, http.Handler) error {
+	return NewRunServerWithExtensions(l, modifiers)
+}
+
+// NewRunServerWithExtensions returns a function like NewRunServer that also runs the listeners of
+// the extensions along with the server. If any of them fails, all of them are stopped.
+func NewRunServerWithExtensions(l logging.Logger, modifiers []TLSModifier, extensions ...Extension) func(context.Context, config.ServiceConfig, http.Handler) error {
+	return func(ctx context.Context, cfg config.ServiceConfig, handler http.Handler) error {
+		s := serverhttp.NewServerWithLogger(cfg, handler, l)
+
+		if s.TLSConfig != nil {
+			for _, m := range modifiers {
+				if err := m(ctx, cfg, s.TLSConfig); err != nil {
+					return err
+				}
+			}
+		}
+
+		var runs []func(context.Context) error
+		for _, ext := range extensions {
+			run, err := ext(ctx, cfg, s)
+			if err != nil {
+				return err
+			}
+			if run != nil {
+				runs = append(runs, run)
+			}
+		}
+		if len(runs) == 0 {
+			return Serve(ctx, cfg, s)
+		}
+
+		g, gctx := errgroup.WithContext(ctx)
+		gctx, cancel := context.WithCancel(gctx)
+		defer cancel()
+		g.Go(func() error {
+			defer cancel()
+			return Serve(gctx, cfg, s)
+		})
+		for _, run := range runs {
+			run := run
+			g.Go(func() error {
+				defer cancel()
+				return run(gctx)
+			})
+		}
+		return g.Wait()
+	}
+}
+
+// Serve starts the received server and blocks until the server fails or the context is cancelled.
+// In the latter case, the server is gracefully shut down.
+func Serve(ctx context.Context, cfg config.ServiceConfig, s *http.Server) error {
+	done := make(chan error, 1)
+
+	go func() {
+		if s.TLSConfig == nil {
+			done <- s.ListenAndServe()
+			return
+		}
+		if len(s.TLSConfig.Certificates) > 0 || s.TLSConfig.GetCertificate != nil || cfg.TLS == nil {
+			done <- s.ListenAndServeTLS("", "")
+			return
+		}
+		done <- s.ListenAndServeTLS(cfg.TLS.PublicKey, cfg.TLS.PrivateKey)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return s.Shutdown(context.Background())
+	}
+}
@@ -0,0 +1,90 @@
+// Package maintenance puts the whole gateway, or the endpoints matching a set of patterns, into
+// maintenance mode, rejecting the requests with a 503 while the health checks keep working.
+package maintenance
+
+import (
+	"encoding/json"
+	"errors"
+	"path"
+
+	"github.com/luraproject/lura/v2/config"
+
+	"github.com/krakend/krakend-ce/v2/clientip"
+)
+
+// Namespace is the key used to store the maintenance config at the ExtraConfig struct
+const Namespace = "router/maintenance"
+
+const (
+	defaultRetryAfter  = 60
+	defaultTokenHeader = "X-Maintenance-Token"
+)
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// Config defines the maintenance mode
+type Config struct {
+	// Enabled sets the initial state of the maintenance mode
+	Enabled bool `json:"enabled"`
+	// Endpoints is a list of patterns (path.Match syntax) matched against the path of the request and
+	// against the endpoint definition. When empty, the maintenance mode applies to all of them.
+	Endpoints []string `json:"endpoints"`
+	// RetryAfter is the value in seconds of the Retry-After header. Default: 60
+	RetryAfter int `json:"retry_after"`
+	// Body is the json body of the responses. When not defined, the error body configured for the
+	// 503 status is used.
+	Body interface{} `json:"body"`
+	// AllowIPs is a list of IPs or CIDRs of the clients allowed to pass
+	AllowIPs []string `json:"allow_ips"`
+	// Config defines the trusted proxies and the header declaring the client IP checked against
+	// the AllowIPs
+	clientip.Config
+	// AllowTokens is a list of tokens allowed to pass when sent in the TokenHeader
+	// (default: X-Maintenance-Token)
+	AllowTokens []string `json:"allow_tokens"`
+	TokenHeader string   `json:"token_header"`
+	// AdminPath, when defined along with the AdminToken, exposes an endpoint to get (GET) and
+	// change (PUT) the state of the maintenance mode, keeping the fields not sent. The token must
+	// be sent as a bearer token.
+	AdminPath  string `json:"admin_path"`
+	AdminToken string `json:"admin_token"`
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if err := validatePatterns(res.Endpoints); err != nil {
+		return res, err
+	}
+	if res.RetryAfter <= 0 {
+		res.RetryAfter = defaultRetryAfter
+	}
+	if res.TokenHeader == "" {
+		res.TokenHeader = defaultTokenHeader
+	}
+	if (res.AdminPath == "") != (res.AdminToken == "") {
+		return res, errors.New("the admin endpoint requires both admin_path and admin_token")
+	}
+	return res, nil
+}
+
+func validatePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.New("invalid endpoint pattern: " + p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package maintenance
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/netip"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	router "github.com/luraproject/lura/v2/router/gin"
+
+	"github.com/krakend/krakend-ce/v2/clientip"
+)
+
+const (
+	logPrefix         = "[SERVICE: Gin][Maintenance]"
+	defaultHealthPath = "/__health"
+)
+
+// State is the current state of the maintenance mode
+type State struct {
+	Enabled   bool     `json:"enabled"`
+	Endpoints []string `json:"endpoints"`
+}
+
+type mode struct {
+	cfg         Config
+	state       atomic.Pointer[State]
+	allowIPs    []netip.Prefix
+	resolver    *clientip.Resolver
+	healthPaths map[string]struct{}
+	errF        func(*gin.Context, int)
+	l           logging.Logger
+}
+
+// Register checks the configuration and, if required, registers the maintenance middleware and the
+// admin endpoint at the gin engine. The errF renders the rejections when there is no body defined.
+func Register(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine, errF func(*gin.Context, int)) {
+	mCfg, err := ParseConfig(cfg.ExtraConfig)
+	if err == ErrNoConfig {
+		return
+	}
+	if err != nil {
+		l.Warning(logPrefix, err.Error())
+		return
+	}
+
+	m := &mode{
+		cfg:         mCfg,
+		healthPaths: map[string]struct{}{defaultHealthPath: {}},
+		errF:        errF,
+		l:           l,
+	}
+	if m.errF == nil {
+		m.errF = func(c *gin.Context, status int) { c.AbortWithStatus(status) }
+	}
+	if m.allowIPs, err = clientip.ParsePrefixes(mCfg.AllowIPs); err != nil {
+		l.Warning(logPrefix, err.Error())
+		return
+	}
+	if m.resolver, err = clientip.New(mCfg.Config); err != nil {
+		l.Warning(logPrefix, err.Error())
+		return
+	}
+	if p := healthPath(cfg.ExtraConfig); p != "" {
+		m.healthPaths[p] = struct{}{}
+	}
+	m.state.Store(&State{Enabled: mCfg.Enabled, Endpoints: mCfg.Endpoints})
+
+	engine.Use(m.handlerFunc)
+	if mCfg.AdminPath != "" {
+		engine.GET(mCfg.AdminPath, m.adminGet)
+		engine.PUT(mCfg.AdminPath, m.adminPut)
+	}
+	l.Debug(logPrefix, "The maintenance mode has been registered successfully. Enabled:", mCfg.Enabled)
+}
+
+func (m *mode) handlerFunc(c *gin.Context) {
+	s := m.state.Load()
+	if !s.Enabled || !m.affected(s, c) || m.allowed(c) {
+		c.Next()
+		return
+	}
+	c.Header("Retry-After", strconv.Itoa(m.cfg.RetryAfter))
+	if m.cfg.Body != nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, m.cfg.Body)
+		return
+	}
+	m.errF(c, http.StatusServiceUnavailable)
+}
+
+func (m *mode) affected(s *State, c *gin.Context) bool {
+	p := c.Request.URL.Path
+	if _, ok := m.healthPaths[p]; ok || p == m.cfg.AdminPath {
+		return false
+	}
+	if len(s.Endpoints) == 0 {
+		return true
+	}
+	for _, pattern := range s.Endpoints {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, c.FullPath()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mode) allowed(c *gin.Context) bool {
+	if token := c.GetHeader(m.cfg.TokenHeader); token != "" {
+		for _, t := range m.cfg.AllowTokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return true
+			}
+		}
+	}
+	if len(m.allowIPs) == 0 {
+		return false
+	}
+	ip := m.resolver.ClientIP(c.Request)
+	return ip.IsValid() && clientip.Contains(m.allowIPs, ip)
+}
+
+func (m *mode) adminGet(c *gin.Context) {
+	if !m.authorized(c) {
+		return
+	}
+	c.JSON(http.StatusOK, m.state.Load())
+}
+
+// stateUpdate holds the fields of the state sent to the admin endpoint. The omitted ones are kept,
+// while an empty list of endpoints puts the whole gateway in maintenance.
+type stateUpdate struct {
+	Enabled   *bool     `json:"enabled"`
+	Endpoints *[]string `json:"endpoints"`
+}
+
+func (m *mode) adminPut(c *gin.Context) {
+	if !m.authorized(c) {
+		return
+	}
+	var u stateUpdate
+	if err := c.ShouldBindJSON(&u); err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	s := *m.state.Load()
+	if u.Enabled != nil {
+		s.Enabled = *u.Enabled
+	}
+	if u.Endpoints != nil {
+		s.Endpoints = *u.Endpoints
+	}
+	if err := validatePatterns(s.Endpoints); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m.state.Store(&s)
+	m.l.Info(logPrefix, "Maintenance mode updated. Enabled:", s.Enabled, "Endpoints:", s.Endpoints)
+	c.JSON(http.StatusOK, &s)
+}
+
+func (m *mode) authorized(c *gin.Context) bool {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if ok && subtle.ConstantTimeCompare([]byte(token), []byte(m.cfg.AdminToken)) == 1 {
+		return true
+	}
+	c.AbortWithStatus(http.StatusUnauthorized)
+	return false
+}
+
+// healthPath returns the custom path of the health endpoint defined at the router config, if any
+func healthPath(cfg config.ExtraConfig) string {
+	v, ok := cfg[router.Namespace].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	p, _ := v["health_path"].(string)
+	return p
+}
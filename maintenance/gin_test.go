@@ -0,0 +1,97 @@
+package maintenance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestRegister(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"enabled":          true,
+				"endpoints":        []string{"/users/*"},
+				"retry_after":      120,
+				"allow_ips":        []string{"10.0.0.0/8"},
+				"trusted_proxies":  []string{"192.168.1.1"},
+				"client_ip_header": "X-Forwarded-For",
+				"allow_tokens":     []string{"smoke"},
+				"admin_path":       "/__maintenance",
+				"admin_token":      "secret",
+			},
+		},
+	}
+
+	engine := gin.New()
+	Register(cfg, logging.NoOp, engine, nil)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	engine.GET("/users/:id", ok)
+	engine.GET("/orders", ok)
+	engine.GET("/__health", ok)
+
+	do := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = "192.168.1.1:1234"
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("GET", "/users/1", "", nil)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "120" {
+		t.Errorf("unexpected response: %d %v", w.Code, w.Header())
+	}
+	if w := do("GET", "/users/1", "", map[string]string{"X-Maintenance-Token": "smoke"}); w.Code != http.StatusOK {
+		t.Errorf("unexpected status with token: %d", w.Code)
+	}
+	if w := do("GET", "/users/1", "", map[string]string{"X-Forwarded-For": "10.0.0.1"}); w.Code != http.StatusOK {
+		t.Errorf("unexpected status for an allowed IP: %d", w.Code)
+	}
+	if w := do("GET", "/users/1", "", map[string]string{"X-Real-Ip": "10.0.0.1", "X-Forwarded-For": "8.8.8.8"}); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status for a not allowed IP: %d", w.Code)
+	}
+	if w := do("GET", "/orders", "", nil); w.Code != http.StatusOK {
+		t.Errorf("unexpected status for an endpoint not in maintenance: %d", w.Code)
+	}
+	if w := do("GET", "/__health", "", nil); w.Code != http.StatusOK {
+		t.Errorf("unexpected status for the health endpoint: %d", w.Code)
+	}
+
+	for _, auth := range []string{"", "secret", "Basic secret", "Bearer other"} {
+		if w := do("PUT", "/__maintenance", `{"enabled":true}`, map[string]string{"Authorization": auth}); w.Code != http.StatusUnauthorized {
+			t.Errorf("unexpected status for an unauthorized admin call with %q: %d", auth, w.Code)
+		}
+	}
+	admin := map[string]string{"Authorization": "Bearer secret"}
+	if w := do("PUT", "/__maintenance", `{"enabled":false}`, admin); w.Code != http.StatusOK || w.Body.String() != `{"enabled":false,"endpoints":["/users/*"]}` {
+		t.Errorf("unexpected response for the admin call: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/users/1", "", nil); w.Code != http.StatusOK {
+		t.Errorf("unexpected status with the maintenance disabled: %d", w.Code)
+	}
+	if w := do("PUT", "/__maintenance", `{"enabled":true}`, admin); w.Code != http.StatusOK || w.Body.String() != `{"enabled":true,"endpoints":["/users/*"]}` {
+		t.Errorf("unexpected response for the admin call: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/orders", "", nil); w.Code != http.StatusOK {
+		t.Errorf("unexpected status for an endpoint not in maintenance: %d", w.Code)
+	}
+	if w := do("PUT", "/__maintenance", `{"endpoints":[]}`, admin); w.Code != http.StatusOK {
+		t.Errorf("unexpected status for the admin call: %d", w.Code)
+	}
+	if w := do("GET", "/orders", "", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status for the whole gateway in maintenance: %d", w.Code)
+	}
+	if w := do("GET", "/__maintenance", "", admin); w.Code != http.StatusOK || w.Body.String() != `{"enabled":true,"endpoints":[]}` {
+		t.Errorf("unexpected state: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,49 @@
+// Package mtls authenticates the clients of the gateway with their TLS certificates and maps
+// their identity into request headers and claims.
+package mtls
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key used to store the mTLS config at the ExtraConfig struct
+const Namespace = "auth/mtls"
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// Config defines the client certificate requirements. At the service level, only the
+// CA bundles are used, to build the pool offered during the TLS handshake.
+type Config struct {
+	// CaCerts is the list of PEM files with the CAs allowed to sign the client certificates
+	CaCerts []string `json:"ca_certs"`
+	// CRLFiles is the list of certificate revocation lists (PEM or DER) to check
+	CRLFiles []string `json:"crl_files"`
+	// OCSPResponses is the list of DER encoded OCSP responses (as stapled by the CA) to check
+	OCSPResponses []string `json:"ocsp_responses"`
+	// OCSPFailOpen accepts the certificates whose OCSP response can not be trusted (not signed by
+	// their issuer, expired or with an unknown status), logging it. They are rejected by default.
+	OCSPFailOpen bool `json:"ocsp_fail_open"`
+	// Headers maps request header names to identity fields. Example: "X-Client-CN": "subject.cn"
+	Headers map[string]string `json:"propagate_headers"`
+	// Claims maps claim names to identity fields. Example: "sub": "san.uri"
+	Claims map[string]string `json:"claims"`
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	err = json.Unmarshal(b, &res)
+	return res, err
+}
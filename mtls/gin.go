@@ -0,0 +1,138 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	router "github.com/luraproject/lura/v2/router/gin"
+
+	"github.com/krakend/krakend-ce/v2/listener"
+)
+
+// ClaimsKey is the key used to store the claims extracted from the client certificate at the gin context
+const ClaimsKey = "mtls_claims"
+
+// TLSModifier returns a listener.TLSModifier asking the clients for their certificates during the handshake
+// and adding the CAs declared at the service and endpoint levels to the pool used for verifying them.
+// Clients without certificate are still accepted, so only the endpoints with the mTLS config require them.
+func TLSModifier(l logging.Logger) listener.TLSModifier {
+	return func(_ context.Context, cfg config.ServiceConfig, tlsCfg *tls.Config) error {
+		var files []string
+		if mCfg, err := ParseConfig(cfg.ExtraConfig); err == nil {
+			files = append(files, mCfg.CaCerts...)
+		} else if err != ErrNoConfig {
+			return err
+		}
+		for _, e := range cfg.Endpoints {
+			if mCfg, err := ParseConfig(e.ExtraConfig); err == nil {
+				files = append(files, mCfg.CaCerts...)
+			}
+		}
+		if len(files) == 0 {
+			return nil
+		}
+
+		pool := tlsCfg.ClientCAs
+		if pool == nil {
+			pool = x509.NewCertPool()
+		}
+		for _, path := range files {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			pool.AppendCertsFromPEM(b)
+		}
+		tlsCfg.ClientCAs = pool
+
+		if tlsCfg.ClientAuth != tls.RequireAndVerifyClientCert {
+			tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+		l.Debug("[SERVICE: mTLS] Client certificates enabled with", len(files), "CA bundle(s)")
+		return nil
+	}
+}
+
+// HandlerFactory checks the configuration and, if required, wraps the handler factory with a
+// middleware rejecting the requests without a valid client certificate
+func HandlerFactory(hf router.HandlerFactory, l logging.Logger) router.HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		next := hf(cfg, p)
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][mTLS]"
+
+		mCfg, err := ParseConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			return next
+		}
+		if err != nil {
+			l.Warning(logPrefix, err.Error())
+			return next
+		}
+
+		v, err := NewVerifier(mCfg, l, logPrefix)
+		if err != nil {
+			l.Error(logPrefix, "Unable to create the certificate verifier:", err.Error())
+			return func(c *gin.Context) {
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}
+
+		l.Debug(logPrefix, "Client certificate authentication enabled")
+		return handler(v, mCfg, next, l, logPrefix)
+	}
+}
+
+func handler(v *Verifier, cfg Config, next gin.HandlerFunc, l logging.Logger, logPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// never trust identity headers sent by the client
+		for h := range cfg.Headers {
+			c.Request.Header.Del(h)
+		}
+
+		if c.Request.TLS == nil || len(c.Request.TLS.PeerCertificates) == 0 {
+			l.Debug(logPrefix, errNoCertificate.Error())
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		cert, err := v.Verify(c.Request.TLS.PeerCertificates)
+		if err != nil {
+			l.Warning(logPrefix, "Client certificate rejected:", err.Error())
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		for h, field := range cfg.Headers {
+			if value := headerValue(Identity(cert, field)); value != "" {
+				c.Request.Header.Set(h, value)
+			}
+		}
+
+		if len(cfg.Claims) > 0 {
+			claims := make(map[string]interface{}, len(cfg.Claims))
+			for name, field := range cfg.Claims {
+				claims[name] = Identity(cert, field)
+			}
+			c.Set(ClaimsKey, claims)
+		}
+
+		next(c)
+	}
+}
+
+// Claims returns the claims extracted from the client certificate, if any
+func Claims(c *gin.Context) map[string]interface{} {
+	v, ok := c.Get(ClaimsKey)
+	if !ok {
+		return nil
+	}
+	claims, _ := v.(map[string]interface{})
+	return claims
+}
@@ -0,0 +1,62 @@
+package mtls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+)
+
+// Identity returns the value of the requested field of the certificate. Supported fields are:
+// subject, subject.cn, subject.o, subject.ou, subject.c, issuer, issuer.cn, serial, fingerprint,
+// san.dns, san.email, san.uri and san.ip. Multi-valued fields are returned as a slice of strings.
+func Identity(cert *x509.Certificate, field string) interface{} {
+	switch strings.ToLower(field) {
+	case "subject":
+		return cert.Subject.String()
+	case "subject.cn":
+		return cert.Subject.CommonName
+	case "subject.o":
+		return cert.Subject.Organization
+	case "subject.ou":
+		return cert.Subject.OrganizationalUnit
+	case "subject.c":
+		return cert.Subject.Country
+	case "issuer":
+		return cert.Issuer.String()
+	case "issuer.cn":
+		return cert.Issuer.CommonName
+	case "serial":
+		return cert.SerialNumber.String()
+	case "fingerprint":
+		sum := sha256.Sum256(cert.Raw)
+		return hex.EncodeToString(sum[:])
+	case "san.dns":
+		return cert.DNSNames
+	case "san.email":
+		return cert.EmailAddresses
+	case "san.uri":
+		res := make([]string, len(cert.URIs))
+		for i, u := range cert.URIs {
+			res[i] = u.String()
+		}
+		return res
+	case "san.ip":
+		res := make([]string, len(cert.IPAddresses))
+		for i, ip := range cert.IPAddresses {
+			res[i] = ip.String()
+		}
+		return res
+	}
+	return nil
+}
+
+func headerValue(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []string:
+		return strings.Join(t, ",")
+	}
+	return ""
+}
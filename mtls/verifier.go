@@ -0,0 +1,173 @@
+package mtls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/luraproject/lura/v2/logging"
+	"golang.org/x/crypto/ocsp"
+)
+
+var (
+	errNoCertificate = errors.New("no client certificate")
+	errRevoked       = errors.New("client certificate revoked")
+)
+
+// Verifier checks the certificate chains presented by the clients
+type Verifier struct {
+	roots     *x509.CertPool
+	crls      []*x509.RevocationList
+	ocsp      map[string][]byte
+	failOpen  bool
+	now       func() time.Time
+	l         logging.Logger
+	logPrefix string
+}
+
+// NewVerifier loads the CA bundles, the CRLs and the OCSP responses defined in the config
+func NewVerifier(cfg Config, l logging.Logger, logPrefix string) (*Verifier, error) {
+	roots, err := LoadCertPool(cfg.CaCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Verifier{
+		roots:     roots,
+		ocsp:      map[string][]byte{},
+		failOpen:  cfg.OCSPFailOpen,
+		now:       time.Now,
+		l:         l,
+		logPrefix: logPrefix,
+	}
+
+	for _, path := range cfg.CRLFiles {
+		b, err := readPEMOrDER(path, "X509 CRL")
+		if err != nil {
+			return nil, err
+		}
+		crl, err := x509.ParseRevocationList(b)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the CRL %s: %w", path, err)
+		}
+		v.crls = append(v.crls, crl)
+	}
+
+	for _, path := range cfg.OCSPResponses {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		// the signature is checked later, once the issuer of the certificate is known
+		resp, err := ocsp.ParseResponse(b, nil)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the OCSP response %s: %w", path, err)
+		}
+		v.ocsp[resp.SerialNumber.String()] = b
+	}
+
+	return v, nil
+}
+
+// Verify checks the chain of the received certificates and returns the verified leaf
+func (v *Verifier) Verify(certs []*x509.Certificate) (*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, errNoCertificate
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	chains, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   v.now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chain := range chains {
+		for i := 0; i < len(chain)-1; i++ {
+			if err := v.checkRevocation(chain[i], chain[i+1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return certs[0], nil
+}
+
+func (v *Verifier) checkRevocation(cert, issuer *x509.Certificate) error {
+	for _, crl := range v.crls {
+		if !bytes.Equal(crl.RawIssuer, issuer.RawSubject) {
+			continue
+		}
+		if err := crl.CheckSignatureFrom(issuer); err != nil {
+			continue
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return errRevoked
+			}
+		}
+	}
+
+	raw, ok := v.ocsp[cert.SerialNumber.String()]
+	if !ok {
+		return nil
+	}
+	resp, err := ocsp.ParseResponseForCert(raw, cert, issuer)
+	switch {
+	case err != nil:
+		// the response was issued by a different CA or it is not properly signed
+		err = fmt.Errorf("invalid OCSP response for the serial %s: %w", cert.SerialNumber, err)
+	case resp.Status == ocsp.Revoked:
+		return errRevoked
+	case !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(v.now()):
+		err = fmt.Errorf("the OCSP response for the serial %s expired at %s", cert.SerialNumber, resp.NextUpdate.Format(time.RFC3339))
+	case resp.Status != ocsp.Good:
+		err = fmt.Errorf("unknown OCSP status for the serial %s", cert.SerialNumber)
+	default:
+		return nil
+	}
+
+	if !v.failOpen {
+		return err
+	}
+	v.l.Warning(v.logPrefix, "Accepting the client certificate without a trusted OCSP response:", err.Error())
+	return nil
+}
+
+// LoadCertPool returns a pool with all the certificates found at the received PEM files
+func LoadCertPool(files []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found at %s", path)
+		}
+	}
+	return pool, nil
+}
+
+func readPEMOrDER(path, blockType string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(b); block != nil && block.Type == blockType {
+		return block.Bytes, nil
+	}
+	return b, nil
+}
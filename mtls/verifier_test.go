@@ -0,0 +1,207 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/logging"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestVerifier(t *testing.T) {
+	dir := t.TempDir()
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	newClient := func(serial int64) *x509.Certificate {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		spiffe, _ := url.Parse("spiffe://example.org/ns/default/sa/client")
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "client", Organization: []string{"acme"}},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			DNSNames:     []string{"client.example.org"},
+			URIs:         []*url.URL{spiffe},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, _ := x509.ParseCertificate(der)
+		return c
+	}
+
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(3), RevocationTime: time.Now()},
+		},
+	}, ca, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caPath := filepath.Join(dir, "ca.pem")
+	crlPath := filepath.Join(dir, "ca.crl")
+	os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600)
+	os.WriteFile(crlPath, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlDER}), 0600)
+
+	v, err := NewVerifier(Config{CaCerts: []string{caPath}, CRLFiles: []string{crlPath}}, logging.NoOp, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.Verify(nil); err != errNoCertificate {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cert, err := v.Verify([]*x509.Certificate{newClient(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cn := Identity(cert, "subject.cn"); cn != "client" {
+		t.Errorf("unexpected cn: %v", cn)
+	}
+	if o := Identity(cert, "subject.o"); !reflect.DeepEqual(o, []string{"acme"}) {
+		t.Errorf("unexpected organization: %v", o)
+	}
+	if uri := headerValue(Identity(cert, "san.uri")); uri != "spiffe://example.org/ns/default/sa/client" {
+		t.Errorf("unexpected uri: %v", uri)
+	}
+
+	if _, err := v.Verify([]*x509.Certificate{newClient(3)}); err != errRevoked {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifier_ocsp(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newTestCA(t, "test CA")
+	other, otherKey := newTestCA(t, "other CA")
+	caPath := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, _ := x509.ParseCertificate(der)
+
+	for _, tc := range []struct {
+		name       string
+		issuer     *x509.Certificate
+		key        *ecdsa.PrivateKey
+		status     int
+		nextUpdate time.Time
+		err        string
+		failOpen   bool
+	}{
+		{name: "good", issuer: ca, key: caKey, status: ocsp.Good, nextUpdate: time.Now().Add(time.Hour)},
+		{name: "without next update", issuer: ca, key: caKey, status: ocsp.Good},
+		{name: "revoked", issuer: ca, key: caKey, status: ocsp.Revoked, err: errRevoked.Error()},
+		{name: "revoked failing open", issuer: ca, key: caKey, status: ocsp.Revoked, err: errRevoked.Error(), failOpen: true},
+		{name: "other issuer", issuer: other, key: otherKey, status: ocsp.Good, err: "invalid OCSP response for the serial 2: "},
+		{name: "other issuer failing open", issuer: other, key: otherKey, status: ocsp.Good, failOpen: true},
+		{name: "expired", issuer: ca, key: caKey, status: ocsp.Good, nextUpdate: time.Now().Add(-time.Minute), err: "the OCSP response for the serial 2 expired at "},
+		{name: "expired failing open", issuer: ca, key: caKey, status: ocsp.Good, nextUpdate: time.Now().Add(-time.Minute), failOpen: true},
+		{name: "unknown", issuer: ca, key: caKey, status: ocsp.Unknown, err: "unknown OCSP status for the serial 2"},
+		{name: "unknown failing open", issuer: ca, key: caKey, status: ocsp.Unknown, failOpen: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := ocsp.CreateResponse(tc.issuer, tc.issuer, ocsp.Response{
+				Status:       tc.status,
+				SerialNumber: client.SerialNumber,
+				ThisUpdate:   time.Now().Add(-2 * time.Minute),
+				NextUpdate:   tc.nextUpdate,
+				RevokedAt:    time.Now().Add(-2 * time.Minute),
+			}, tc.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ocspPath := filepath.Join(t.TempDir(), "client.ocsp")
+			os.WriteFile(ocspPath, resp, 0600)
+
+			v, err := NewVerifier(Config{
+				CaCerts:       []string{caPath},
+				OCSPResponses: []string{ocspPath},
+				OCSPFailOpen:  tc.failOpen,
+			}, logging.NoOp, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = v.Verify([]*x509.Certificate{client})
+			if tc.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err.Error())
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	garbage := filepath.Join(dir, "garbage.ocsp")
+	os.WriteFile(garbage, []byte("garbage"), 0600)
+	if _, err := NewVerifier(Config{CaCerts: []string{caPath}, OCSPResponses: []string{garbage}}, logging.NoOp, ""); err == nil {
+		t.Error("expecting an error for an unparseable OCSP response")
+	}
+}
+
+func newTestCA(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := x509.ParseCertificate(der)
+	return c, key
+}
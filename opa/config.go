@@ -0,0 +1,75 @@
+// Package opa authorizes the requests of an endpoint with the decisions of an embedded Open Policy
+// Agent evaluating Rego policies loaded from local bundles.
+//
+// The input of the decision has the same data available to the CEL policies: method, path,
+// params, headers, querystring, ip, jwt and mtls. The query can return a boolean, allowing the
+// request when it is true, or an object like
+//
+//	{"allow": true, "headers": {"X-Tenant": "acme"}}
+//
+// adding its headers to the request sent to the backends. The added headers must be declared as
+// input_headers of the endpoint to reach them. An undefined decision denies the request.
+package opa
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/luraproject/lura/v2/config"
+
+	"github.com/krakend/krakend-ce/v2/clientip"
+)
+
+// Namespace is the key used to store the OPA config at the endpoint ExtraConfig struct
+const Namespace = "auth/opa"
+
+const defaultQuery = "data.krakend.allow"
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+var errNoBundles = errors.New("at least one bundle is required")
+
+// Config defines the bundles to load, the decision to query and the response to return when the
+// request is denied
+type Config struct {
+	// Bundles are the paths to the bundle directories or tarballs with the policies and their data
+	Bundles []string `json:"bundles"`
+	// Query is the decision to evaluate. Default: data.krakend.allow
+	Query string `json:"query"`
+	// ErrorStatus is the status code returned when the request is denied. Default: 403
+	ErrorStatus int `json:"error_status"`
+	// ErrorBody is the json body returned when the request is denied. Default: no body
+	ErrorBody interface{} `json:"error_body"`
+	// DecisionLogs logs every decision with the logger of the service
+	DecisionLogs bool `json:"decision_logs"`
+	// Config defines the trusted proxies and the header declaring the client IP of the input
+	clientip.Config
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if len(res.Bundles) == 0 {
+		return res, errNoBundles
+	}
+	if res.Query == "" {
+		res.Query = defaultQuery
+	}
+	if res.ErrorStatus == 0 {
+		res.ErrorStatus = http.StatusForbidden
+	}
+	return res, nil
+}
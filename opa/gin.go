@@ -0,0 +1,160 @@
+package opa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	router "github.com/luraproject/lura/v2/router/gin"
+	"github.com/open-policy-agent/opa/v1/rego"
+
+	"github.com/krakend/krakend-ce/v2/clientip"
+	"github.com/krakend/krakend-ce/v2/policy"
+)
+
+// HandlerFactory checks the configuration and, if required, wraps the handler factory with a
+// middleware querying the decision of the endpoint. It must be placed after the JWT validator and
+// the mTLS middleware, so their claims are part of the input. The claims are only added for the
+// endpoints declaring the JWT validator.
+func HandlerFactory(hf router.HandlerFactory, l logging.Logger, claimsF policy.ClaimsFactory) router.HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		next := hf(cfg, p)
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][OPA]"
+
+		oCfg, err := ParseConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			return next
+		}
+		if err != nil {
+			l.Error(logPrefix, err.Error())
+			return deniedHandler(http.StatusInternalServerError, nil)
+		}
+
+		d, err := New(context.Background(), oCfg)
+		if err != nil {
+			l.Error(logPrefix, "Unable to prepare the decision:", err.Error())
+			return deniedHandler(http.StatusInternalServerError, nil)
+		}
+
+		resolver, err := clientip.New(oCfg.Config)
+		if err != nil {
+			l.Error(logPrefix, err.Error())
+			return deniedHandler(http.StatusInternalServerError, nil)
+		}
+
+		claims := func(*http.Request) map[string]interface{} { return nil }
+		if claimsF != nil {
+			if f := claimsF(cfg); f != nil {
+				claims = f
+			}
+		}
+		deny := deniedHandler(oCfg.ErrorStatus, oCfg.ErrorBody)
+
+		l.Debug(logPrefix, "Querying", oCfg.Query, "from", len(oCfg.Bundles), "bundle(s)")
+
+		return func(c *gin.Context) {
+			in := policy.NewInput(c, resolver, claims(c.Request), nil)
+			res, err := d.Eval(c.Request.Context(), in)
+			if oCfg.DecisionLogs {
+				logDecision(l, logPrefix, oCfg.Query, in, res, err)
+			}
+			if err != nil {
+				l.Error(logPrefix, "Unable to evaluate the decision:", err.Error())
+				deny(c)
+				return
+			}
+			if !res.Allow {
+				deny(c)
+				return
+			}
+			for k, v := range res.Headers {
+				c.Request.Header.Set(k, v)
+			}
+			next(c)
+		}
+	}
+}
+
+// Decision is the result of the query for a request
+type Decision struct {
+	Allow bool `json:"allow"`
+	// Headers are added to the request sent to the backends
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Decider evaluates a prepared query
+type Decider struct {
+	query rego.PreparedEvalQuery
+}
+
+// New loads the bundles and prepares the query of the config
+func New(ctx context.Context, cfg Config) (*Decider, error) {
+	opts := []func(*rego.Rego){rego.Query(cfg.Query)}
+	for _, b := range cfg.Bundles {
+		opts = append(opts, rego.LoadBundle(b))
+	}
+	q, err := rego.New(opts...).PrepareForEval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Decider{query: q}, nil
+}
+
+// Eval queries the decision for the input. An undefined decision denies the request.
+func (d *Decider) Eval(ctx context.Context, in policy.Input) (Decision, error) {
+	rs, err := d.query.Eval(ctx, rego.EvalInput(in))
+	if err != nil {
+		return Decision{}, err
+	}
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return Decision{}, nil
+	}
+
+	switch v := rs[0].Expressions[0].Value.(type) {
+	case bool:
+		return Decision{Allow: v}, nil
+	case map[string]interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return Decision{}, err
+		}
+		res := Decision{}
+		if err := json.Unmarshal(b, &res); err != nil {
+			return Decision{}, fmt.Errorf("unexpected decision %s: %w", string(b), err)
+		}
+		return res, nil
+	default:
+		return Decision{}, fmt.Errorf("unexpected decision of type %T", v)
+	}
+}
+
+func logDecision(l logging.Logger, logPrefix, query string, in policy.Input, res Decision, err error) {
+	entry := map[string]interface{}{
+		"query":  query,
+		"method": in.Method,
+		"path":   in.Path,
+		"ip":     in.IP,
+		"allow":  res.Allow,
+	}
+	if err != nil {
+		entry["error"] = err.Error()
+	}
+	b, _ := json.Marshal(entry)
+	l.Info(logPrefix, "Decision:", string(b))
+}
+
+func deniedHandler(status int, body interface{}) gin.HandlerFunc {
+	if body == nil {
+		return func(c *gin.Context) {
+			c.AbortWithStatus(status)
+		}
+	}
+	return func(c *gin.Context) {
+		c.AbortWithStatusJSON(status, body)
+	}
+}
@@ -0,0 +1,137 @@
+package opa
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+const testPolicy = `package krakend
+
+default allow := false
+
+allow if {
+	input.method == "GET"
+	input.jwt.sub == input.params.user
+}
+
+decision := {"allow": true, "headers": {"X-Tenant": data.tenants[input.jwt.sub]}} if allow
+`
+
+func TestHandlerFactory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "policy.rego"), []byte(testPolicy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), []byte(`{"tenants":{"alice":"acme"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(*config.EndpointConfig) func(*http.Request) map[string]interface{} {
+		return func(r *http.Request) map[string]interface{} {
+			return map[string]interface{}{"sub": r.Header.Get("X-Sub")}
+		}
+	}
+	hf := HandlerFactory(func(*config.EndpointConfig, proxy.Proxy) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.String(http.StatusOK, c.Request.Header.Get("X-Tenant"))
+		}
+	}, logging.NoOp, claims)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	for endpoint, query := range map[string]string{"/allow/:user": "", "/decision/:user": "data.krakend.decision"} {
+		cfg := &config.EndpointConfig{
+			Endpoint: endpoint,
+			ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+				"bundles":      []interface{}{dir},
+				"query":        query,
+				"error_body":   map[string]interface{}{"error": "denied"},
+				"error_status": 401,
+			}},
+		}
+		engine.GET(endpoint, hf(cfg, nil))
+	}
+
+	for _, tc := range []struct {
+		path, sub, body string
+		status          int
+	}{
+		{path: "/allow/alice", sub: "alice", status: http.StatusOK},
+		{path: "/allow/alice", sub: "bob", status: http.StatusUnauthorized, body: `{"error":"denied"}`},
+		{path: "/decision/alice", sub: "alice", status: http.StatusOK, body: "acme"},
+		{path: "/decision/alice", sub: "bob", status: http.StatusUnauthorized, body: `{"error":"denied"}`},
+	} {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Header.Set("X-Sub", tc.sub)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != tc.status || w.Body.String() != tc.body {
+			t.Errorf("%s as %s: unexpected response: %d %s", tc.path, tc.sub, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestHandlerFactory_wrongBundle(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint:    "/",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"bundles": []interface{}{"./unknown"}}},
+	}
+	hf := HandlerFactory(func(*config.EndpointConfig, proxy.Proxy) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			t.Error("unexpected call to the next handler")
+		}
+	}, logging.NoOp, nil)
+	engine := gin.New()
+	engine.GET("/", hf(cfg, nil))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+}
+
+func TestHandlerFactory_clientIP(t *testing.T) {
+	dir := t.TempDir()
+	policy := "package krakend\n\ndefault allow := false\n\nallow if input.ip == \"10.0.0.1\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "policy.rego"), []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	hf := HandlerFactory(func(*config.EndpointConfig, proxy.Proxy) gin.HandlerFunc {
+		return func(c *gin.Context) { c.Status(http.StatusOK) }
+	}, logging.NoOp, nil)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/untrusted", hf(&config.EndpointConfig{
+		Endpoint:    "/untrusted",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"bundles": []interface{}{dir}}},
+	}, nil))
+	engine.GET("/trusted", hf(&config.EndpointConfig{
+		Endpoint: "/trusted",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"bundles":          []interface{}{dir},
+			"trusted_proxies":  []interface{}{"192.0.2.1"},
+			"client_ip_header": "X-Forwarded-For",
+		}},
+	}, nil))
+
+	for path, status := range map[string]int{"/untrusted": http.StatusForbidden, "/trusted": http.StatusOK} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("%s: unexpected status code: %d", path, w.Code)
+		}
+	}
+}
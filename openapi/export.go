@@ -0,0 +1,309 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+// ExportNamespace is the key used to store the export config at the service ExtraConfig
+const ExportNamespace = "documentation/openapi"
+
+// JWTValidatorNamespace is the namespace of the JWT validation of the endpoints
+const JWTValidatorNamespace = "auth/validator"
+
+const (
+	defaultExportPath = "/__openapi.json"
+	securityScheme    = "bearerAuth"
+)
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+var reParam = regexp.MustCompile(`/[:*]([^/]+)`)
+
+// ExportConfig defines the document describing the endpoints of the gateway
+type ExportConfig struct {
+	// Path is the path serving the document. Default: /__openapi.json
+	Path        string `json:"path"`
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+	// Servers are the urls the gateway is reached at
+	Servers []string `json:"servers"`
+}
+
+// ParseExportConfig extracts the export config from the service ExtraConfig
+func ParseExportConfig(cfg config.ExtraConfig) (ExportConfig, error) {
+	res := ExportConfig{}
+	e, ok := cfg[ExportNamespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if res.Path == "" {
+		res.Path = defaultExportPath
+	}
+	return res, nil
+}
+
+// Register serves the OpenAPI document of the endpoints of the service, if configured
+func Register(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine) {
+	logPrefix := "[SERVICE: Gin][OpenAPI]"
+	eCfg, err := ParseExportConfig(cfg.ExtraConfig)
+	if err == ErrNoConfig {
+		return
+	}
+	if err != nil {
+		l.Warning(logPrefix, err.Error())
+		return
+	}
+	b, err := json.Marshal(Export(cfg, eCfg))
+	if err != nil {
+		l.Warning(logPrefix, err.Error())
+		return
+	}
+	for _, e := range cfg.Endpoints {
+		if collides(e, eCfg.Path) {
+			l.Error(logPrefix, "The path of the document is served by the endpoint", e.Method, e.Endpoint, "so the document is not served")
+			return
+		}
+	}
+	engine.GET(eCfg.Path, func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", b)
+	})
+	l.Debug(logPrefix, "Serving the OpenAPI document at", eCfg.Path)
+}
+
+// Export returns the OpenAPI 3.1 document describing the endpoints of the service: their
+// parameters, the JSON schema of their bodies, their JWT validation and their output encoding
+func Export(cfg config.ServiceConfig, eCfg ExportConfig) map[string]interface{} {
+	info := map[string]interface{}{
+		"title":   eCfg.Title,
+		"version": eCfg.Version,
+	}
+	if eCfg.Title == "" {
+		info["title"] = cfg.Name
+	}
+	if info["title"] == "" {
+		info["title"] = "KrakenD"
+	}
+	if eCfg.Version == "" {
+		info["version"] = "1.0.0"
+	}
+	if eCfg.Description != "" {
+		info["description"] = eCfg.Description
+	}
+
+	paths := map[string]interface{}{}
+	operationIDs := map[string]bool{}
+	secured := false
+	for _, e := range cfg.Endpoints {
+		path, params := exportPath(e.Endpoint)
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		method := strings.ToUpper(e.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+		op := operation(cfg, e, method, path, params)
+		op["operationId"] = uniqueID(op["operationId"].(string), operationIDs)
+		if _, ok := op["security"]; ok {
+			secured = true
+		}
+		item[strings.ToLower(method)] = op
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.1.0",
+		"info":    info,
+		"paths":   paths,
+	}
+	if len(eCfg.Servers) > 0 {
+		servers := make([]interface{}, len(eCfg.Servers))
+		for i, s := range eCfg.Servers {
+			servers[i] = map[string]interface{}{"url": s}
+		}
+		doc["servers"] = servers
+	}
+	if secured {
+		doc["components"] = map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				securityScheme: map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+			},
+		}
+	}
+	return doc
+}
+
+// exportPath returns the OpenAPI path of the endpoint with its params
+func exportPath(endpoint string) (string, []string) {
+	path := reParam.ReplaceAllString(endpoint, "/{$1}")
+	var params []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, segment[1:len(segment)-1])
+		}
+	}
+	return path, params
+}
+
+func operation(cfg config.ServiceConfig, e *config.EndpointConfig, method, path string, pathParams []string) map[string]interface{} {
+	var params []interface{}
+	for _, p := range pathParams {
+		params = append(params, parameter(p, "path", true))
+	}
+	for _, p := range sortedNames(e.QueryString) {
+		params = append(params, parameter(p, "query", false))
+	}
+	for _, h := range sortedNames(e.HeadersToPass) {
+		params = append(params, parameter(h, "header", false))
+	}
+
+	op := map[string]interface{}{
+		"operationId": operationID(method, path),
+		"responses":   responses(cfg, e),
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if schema, ok := e.ExtraConfig[JSONSchemaNamespace].(map[string]interface{}); ok {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schema},
+			},
+		}
+	}
+	if v, ok := e.ExtraConfig[JWTValidatorNamespace].(map[string]interface{}); ok {
+		roles := []interface{}{}
+		if r, ok := v["roles"].([]interface{}); ok {
+			roles = r
+		}
+		op["security"] = []interface{}{map[string]interface{}{securityScheme: roles}}
+		op["responses"].(map[string]interface{})["401"] = map[string]interface{}{"description": "Missing or invalid token"}
+	}
+	return op
+}
+
+func parameter(name, in string, required bool) map[string]interface{} {
+	p := map[string]interface{}{
+		"name":   name,
+		"in":     in,
+		"schema": map[string]interface{}{"type": "string"},
+	}
+	if required {
+		p["required"] = true
+	}
+	return p
+}
+
+// responses describes the successful response of the endpoint by its output encoding
+func responses(cfg config.ServiceConfig, e *config.EndpointConfig) map[string]interface{} {
+	encoding := e.OutputEncoding
+	if encoding == "" {
+		encoding = cfg.OutputEncoding
+	}
+
+	object := map[string]interface{}{"type": "object"}
+	content := map[string]interface{}{}
+	switch encoding {
+	case "no-op":
+		return map[string]interface{}{
+			"default": map[string]interface{}{"description": "The response of the backend"},
+		}
+	case "json-collection":
+		content["application/json"] = map[string]interface{}{"schema": map[string]interface{}{"type": "array"}}
+	case "xml":
+		content["application/xml"] = map[string]interface{}{"schema": object}
+	case "string":
+		content["text/plain"] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+	case "negotiate":
+		for _, ct := range []string{"application/json", "application/xml", "application/x-yaml"} {
+			content[ct] = map[string]interface{}{"schema": object}
+		}
+	default:
+		content["application/json"] = map[string]interface{}{"schema": object}
+	}
+	return map[string]interface{}{
+		"200": map[string]interface{}{"description": "Successful response", "content": content},
+	}
+}
+
+func operationID(method, path string) string {
+	parts := []string{strings.ToLower(method)}
+	for _, segment := range strings.Split(path, "/") {
+		segment = strings.Trim(segment, "{}")
+		if segment != "" {
+			parts = append(parts, segment)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// uniqueID adds a numeric suffix to the operation id when it is already used, as the params and
+// the static segments of the paths render the same
+func uniqueID(id string, used map[string]bool) string {
+	res := id
+	for i := 2; used[res]; i++ {
+		res = fmt.Sprintf("%s_%d", id, i)
+	}
+	used[res] = true
+	return res
+}
+
+// collides reports if the GET requests to the path are routed to the endpoint, as the router can
+// not register the document along with it
+func collides(e *config.EndpointConfig, path string) bool {
+	if e.Method != "" && !strings.EqualFold(e.Method, http.MethodGet) {
+		return false
+	}
+	route := strings.Split(e.Endpoint, "/")
+	segments := strings.Split(path, "/")
+	for i, r := range route {
+		if strings.HasPrefix(r, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		isParam := strings.HasPrefix(r, ":") || (strings.HasPrefix(r, "{") && strings.HasSuffix(r, "}"))
+		if r != segments[i] && (!isParam || segments[i] == "") {
+			return false
+		}
+	}
+	return len(route) == len(segments)
+}
+
+func sortedNames(names []string) []string {
+	res := make([]string, 0, len(names))
+	for _, n := range names {
+		// the wildcards can not be described
+		if n != "*" {
+			res = append(res, n)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,102 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestExport(t *testing.T) {
+	cfg := config.ServiceConfig{
+		Name: "shop",
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint:      "/users/:id",
+				Method:        "GET",
+				QueryString:   []string{"fields", "*"},
+				HeadersToPass: []string{"X-Tenant"},
+				ExtraConfig: config.ExtraConfig{
+					JWTValidatorNamespace: map[string]interface{}{"alg": "RS256", "roles": []interface{}{"admin"}},
+				},
+			},
+			{
+				Endpoint:       "/users",
+				Method:         "POST",
+				OutputEncoding: "no-op",
+				ExtraConfig: config.ExtraConfig{
+					JSONSchemaNamespace: map[string]interface{}{"type": "object", "required": []interface{}{"name"}},
+				},
+			},
+			{Endpoint: "/files/*path", Method: "GET", OutputEncoding: "string"},
+		},
+	}
+
+	b, _ := json.Marshal(Export(cfg, ExportConfig{Version: "2.0.0", Servers: []string{"https://api.example.com"}}))
+	expected := `{"components":{"securitySchemes":{"bearerAuth":{"bearerFormat":"JWT","scheme":"bearer","type":"http"}}},` +
+		`"info":{"title":"shop","version":"2.0.0"},"openapi":"3.1.0","paths":{` +
+		`"/files/{path}":{"get":{"operationId":"get_files_path","parameters":[{"in":"path","name":"path","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"text/plain":{"schema":{"type":"string"}}},"description":"Successful response"}}}},` +
+		`"/users":{"post":{"operationId":"post_users","requestBody":{"content":{"application/json":{"schema":{"required":["name"],"type":"object"}}},"required":true},"responses":{"default":{"description":"The response of the backend"}}}},` +
+		`"/users/{id}":{"get":{"operationId":"get_users_id","parameters":[{"in":"path","name":"id","required":true,"schema":{"type":"string"}},{"in":"query","name":"fields","schema":{"type":"string"}},{"in":"header","name":"X-Tenant","schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Successful response"},"401":{"description":"Missing or invalid token"}},"security":[{"bearerAuth":["admin"]}]}}` +
+		`},"servers":[{"url":"https://api.example.com"}]}`
+	if string(b) != expected {
+		t.Errorf("unexpected document:\n%s", b)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	Register(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{ExportNamespace: map[string]interface{}{"title": "docs"}},
+		Endpoints:   []*config.EndpointConfig{{Endpoint: "/foo", Method: "GET"}},
+	}, logging.NoOp, engine)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", defaultExportPath, nil))
+	b, _ := io.ReadAll(w.Body)
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("unexpected response %d: %s", w.Code, b)
+	}
+	if doc["info"].(map[string]interface{})["title"] != "docs" || doc["paths"].(map[string]interface{})["/foo"] == nil {
+		t.Errorf("unexpected document: %s", b)
+	}
+}
+
+func TestRegister_collision(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, path := range []string{defaultExportPath, "/:file", "/*path"} {
+		engine := gin.New()
+		cfg := config.ServiceConfig{
+			ExtraConfig: config.ExtraConfig{ExportNamespace: map[string]interface{}{}},
+			Endpoints: []*config.EndpointConfig{
+				{Endpoint: "/users/:id", Method: "GET"},
+				{Endpoint: path, Method: "GET"},
+			},
+		}
+		Register(cfg, logging.NoOp, engine)
+		if routes := engine.Routes(); len(routes) != 0 {
+			t.Errorf("%s: unexpected routes %v", path, routes)
+		}
+	}
+}
+
+func TestExport_uniqueOperationID(t *testing.T) {
+	doc := Export(config.ServiceConfig{Endpoints: []*config.EndpointConfig{
+		{Endpoint: "/users/:id", Method: "GET"},
+		{Endpoint: "/users/id", Method: "GET"},
+		{Endpoint: "/users/{id}", Method: "POST"},
+	}}, ExportConfig{})
+	paths := doc["paths"].(map[string]interface{})
+	for path, expected := range map[string]string{"/users/{id}": "get_users_id", "/users/id": "get_users_id_2"} {
+		op := paths[path].(map[string]interface{})["get"].(map[string]interface{})
+		if op["operationId"] != expected {
+			t.Errorf("%s: unexpected operationId %v", path, op["operationId"])
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package openapi converts between the OpenAPI documents of the services and the configuration of
+// the gateway.
+package openapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// JSONSchemaNamespace is the namespace of the validation of the request bodies
+const JSONSchemaNamespace = "validation/json-schema"
+
+var methodOrder = map[string]int{
+	"GET": 0, "HEAD": 1, "OPTIONS": 2, "POST": 3, "PUT": 4, "PATCH": 5, "DELETE": 6,
+}
+
+// ImportOptions customizes the endpoints generated from a document
+type ImportOptions struct {
+	// Host replaces the scheme and host of the servers of the document. It is required when the
+	// servers are relative or missing.
+	Host string
+	// Prefix is prepended to the path of the endpoints
+	Prefix string
+}
+
+// Load reads and validates the OpenAPI 3 document, resolving its references to local files. The
+// references to remote documents are rejected.
+func Load(ctx context.Context, path string) (*openapi3.T, error) {
+	return load(ctx, path, openapi3.ReadFromFile)
+}
+
+// LoadWithRemoteRefs reads and validates the OpenAPI 3 document like Load, also fetching the
+// remote documents it references
+func LoadWithRemoteRefs(ctx context.Context, path string) (*openapi3.T, error) {
+	return load(ctx, path, nil)
+}
+
+// load reads the document with the reader of the references, or with the default one of the
+// loader (reading local files and remote documents) when nil
+func load(ctx context.Context, path string, read openapi3.ReadFromURIFunc) (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	loader.Context = ctx
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = read
+	doc, err := loader.LoadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(ctx); err != nil {
+		return nil, fmt.Errorf("invalid document %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// Endpoints returns the config of an endpoint for every operation of the document, sorted by path
+// and method. Every endpoint gets a single backend, sending the operation to the server.
+func Endpoints(doc *openapi3.T, opts ImportOptions) ([]map[string]interface{}, error) {
+	if doc.Paths == nil {
+		return nil, nil
+	}
+	items := doc.Paths.Map()
+	paths := make([]string, 0, len(items))
+	for p := range items {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var res []map[string]interface{}
+	for _, path := range paths {
+		item := items[path]
+		ops := item.Operations()
+		methods := make([]string, 0, len(ops))
+		for m := range ops {
+			methods = append(methods, m)
+		}
+		sort.Slice(methods, func(i, j int) bool { return methodOrder[methods[i]] < methodOrder[methods[j]] })
+
+		for _, method := range methods {
+			op := ops[method]
+			servers := doc.Servers
+			if len(item.Servers) > 0 {
+				servers = item.Servers
+			}
+			if op.Servers != nil && len(*op.Servers) > 0 {
+				servers = *op.Servers
+			}
+			host, basePath, err := server(servers, opts.Host)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			res = append(res, endpoint(path, method, item, op, host, basePath, opts.Prefix))
+		}
+	}
+	return res, nil
+}
+
+func endpoint(path, method string, item *openapi3.PathItem, op *openapi3.Operation, host, basePath, prefix string) map[string]interface{} {
+	e := map[string]interface{}{
+		"endpoint": prefix + path,
+		"method":   method,
+	}
+	if op.Summary != "" {
+		e["@comment"] = op.Summary
+	}
+
+	var queryStrings, headers []string
+	for _, p := range parameters(item, op) {
+		switch p.In {
+		case openapi3.ParameterInQuery:
+			queryStrings = append(queryStrings, p.Name)
+		case openapi3.ParameterInHeader:
+			headers = append(headers, p.Name)
+		}
+	}
+	if len(queryStrings) > 0 {
+		sort.Strings(queryStrings)
+		e["input_query_strings"] = queryStrings
+	}
+	if len(headers) > 0 {
+		sort.Strings(headers)
+		e["input_headers"] = headers
+	}
+
+	if body := op.RequestBody; body != nil && body.Value != nil {
+		if mt := jsonMediaType(body.Value.Content); mt != nil && mt.Schema != nil {
+			e["extra_config"] = map[string]interface{}{JSONSchemaNamespace: JSONSchema(mt.Schema)}
+		}
+	}
+
+	b := map[string]interface{}{
+		"host":        []string{host},
+		"url_pattern": basePath + path,
+		"method":      method,
+	}
+	if content := successContent(op); len(content) > 0 {
+		if mt := jsonMediaType(content); mt == nil {
+			e["output_encoding"] = "no-op"
+			b["encoding"] = "no-op"
+		} else if mt.Schema != nil && mt.Schema.Value != nil && mt.Schema.Value.Type.Is(openapi3.TypeArray) {
+			b["is_collection"] = true
+		}
+	}
+	e["backend"] = []interface{}{b}
+	return e
+}
+
+// parameters returns the parameters of the operation, overriding the ones of the path
+func parameters(item *openapi3.PathItem, op *openapi3.Operation) []*openapi3.Parameter {
+	var res []*openapi3.Parameter
+	index := map[string]int{}
+	for _, params := range []openapi3.Parameters{item.Parameters, op.Parameters} {
+		for _, p := range params {
+			if p == nil || p.Value == nil {
+				continue
+			}
+			key := p.Value.In + ":" + p.Value.Name
+			if i, ok := index[key]; ok {
+				res[i] = p.Value
+				continue
+			}
+			index[key] = len(res)
+			res = append(res, p.Value)
+		}
+	}
+	return res
+}
+
+// successContent returns the content of the first successful response of the operation
+func successContent(op *openapi3.Operation) openapi3.Content {
+	if op.Responses == nil {
+		return nil
+	}
+	codes := make([]string, 0, op.Responses.Len())
+	for code := range op.Responses.Map() {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if r := op.Responses.Value(code); r != nil && r.Value != nil && len(r.Value.Content) > 0 {
+			return r.Value.Content
+		}
+	}
+	return nil
+}
+
+func jsonMediaType(content openapi3.Content) *openapi3.MediaType {
+	if mt := content.Get("application/json"); mt != nil {
+		return mt
+	}
+	for ct, mt := range content {
+		if strings.HasSuffix(strings.SplitN(ct, ";", 2)[0], "+json") {
+			return mt
+		}
+	}
+	return nil
+}
+
+// server returns the host and the base path of the first server, replacing its variables with
+// their default values
+func server(servers openapi3.Servers, host string) (string, string, error) {
+	var raw string
+	if len(servers) > 0 {
+		raw = servers[0].URL
+		for name, v := range servers[0].Variables {
+			raw = strings.ReplaceAll(raw, "{"+name+"}", v.Default)
+		}
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", err
+	}
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if host != "" {
+		return strings.TrimSuffix(host, "/"), basePath, nil
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", "", errors.New("the server url is not absolute, so a host is required")
+	}
+	return u.Scheme + "://" + u.Host, basePath, nil
+}
@@ -0,0 +1,197 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const spec = `
+openapi: 3.0.3
+info:
+  title: users
+  version: "1.0"
+servers:
+  - url: https://{env}.example.com/v1
+    variables:
+      env:
+        default: api
+paths:
+  /users:
+    get:
+      summary: List the users
+      parameters:
+        - {name: page, in: query, schema: {type: integer}}
+        - {name: X-Tenant, in: header, schema: {type: string}}
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: "#/components/schemas/User"}
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/User"}
+      responses:
+        "201": {description: created}
+  /users/{id}/avatar:
+    parameters:
+      - {name: id, in: path, required: true, schema: {type: string}}
+    get:
+      responses:
+        "200":
+          description: ok
+          content:
+            image/png: {}
+components:
+  schemas:
+    User:
+      type: object
+      required: [id, name]
+      properties:
+        id: {type: string, readOnly: true}
+        name: {type: string, minLength: 1}
+        email: {type: string, format: email, nullable: true}
+`
+
+func TestEndpoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	if err := os.WriteFile(path, []byte(spec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Load(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints, err := Endpoints(doc, ImportOptions{Prefix: "/api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := json.Marshal(endpoints)
+	expected := `[` +
+		`{"@comment":"List the users","backend":[{"host":["https://api.example.com"],"is_collection":true,"method":"GET","url_pattern":"/v1/users"}],"endpoint":"/api/users","input_headers":["X-Tenant"],"input_query_strings":["page"],"method":"GET"},` +
+		`{"backend":[{"host":["https://api.example.com"],"method":"POST","url_pattern":"/v1/users"}],"endpoint":"/api/users","extra_config":{"validation/json-schema":{"properties":{"email":{"format":"email","type":["string","null"]},"name":{"minLength":1,"type":"string"}},"required":["name"],"type":"object"}},"method":"POST"},` +
+		`{"backend":[{"encoding":"no-op","host":["https://api.example.com"],"method":"GET","url_pattern":"/v1/users/{id}/avatar"}],"endpoint":"/api/users/{id}/avatar","method":"GET","output_encoding":"no-op"}` +
+		`]`
+	if string(b) != expected {
+		t.Errorf("unexpected endpoints:\n%s", b)
+	}
+}
+
+func TestLoad_refs(t *testing.T) {
+	const schema = `{"type": "object", "properties": {"id": {"type": "string"}}}`
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, schema)
+	}))
+	defer remote.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "user.json"), []byte(schema), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	document := func(ref string) string {
+		return `{"openapi": "3.0.3", "info": {"title": "users", "version": "1"}, "paths": {"/users": {"get": {"responses": {"200": {
+			"description": "ok", "content": {"application/json": {"schema": {"$ref": "` + ref + `"}}}}}}}}}`
+	}
+	local, remoteRef := filepath.Join(dir, "local.json"), filepath.Join(dir, "remote.json")
+	os.WriteFile(local, []byte(document("user.json")), 0o600)
+	os.WriteFile(remoteRef, []byte(document(remote.URL+"/user.json")), 0o600)
+
+	if _, err := Load(context.Background(), local); err != nil {
+		t.Errorf("unexpected error loading a local reference: %s", err.Error())
+	}
+	if _, err := Load(context.Background(), remoteRef); err == nil {
+		t.Error("expecting an error loading a remote reference")
+	}
+	if _, err := LoadWithRemoteRefs(context.Background(), remoteRef); err != nil {
+		t.Errorf("unexpected error loading a remote reference: %s", err.Error())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	var cfg map[string]interface{}
+	json.Unmarshal([]byte(`{"version":3,"endpoints":[
+		{"endpoint":"/users","method":"GET","timeout":"1s","backend":[
+			{"host":["http://old"],"url_pattern":"/users","extra_config":{"qos/ratelimit/proxy":{"max_rate":10}}},
+			{"host":["http://profiles"],"url_pattern":"/profiles","group":"profiles"}
+		]},
+		{"endpoint":"/health","backend":[{"host":["http://old"],"url_pattern":"/health"}]}
+	]}`), &cfg)
+	generated := []map[string]interface{}{
+		{"endpoint": "/users", "method": "GET", "backend": []interface{}{map[string]interface{}{"host": []string{"http://new"}, "url_pattern": "/users"}}},
+		{"endpoint": "/users", "method": "POST", "backend": []interface{}{map[string]interface{}{"host": []string{"http://new"}, "url_pattern": "/users"}}},
+	}
+
+	changes, err := Merge(cfg, generated, StrategyUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(cfg["endpoints"])
+	expected := `[` +
+		`{"backend":[{"extra_config":{"qos/ratelimit/proxy":{"max_rate":10}},"host":["http://new"],"url_pattern":"/users"},{"group":"profiles","host":["http://profiles"],"url_pattern":"/profiles"}],"endpoint":"/users","method":"GET","timeout":"1s"},` +
+		`{"backend":[{"host":["http://old"],"url_pattern":"/health"}],"endpoint":"/health"},` +
+		`{"backend":[{"host":["http://new"],"url_pattern":"/users"}],"endpoint":"/users","method":"POST"}` +
+		`]`
+	if string(b) != expected {
+		t.Errorf("unexpected endpoints:\n%s", b)
+	}
+
+	diff := Diff(changes)
+	for _, line := range []string{
+		"~ GET /users (updated)",
+		`-           "http://old"`,
+		`+           "http://new"`,
+		"+ POST /users (added)",
+	} {
+		if !strings.Contains(diff, line+"\n") {
+			t.Errorf("the diff does not contain %q:\n%s", line, diff)
+		}
+	}
+
+	if _, err := Merge(cfg, generated, "unknown"); err == nil {
+		t.Error("expecting an error for an unknown strategy")
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	sources := []Source{
+		{Path: "users.yaml", Endpoints: []map[string]interface{}{
+			{"endpoint": "/users/{id}", "method": "GET"},
+			{"endpoint": "/users", "method": "POST"},
+		}},
+		{Path: "legacy.yaml", Endpoints: []map[string]interface{}{
+			{"endpoint": "/users/{name}", "method": "GET"},
+			{"endpoint": "/users/{name}", "method": "DELETE"},
+			{"endpoint": "/users", "method": "POST"},
+			{"endpoint": "/files/*path", "method": "GET"},
+		}},
+		{Path: "files.yaml", Endpoints: []map[string]interface{}{
+			{"endpoint": "/files/*name", "method": "GET"},
+		}},
+	}
+	collisions := Collisions(sources)
+	expected := []string{
+		"GET /users/{name} from legacy.yaml collides with /users/{id} from users.yaml",
+		"POST /users from legacy.yaml collides with /users from users.yaml",
+		"GET /files/*name from files.yaml collides with /files/*path from legacy.yaml",
+	}
+	if len(collisions) != len(expected) {
+		t.Fatalf("unexpected collisions: %v", collisions)
+	}
+	for i := range expected {
+		if collisions[i] != expected[i] {
+			t.Errorf("unexpected collision #%d: %s", i, collisions[i])
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package openapi
+
+import (
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// JSONSchema converts the OpenAPI 3.0 schema into a JSON schema (draft 7) with all the references
+// inlined. The recursive references are replaced by an empty schema, accepting any value.
+func JSONSchema(ref *openapi3.SchemaRef) map[string]interface{} {
+	return jsonSchema(ref, map[*openapi3.Schema]bool{})
+}
+
+func jsonSchema(ref *openapi3.SchemaRef, visiting map[*openapi3.Schema]bool) map[string]interface{} {
+	res := map[string]interface{}{}
+	if ref == nil || ref.Value == nil || visiting[ref.Value] {
+		return res
+	}
+	s := ref.Value
+	visiting[s] = true
+	defer delete(visiting, s)
+
+	if types := s.Type.Slice(); len(types) > 0 {
+		if s.Nullable {
+			types = append(append([]string{}, types...), "null")
+		}
+		if len(types) == 1 {
+			res["type"] = types[0]
+		} else {
+			res["type"] = types
+		}
+	}
+	if s.Format != "" {
+		res["format"] = s.Format
+	}
+	if len(s.Enum) > 0 {
+		res["enum"] = s.Enum
+	}
+	if s.Default != nil {
+		res["default"] = s.Default
+	}
+
+	for key, refs := range map[string]openapi3.SchemaRefs{"allOf": s.AllOf, "anyOf": s.AnyOf, "oneOf": s.OneOf} {
+		if len(refs) == 0 {
+			continue
+		}
+		l := make([]interface{}, len(refs))
+		for i, r := range refs {
+			l[i] = jsonSchema(r, visiting)
+		}
+		res[key] = l
+	}
+	if s.Not != nil {
+		res["not"] = jsonSchema(s.Not, visiting)
+	}
+
+	if s.Min != nil {
+		if s.ExclusiveMin {
+			res["exclusiveMinimum"] = *s.Min
+		} else {
+			res["minimum"] = *s.Min
+		}
+	}
+	if s.Max != nil {
+		if s.ExclusiveMax {
+			res["exclusiveMaximum"] = *s.Max
+		} else {
+			res["maximum"] = *s.Max
+		}
+	}
+	if s.MultipleOf != nil {
+		res["multipleOf"] = *s.MultipleOf
+	}
+
+	if s.MinLength > 0 {
+		res["minLength"] = s.MinLength
+	}
+	if s.MaxLength != nil {
+		res["maxLength"] = *s.MaxLength
+	}
+	if s.Pattern != "" {
+		res["pattern"] = s.Pattern
+	}
+
+	if s.Items != nil {
+		res["items"] = jsonSchema(s.Items, visiting)
+	}
+	if s.MinItems > 0 {
+		res["minItems"] = s.MinItems
+	}
+	if s.MaxItems != nil {
+		res["maxItems"] = *s.MaxItems
+	}
+	if s.UniqueItems {
+		res["uniqueItems"] = true
+	}
+
+	if len(s.Properties) > 0 {
+		props := make(map[string]interface{}, len(s.Properties))
+		for name, p := range s.Properties {
+			// the read only properties are not sent by the clients
+			if p.Value != nil && p.Value.ReadOnly {
+				continue
+			}
+			props[name] = jsonSchema(p, visiting)
+		}
+		res["properties"] = props
+	}
+	if len(s.Required) > 0 {
+		required := make([]string, 0, len(s.Required))
+		for _, name := range s.Required {
+			if p, ok := s.Properties[name]; ok && p.Value != nil && p.Value.ReadOnly {
+				continue
+			}
+			required = append(required, name)
+		}
+		if len(required) > 0 {
+			res["required"] = required
+		}
+	}
+	if s.MinProps > 0 {
+		res["minProperties"] = s.MinProps
+	}
+	if s.MaxProps != nil {
+		res["maxProperties"] = *s.MaxProps
+	}
+	if ap := s.AdditionalProperties; ap.Schema != nil {
+		res["additionalProperties"] = jsonSchema(ap.Schema, visiting)
+	} else if ap.Has != nil {
+		res["additionalProperties"] = *ap.Has
+	}
+
+	return res
+}
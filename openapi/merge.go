@@ -0,0 +1,234 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/krakend/krakend-ce/v2/lint"
+)
+
+// Strategy defines how the generated endpoints are merged with the existing ones, matched by
+// method and path
+type Strategy string
+
+const (
+	// StrategyUpdate overwrites the generated keys of the existing endpoints, keeping the rest of
+	// them. The extra config is merged by namespace and the backends by position.
+	StrategyUpdate Strategy = "update"
+	// StrategyReplace replaces the existing endpoints
+	StrategyReplace Strategy = "replace"
+	// StrategyKeep keeps the existing endpoints untouched
+	StrategyKeep Strategy = "keep"
+)
+
+// Change is the result of merging a generated endpoint
+type Change struct {
+	Method   string
+	Endpoint string
+	// Before is nil for the added endpoints
+	Before map[string]interface{}
+	After  map[string]interface{}
+}
+
+// Merge adds the endpoints to the service config, returning the changes. The existing endpoints
+// not generated are kept, as they may not come from the documents.
+func Merge(cfg map[string]interface{}, endpoints []map[string]interface{}, s Strategy) ([]Change, error) {
+	switch s {
+	case StrategyUpdate, StrategyReplace, StrategyKeep:
+	default:
+		return nil, fmt.Errorf("unknown merge strategy %q", s)
+	}
+	if cfg["version"] == nil {
+		cfg["version"] = 3
+	}
+	existing, _ := cfg["endpoints"].([]interface{})
+	index := map[string]int{}
+	for i, e := range existing {
+		if m, ok := e.(map[string]interface{}); ok {
+			index[endpointKey(m)] = i
+		}
+	}
+
+	changes := make([]Change, 0, len(endpoints))
+	for _, e := range endpoints {
+		key := endpointKey(e)
+		c := Change{Method: e["method"].(string), Endpoint: e["endpoint"].(string)}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(existing)
+			existing = append(existing, e)
+			c.After = e
+			changes = append(changes, c)
+			continue
+		}
+
+		c.Before = existing[i].(map[string]interface{})
+		switch s {
+		case StrategyKeep:
+			c.After = c.Before
+		case StrategyReplace:
+			c.After = e
+		default:
+			c.After = mergeValue(c.Before, e).(map[string]interface{})
+		}
+		existing[i] = c.After
+		changes = append(changes, c)
+	}
+	cfg["endpoints"] = existing
+	return changes, nil
+}
+
+// Source is an OpenAPI document and the endpoints generated from it
+type Source struct {
+	Path      string
+	Endpoints []map[string]interface{}
+}
+
+// Collisions reports the endpoints generated more than once with the same method and path, as
+// the router can not register them together, even with different param names
+func Collisions(sources []Source) []string {
+	type origin struct {
+		path   string
+		source string
+	}
+	seen := map[string]origin{}
+	var res []string
+	for _, s := range sources {
+		for _, e := range s.Endpoints {
+			method, _ := e["method"].(string)
+			path, _ := e["endpoint"].(string)
+			key := strings.ToUpper(method) + " " + lint.RouteShape(path)
+			first, ok := seen[key]
+			if !ok {
+				seen[key] = origin{path: path, source: s.Path}
+				continue
+			}
+			res = append(res, fmt.Sprintf("%s %s from %s collides with %s from %s", strings.ToUpper(method), path, s.Path, first.path, first.source))
+		}
+	}
+	return res
+}
+
+func endpointKey(e map[string]interface{}) string {
+	method, _ := e["method"].(string)
+	if method == "" {
+		method = "GET"
+	}
+	path, _ := e["endpoint"].(string)
+	return strings.ToUpper(method) + " " + path
+}
+
+// mergeValue returns a copy of the old value with the new one on top of it
+func mergeValue(old, v interface{}) interface{} {
+	switch n := v.(type) {
+	case map[string]interface{}:
+		o, ok := old.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		res := make(map[string]interface{}, len(o)+len(n))
+		for k, x := range o {
+			res[k] = x
+		}
+		for k, x := range n {
+			// the validation schemas are generated as a whole
+			if k == JSONSchemaNamespace {
+				res[k] = x
+				continue
+			}
+			res[k] = mergeValue(o[k], x)
+		}
+		return res
+	case []interface{}:
+		o, ok := old.([]interface{})
+		if !ok {
+			return v
+		}
+		// the lists of objects, like the backends, are merged by position, keeping the objects
+		// not generated
+		res := make([]interface{}, len(n), max(len(n), len(o)))
+		for i, x := range n {
+			if _, isMap := x.(map[string]interface{}); isMap && i < len(o) {
+				res[i] = mergeValue(o[i], x)
+			} else {
+				res[i] = x
+			}
+		}
+		for _, x := range o[min(len(n), len(o)):] {
+			if _, isMap := x.(map[string]interface{}); isMap {
+				res = append(res, x)
+			}
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// Diff describes the changes, with a line diff of the JSON of the updated endpoints
+func Diff(changes []Change) string {
+	var b strings.Builder
+	for _, c := range changes {
+		after := prettyJSON(c.After)
+		if c.Before == nil {
+			fmt.Fprintf(&b, "+ %s %s (added)\n", c.Method, c.Endpoint)
+			for _, l := range after {
+				b.WriteString("+   " + l + "\n")
+			}
+			continue
+		}
+		before := prettyJSON(c.Before)
+		if reflect.DeepEqual(before, after) {
+			fmt.Fprintf(&b, "  %s %s (unchanged)\n", c.Method, c.Endpoint)
+			continue
+		}
+		fmt.Fprintf(&b, "~ %s %s (updated)\n", c.Method, c.Endpoint)
+		for _, l := range diffLines(before, after) {
+			b.WriteString(l + "\n")
+		}
+	}
+	return b.String()
+}
+
+func prettyJSON(v interface{}) []string {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	return strings.Split(string(b), "\n")
+}
+
+// diffLines returns the lines of both versions, prefixed with - when removed and + when added
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var res []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			res = append(res, "    "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			res = append(res, "-   "+a[i])
+			i++
+		default:
+			res = append(res, "+   "+b[j])
+			j++
+		}
+	}
+	return res
+}
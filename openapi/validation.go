@@ -0,0 +1,347 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// ValidationNamespace is the key used to store the validation config at the endpoint ExtraConfig
+const ValidationNamespace = "validation/openapi"
+
+const (
+	// ModeEnforce rejects the invalid requests and responses
+	ModeEnforce = "enforce"
+	// ModeLog logs the invalid requests and responses, letting them through
+	ModeLog = "log"
+)
+
+// ValidationConfig maps an endpoint to an operation of an OpenAPI document
+type ValidationConfig struct {
+	// Spec is the path to the OpenAPI 3 document. It can only reference other local files.
+	Spec string `json:"spec"`
+	// OperationID selects the operation by its id. Without it, the operation is selected by
+	// Method and Path, defaulting to the ones of the endpoint.
+	OperationID string `json:"operation_id"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	// Mode is either enforce or log. Default: enforce
+	Mode string `json:"mode"`
+	// ValidateResponses validates the response of the endpoint too, as rendered to the client: the
+	// merged response of the backends, unwrapped from the collection key with the json-collection
+	// output encoding, or the response of the backend as it is with the no-op one. The merged
+	// responses are validated against the 200 status, as the router sends them with it. The
+	// endpoints with any other output encoding (xml, yaml, string...) can not validate responses.
+	ValidateResponses bool `json:"validate_responses"`
+}
+
+// ParseValidationConfig extracts the validation config from the endpoint ExtraConfig
+func ParseValidationConfig(cfg config.ExtraConfig) (ValidationConfig, error) {
+	res := ValidationConfig{}
+	e, ok := cfg[ValidationNamespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if res.Spec == "" {
+		return res, fmt.Errorf("the path to the OpenAPI document is required")
+	}
+	switch res.Mode {
+	case "":
+		res.Mode = ModeEnforce
+	case ModeEnforce, ModeLog:
+	default:
+		return res, fmt.Errorf("unknown validation mode %q", res.Mode)
+	}
+	return res, nil
+}
+
+// validationError is returned when a request or a response does not match the operation
+type validationError struct {
+	err    error
+	status int
+}
+
+func (v validationError) Error() string { return v.err.Error() }
+
+// StatusCode returns the status code of the response rendered by the router
+func (v validationError) StatusCode() int { return v.status }
+
+// ProxyFactory validates the requests, and optionally the responses, of the endpoints against
+// an operation of an OpenAPI document. The params, the query strings and the headers are the
+// ones the endpoint accepts, so the validated ones must be declared at the endpoint. The
+// documents are loaded once, no matter how many endpoints use them. The invalid requests and
+// responses are counted in the registry, if any, as openapi.<endpoint>.invalid.<request|response>.
+func ProxyFactory(l logging.Logger, registry gometrics.Registry, next proxy.Factory) proxy.Factory {
+	var mu sync.Mutex
+	docs := map[string]*openapi3.T{}
+	load := func(path string) (*openapi3.T, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if doc, ok := docs[path]; ok {
+			return doc, nil
+		}
+		doc, err := Load(context.Background(), path)
+		if err != nil {
+			return nil, err
+		}
+		docs[path] = doc
+		return doc, nil
+	}
+
+	return proxy.FactoryFunc(func(cfg *config.EndpointConfig) (proxy.Proxy, error) {
+		p, err := next.New(cfg)
+		if err != nil {
+			return p, err
+		}
+		vCfg, err := ParseValidationConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			return p, nil
+		}
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][OpenAPI]"
+		if err != nil {
+			l.Error(logPrefix, err.Error())
+			return nil, err
+		}
+
+		doc, err := load(vCfg.Spec)
+		if err != nil {
+			l.Error(logPrefix, err.Error())
+			return nil, err
+		}
+		route, err := findRoute(doc, vCfg, cfg)
+		if err != nil {
+			l.Error(logPrefix, err.Error())
+			return nil, err
+		}
+
+		v := &validator{
+			cfg:        vCfg,
+			route:      route,
+			pathParams: pathParams(route),
+			next:       p,
+			invalid:    map[string]gometrics.Counter{"request": gometrics.NilCounter{}, "response": gometrics.NilCounter{}},
+			l:          l,
+			logPrefix:  logPrefix,
+		}
+		if registry != nil {
+			for phase := range v.invalid {
+				v.invalid[phase] = gometrics.GetOrRegisterCounter("openapi."+cfg.Endpoint+".invalid."+phase, registry)
+			}
+		}
+		if vCfg.ValidateResponses {
+			switch cfg.OutputEncoding {
+			case "", "json", "safejson", "fast-json", "negotiate":
+			case encodingCollection:
+				v.collection = true
+			case encodingNoop:
+			default:
+				l.Warning(logPrefix, "The responses can not be validated with the", cfg.OutputEncoding, "output encoding")
+				v.cfg.ValidateResponses = false
+			}
+		}
+
+		l.Debug(logPrefix, "Validating against the operation", route.Method, route.Path, "in", vCfg.Mode, "mode")
+		return v.proxy, nil
+	})
+}
+
+// findRoute returns the operation of the document mapped to the endpoint
+func findRoute(doc *openapi3.T, vCfg ValidationConfig, cfg *config.EndpointConfig) (*routers.Route, error) {
+	if doc.Paths == nil {
+		return nil, fmt.Errorf("the document %s has no paths", vCfg.Spec)
+	}
+	if vCfg.OperationID != "" {
+		for path, item := range doc.Paths.Map() {
+			for method, op := range item.Operations() {
+				if op.OperationID == vCfg.OperationID {
+					return &routers.Route{Spec: doc, Path: path, PathItem: item, Method: method, Operation: op}, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("unknown operation %q in %s", vCfg.OperationID, vCfg.Spec)
+	}
+
+	path := vCfg.Path
+	if path == "" {
+		path, _ = exportPath(cfg.Endpoint)
+	}
+	method := strings.ToUpper(vCfg.Method)
+	if method == "" {
+		method = strings.ToUpper(cfg.Method)
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	if item := doc.Paths.Find(path); item != nil {
+		if op := item.GetOperation(method); op != nil {
+			return &routers.Route{Spec: doc, Path: path, PathItem: item, Method: method, Operation: op}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %s %s in %s", method, path, vCfg.Spec)
+}
+
+// pathParams returns the names of the path params of the operation
+func pathParams(route *routers.Route) []string {
+	var res []string
+	for _, ps := range []openapi3.Parameters{route.PathItem.Parameters, route.Operation.Parameters} {
+		for _, p := range ps {
+			if p.Value != nil && p.Value.In == openapi3.ParameterInPath {
+				res = append(res, p.Value.Name)
+			}
+		}
+	}
+	return res
+}
+
+const (
+	encodingCollection = "json-collection"
+	encodingNoop       = "no-op"
+	// collectionKey is the key where the arrays are wrapped into the response data
+	collectionKey = "collection"
+)
+
+type validator struct {
+	cfg        ValidationConfig
+	route      *routers.Route
+	pathParams []string
+	next       proxy.Proxy
+	// collection unwraps the data rendered with the json-collection output encoding
+	collection bool
+	invalid    map[string]gometrics.Counter
+	l          logging.Logger
+	logPrefix  string
+}
+
+func (v *validator) proxy(ctx context.Context, req *proxy.Request) (*proxy.Response, error) {
+	input, err := v.input(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := openapi3filter.ValidateRequest(ctx, input); err != nil {
+		if v.reject("request", err) {
+			return nil, validationError{err: err, status: http.StatusBadRequest}
+		}
+	}
+
+	resp, err := v.next(ctx, req)
+	if err != nil || resp == nil || !v.cfg.ValidateResponses {
+		return resp, err
+	}
+
+	rInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 resp.Metadata.StatusCode,
+		Header:                 http.Header{},
+		Options:                input.Options,
+	}
+	if rInput.Status == 0 {
+		rInput.Status = http.StatusOK
+	}
+	if resp.Io != nil {
+		b, err := io.ReadAll(resp.Io)
+		if err != nil {
+			return resp, err
+		}
+		resp.Io = bytes.NewReader(b)
+		for k, vs := range resp.Metadata.Headers {
+			rInput.Header[k] = vs
+		}
+		rInput.SetBodyBytes(b)
+	} else {
+		var data interface{} = resp.Data
+		if v.collection {
+			data = resp.Data[collectionKey]
+		}
+		b, err := json.Marshal(data)
+		if err != nil {
+			return resp, err
+		}
+		rInput.Header.Set("Content-Type", "application/json")
+		rInput.SetBodyBytes(b)
+	}
+	if err := openapi3filter.ValidateResponse(ctx, rInput); err != nil {
+		if v.reject("response", err) {
+			return nil, validationError{err: err, status: http.StatusBadGateway}
+		}
+	}
+	return resp, nil
+}
+
+// input builds the request to validate, keeping the body readable by the next proxies
+func (v *validator) input(ctx context.Context, req *proxy.Request) (*openapi3filter.RequestValidationInput, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	u := &url.URL{Path: req.Path, RawQuery: req.Query.Encode()}
+	r, err := http.NewRequestWithContext(ctx, v.route.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range req.Headers {
+		r.Header[k] = vs
+	}
+	if len(body) > 0 && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	// the router capitalizes the names of the params
+	params := map[string]string{}
+	for _, name := range v.pathParams {
+		for k, value := range req.Params {
+			if strings.EqualFold(k, name) {
+				params[name] = value
+			}
+		}
+	}
+
+	return &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: params,
+		Route:      v.route,
+		Options: &openapi3filter.Options{
+			// the gateway authenticates the requests by itself
+			AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			SkipSettingDefaults: true,
+		},
+	}, nil
+}
+
+// reject records the validation error and reports if the request must be rejected
+func (v *validator) reject(phase string, err error) bool {
+	v.invalid[phase].Inc(1)
+	if v.cfg.Mode == ModeLog {
+		v.l.Warning(v.logPrefix, "Invalid", phase+":", err.Error())
+		return false
+	}
+	v.l.Debug(v.logPrefix, "Rejecting the invalid", phase+":", err.Error())
+	return true
+}
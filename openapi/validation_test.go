@@ -0,0 +1,194 @@
+package openapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+const validationSpec = `
+openapi: 3.0.3
+info: {title: users, version: "1.0"}
+paths:
+  /users/{id}:
+    put:
+      operationId: updateUser
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: integer}}
+        - {name: dry_run, in: query, schema: {type: boolean}}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name]
+              properties:
+                name: {type: string, minLength: 1}
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [id]
+                properties:
+                  id: {type: integer}
+`
+
+func TestProxyFactory(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "users.yaml")
+	if err := os.WriteFile(spec, []byte(validationSpec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var body string
+	respData := map[string]interface{}{"id": 42}
+	next := proxy.FactoryFunc(func(*config.EndpointConfig) (proxy.Proxy, error) {
+		return func(_ context.Context, req *proxy.Request) (*proxy.Response, error) {
+			b, _ := io.ReadAll(req.Body)
+			body = string(b)
+			return &proxy.Response{Data: respData, IsComplete: true}, nil
+		}, nil
+	})
+
+	for _, tc := range []struct {
+		name     string
+		mode     string
+		params   map[string]string
+		query    map[string][]string
+		body     string
+		response map[string]interface{}
+		status   int
+	}{
+		{name: "valid", params: map[string]string{"Id": "42"}, body: `{"name":"bob"}`, response: map[string]interface{}{"id": 42}},
+		{name: "invalid param", params: map[string]string{"Id": "bob"}, body: `{"name":"bob"}`, status: http.StatusBadRequest},
+		{name: "invalid query", params: map[string]string{"Id": "42"}, query: map[string][]string{"dry_run": {"maybe"}}, body: `{"name":"bob"}`, status: http.StatusBadRequest},
+		{name: "invalid body", params: map[string]string{"Id": "42"}, body: `{"name":""}`, status: http.StatusBadRequest},
+		{name: "invalid response", params: map[string]string{"Id": "42"}, body: `{"name":"bob"}`, response: map[string]interface{}{"id": "42"}, status: http.StatusBadGateway},
+		{name: "log only", mode: ModeLog, params: map[string]string{"Id": "bob"}, body: `{"name":""}`, response: map[string]interface{}{"id": "42"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			extra := map[string]interface{}{"spec": spec, "validate_responses": true}
+			if tc.mode != "" {
+				extra["mode"] = tc.mode
+			}
+			p, err := ProxyFactory(logging.NoOp, nil, next).New(&config.EndpointConfig{
+				Endpoint:    "/users/:id",
+				Method:      "PUT",
+				ExtraConfig: config.ExtraConfig{ValidationNamespace: extra},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body = ""
+			respData = tc.response
+			resp, err := p(context.Background(), &proxy.Request{
+				Method: "PUT",
+				Path:   "/users/42",
+				Params: tc.params,
+				Query:  tc.query,
+				Body:   io.NopCloser(strings.NewReader(tc.body)),
+			})
+			if tc.status != 0 {
+				e, ok := err.(validationError)
+				if !ok || e.StatusCode() != tc.status {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err != nil || resp == nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if body != tc.body {
+				t.Errorf("the body was not forwarded: %q", body)
+			}
+		})
+	}
+}
+
+func TestProxyFactory_unknownOperation(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "users.yaml")
+	if err := os.WriteFile(spec, []byte(validationSpec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	next := proxy.FactoryFunc(func(*config.EndpointConfig) (proxy.Proxy, error) { return proxy.NoopProxy, nil })
+
+	for _, extra := range []map[string]interface{}{
+		{"spec": spec, "operation_id": "deleteUser"},
+		{"spec": spec},
+		{"spec": spec, "operation_id": "updateUser", "mode": "strict"},
+	} {
+		if _, err := ProxyFactory(logging.NoOp, nil, next).New(&config.EndpointConfig{
+			Endpoint:    "/users/:id",
+			Method:      "GET",
+			ExtraConfig: config.ExtraConfig{ValidationNamespace: extra},
+		}); err == nil {
+			t.Errorf("expecting an error for %v", extra)
+		}
+	}
+}
+
+const collectionSpec = `
+openapi: 3.0.3
+info: {title: users, version: "1.0"}
+paths:
+  /users:
+    get:
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {type: object, required: [id]}
+`
+
+func TestProxyFactory_collection(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "users.yaml")
+	if err := os.WriteFile(spec, []byte(collectionSpec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var items []interface{}
+	next := proxy.FactoryFunc(func(*config.EndpointConfig) (proxy.Proxy, error) {
+		return func(context.Context, *proxy.Request) (*proxy.Response, error) {
+			return &proxy.Response{Data: map[string]interface{}{"collection": items}, IsComplete: true}, nil
+		}, nil
+	})
+	registry := gometrics.NewRegistry()
+	p, err := ProxyFactory(logging.NoOp, registry, next).New(&config.EndpointConfig{
+		Endpoint:       "/users",
+		Method:         "GET",
+		OutputEncoding: "json-collection",
+		ExtraConfig:    config.ExtraConfig{ValidationNamespace: map[string]interface{}{"spec": spec, "validate_responses": true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items = []interface{}{map[string]interface{}{"id": 1}}
+	if _, err := p(context.Background(), &proxy.Request{Method: "GET", Path: "/users"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	items = []interface{}{map[string]interface{}{"name": "bob"}}
+	if _, err := p(context.Background(), &proxy.Request{Method: "GET", Path: "/users"}); err == nil {
+		t.Error("expecting an error for an invalid item")
+	}
+	if c := gometrics.GetOrRegisterCounter("openapi./users.invalid.response", registry); c.Count() != 1 {
+		t.Errorf("unexpected number of invalid responses: %d", c.Count())
+	}
+}
@@ -0,0 +1,214 @@
+package krakend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	cmd "github.com/krakend/krakend-cobra/v2"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/spf13/cobra"
+
+	"github.com/krakend/krakend-ce/v2/openapi"
+)
+
+var (
+	openAPIHost     string
+	openAPIPrefix   string
+	openAPIBase     string
+	openAPIOutput   string
+	openAPIStrategy string
+	openAPIDryRun   bool
+	openAPIInPlace  bool
+	openAPIRemote   bool
+
+	importOpenAPICmd = &cobra.Command{
+		Use:     "import-openapi [flags] [documents]",
+		Short:   "Generates the endpoints of the gateway from one or more OpenAPI 3 documents.",
+		Run:     importOpenAPIFunc,
+		Example: "krakend import-openapi -b ./krakend.json --in-place ./users.yaml ./orders.json",
+	}
+
+	openAPIHostFlag     cmd.FlagBuilder
+	openAPIPrefixFlag   cmd.FlagBuilder
+	openAPIBaseFlag     cmd.FlagBuilder
+	openAPIOutputFlag   cmd.FlagBuilder
+	openAPIStrategyFlag cmd.FlagBuilder
+	openAPIDryRunFlag   cmd.FlagBuilder
+	openAPIInPlaceFlag  cmd.FlagBuilder
+	openAPIRemoteFlag   cmd.FlagBuilder
+
+	exportOpenAPIOutput  string
+	exportOpenAPITitle   string
+	exportOpenAPIVersion string
+
+	exportOpenAPICmd = &cobra.Command{
+		Use:     "export-openapi [flags] [config]",
+		Short:   "Generates the OpenAPI 3.1 document of the endpoints of the gateway.",
+		Example: "krakend export-openapi -o ./openapi.json ./krakend.json",
+	}
+
+	exportOpenAPIOutputFlag  cmd.FlagBuilder
+	exportOpenAPITitleFlag   cmd.FlagBuilder
+	exportOpenAPIVersionFlag cmd.FlagBuilder
+)
+
+func init() {
+	openAPIHostFlag = cmd.StringFlagBuilder(&openAPIHost, "host", "", "", "Host of the backends, replacing the servers of the documents.")
+	openAPIPrefixFlag = cmd.StringFlagBuilder(&openAPIPrefix, "prefix", "", "", "Prefix of the path of the generated endpoints.")
+	openAPIBaseFlag = cmd.StringFlagBuilder(&openAPIBase, "base", "b", "", "Path to the configuration file the endpoints are merged into.")
+	openAPIOutputFlag = cmd.StringFlagBuilder(&openAPIOutput, "output", "o", "", "Path to the generated configuration file. Default: the standard output.")
+	openAPIStrategyFlag = cmd.StringFlagBuilder(&openAPIStrategy, "merge", "m", string(openapi.StrategyUpdate), "How the existing endpoints are merged: update, replace or keep.")
+	openAPIDryRunFlag = cmd.BoolFlagBuilder(&openAPIDryRun, "dry-run", "", false, "Print the changes instead of writing the configuration.")
+	openAPIInPlaceFlag = cmd.BoolFlagBuilder(&openAPIInPlace, "in-place", "i", false, "Write the generated configuration over the base file.")
+	openAPIRemoteFlag = cmd.BoolFlagBuilder(&openAPIRemote, "remote-refs", "", false, "Fetch the remote documents referenced by the documents.")
+	exportOpenAPIOutputFlag = cmd.StringFlagBuilder(&exportOpenAPIOutput, "output", "o", "", "Path to the generated document. Default: the standard output.")
+	exportOpenAPITitleFlag = cmd.StringFlagBuilder(&exportOpenAPITitle, "title", "", "", "Title of the document, overriding the one in the configuration.")
+	exportOpenAPIVersionFlag = cmd.StringFlagBuilder(&exportOpenAPIVersion, "api-version", "", "", "Version of the document, overriding the one in the configuration.")
+}
+
+// NewImportOpenAPICmd returns the command generating the endpoints from OpenAPI documents
+func NewImportOpenAPICmd() cmd.Command {
+	return cmd.NewCommand(importOpenAPICmd, openAPIHostFlag, openAPIPrefixFlag, openAPIBaseFlag, openAPIOutputFlag, openAPIStrategyFlag, openAPIDryRunFlag, openAPIInPlaceFlag, openAPIRemoteFlag)
+}
+
+func importOpenAPIFunc(ccmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		ccmd.Println("At least one OpenAPI document is required.")
+		os.Exit(1)
+	}
+	if openAPIInPlace && (openAPIBase == "" || openAPIOutput != "") {
+		ccmd.Println("[KO] Writing in place requires a base configuration and no output.")
+		os.Exit(1)
+	}
+
+	var endpoints []map[string]interface{}
+	var sources []openapi.Source
+	opts := openapi.ImportOptions{Host: openAPIHost, Prefix: openAPIPrefix}
+	load := openapi.Load
+	if openAPIRemote {
+		load = openapi.LoadWithRemoteRefs
+	}
+	for _, path := range args {
+		doc, err := load(context.Background(), path)
+		if err != nil {
+			ccmd.Println(fmt.Sprintf("[KO] Unable to load %s: %s", path, err.Error()))
+			os.Exit(1)
+		}
+		es, err := openapi.Endpoints(doc, opts)
+		if err != nil {
+			ccmd.Println(fmt.Sprintf("[KO] Unable to convert %s: %s", path, err.Error()))
+			os.Exit(1)
+		}
+		endpoints = append(endpoints, es...)
+		sources = append(sources, openapi.Source{Path: path, Endpoints: es})
+	}
+	if collisions := openapi.Collisions(sources); len(collisions) > 0 {
+		for _, c := range collisions {
+			ccmd.Println(fmt.Sprintf("[KO] %s", c))
+		}
+		os.Exit(1)
+	}
+
+	cfg := map[string]interface{}{}
+	if openAPIBase != "" {
+		b, err := os.ReadFile(openAPIBase)
+		if err != nil {
+			ccmd.Println(fmt.Sprintf("[KO] Unable to read the base configuration: %s", err.Error()))
+			os.Exit(1)
+		}
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		if err := d.Decode(&cfg); err != nil {
+			ccmd.Println(fmt.Sprintf("[KO] Unable to parse the base configuration: %s", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	changes, err := openapi.Merge(cfg, endpoints, openapi.Strategy(openAPIStrategy))
+	if err != nil {
+		ccmd.Println(fmt.Sprintf("[KO] %s", err.Error()))
+		os.Exit(1)
+	}
+
+	if openAPIDryRun {
+		ccmd.Print(openapi.Diff(changes))
+		return
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(cfg); err != nil {
+		ccmd.Println(fmt.Sprintf("[KO] Unable to encode the configuration: %s", err.Error()))
+		os.Exit(1)
+	}
+	output := openAPIOutput
+	if openAPIInPlace {
+		output = openAPIBase
+	}
+	if output == "" {
+		ccmd.Print(buf.String())
+		return
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0o644); err != nil {
+		ccmd.Println(fmt.Sprintf("[KO] Unable to write the configuration: %s", err.Error()))
+		os.Exit(1)
+	}
+	ccmd.Println(fmt.Sprintf("[OK] %d endpoints written to %s", len(changes), output))
+}
+
+// NewExportOpenAPICmd returns the command generating the OpenAPI document of the configuration
+// parsed with the given parser
+func NewExportOpenAPICmd(parser config.Parser) cmd.Command {
+	exportOpenAPICmd.Run = func(ccmd *cobra.Command, args []string) {
+		exportOpenAPIFunc(ccmd, args, parser)
+	}
+	return cmd.NewCommand(exportOpenAPICmd, exportOpenAPIOutputFlag, exportOpenAPITitleFlag, exportOpenAPIVersionFlag)
+}
+
+func exportOpenAPIFunc(ccmd *cobra.Command, args []string, parser config.Parser) {
+	if len(args) != 1 {
+		ccmd.Println("The path to the configuration file is required.")
+		os.Exit(1)
+	}
+
+	cfg, err := parser.Parse(args[0])
+	if err != nil {
+		ccmd.Println(fmt.Sprintf("[KO] Unable to parse the configuration: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	eCfg, err := openapi.ParseExportConfig(cfg.ExtraConfig)
+	if err != nil && err != openapi.ErrNoConfig {
+		ccmd.Println(fmt.Sprintf("[KO] Unable to parse the %s config: %s", openapi.ExportNamespace, err.Error()))
+		os.Exit(1)
+	}
+	if exportOpenAPITitle != "" {
+		eCfg.Title = exportOpenAPITitle
+	}
+	if exportOpenAPIVersion != "" {
+		eCfg.Version = exportOpenAPIVersion
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(openapi.Export(cfg, eCfg)); err != nil {
+		ccmd.Println(fmt.Sprintf("[KO] Unable to encode the document: %s", err.Error()))
+		os.Exit(1)
+	}
+	if exportOpenAPIOutput == "" {
+		ccmd.Print(buf.String())
+		return
+	}
+	if err := os.WriteFile(exportOpenAPIOutput, buf.Bytes(), 0o644); err != nil {
+		ccmd.Println(fmt.Sprintf("[KO] Unable to write the document: %s", err.Error()))
+		os.Exit(1)
+	}
+	ccmd.Println(fmt.Sprintf("[OK] %d endpoints written to %s", len(cfg.Endpoints), exportOpenAPIOutput))
+}
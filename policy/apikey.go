@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultAPIKeyHeader = "X-Api-Key"
+
+// APIKeys identifies the clients by the key they send, exposing the metadata of the key to the
+// rules as api_key. The metadata of an unknown key is empty.
+type APIKeys struct {
+	// Header sending the key. Default: X-Api-Key. A bearer token is accepted at the Authorization header.
+	Header string   `json:"header"`
+	Keys   []APIKey `json:"keys"`
+}
+
+// APIKey is a known key, declared in plain text or as its hex encoded sha256 hash
+type APIKey struct {
+	ID       string                 `json:"id"`
+	Key      string                 `json:"key"`
+	SHA256   string                 `json:"sha256"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// KeyStore resolves the metadata of the API keys
+type KeyStore struct {
+	header string
+	// keys are the metadata of the keys, indexed by their sha256 hash
+	keys map[string]map[string]interface{}
+}
+
+// NewKeyStore indexes the declared keys
+func NewKeyStore(cfg APIKeys) (*KeyStore, error) {
+	s := &KeyStore{header: cfg.Header, keys: make(map[string]map[string]interface{}, len(cfg.Keys))}
+	if s.header == "" {
+		s.header = defaultAPIKeyHeader
+	}
+	for i, k := range cfg.Keys {
+		hash := strings.ToLower(k.SHA256)
+		if k.Key != "" {
+			hash = hashKey(k.Key)
+		}
+		if hash == "" {
+			return nil, fmt.Errorf("the api key #%d has no key nor sha256", i)
+		}
+		if _, ok := s.keys[hash]; ok {
+			return nil, fmt.Errorf("the api key #%d is declared twice", i)
+		}
+		metadata := make(map[string]interface{}, len(k.Metadata)+1)
+		for name, v := range k.Metadata {
+			metadata[name] = v
+		}
+		metadata["id"] = k.ID
+		s.keys[hash] = metadata
+	}
+	return s, nil
+}
+
+// Lookup returns the metadata of the key sent in the headers, or nil when the key is unknown
+func (s *KeyStore) Lookup(h http.Header) map[string]interface{} {
+	key := h.Get(s.header)
+	if len(key) > 7 && strings.EqualFold(key[:7], "bearer ") {
+		key = key[7:]
+	}
+	if key == "" {
+		return nil
+	}
+	return s.keys[hashKey(key)]
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,94 @@
+// Package policy authorizes the requests of an endpoint by evaluating a set of CEL rules over the
+// request data, the claims of the JWT, the identity of the client certificate and the metadata of
+// the API key.
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+
+	"github.com/krakend/krakend-ce/v2/clientip"
+)
+
+// Namespace is the key used to store the policies config at the endpoint ExtraConfig struct
+const Namespace = "auth/policies"
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// Config defines the rules to evaluate and the response to return when any of them denies the request
+type Config struct {
+	// Rules are CEL expressions that must evaluate to true for the request to be accepted
+	Rules []Rule `json:"rules"`
+	// RuleFiles are files containing a single CEL expression each. The name of the rule is the file name.
+	RuleFiles []string `json:"rule_files"`
+	// ErrorStatus is the status code returned when the request is denied. Default: 403
+	ErrorStatus int `json:"error_status"`
+	// ErrorBody is the json body returned when the request is denied. Default: no body
+	ErrorBody interface{} `json:"error_body"`
+	// APIKeys are the keys the clients are identified with
+	APIKeys *APIKeys `json:"api_keys"`
+	// Debug logs the name of the rule denying each request
+	Debug bool `json:"debug"`
+	// Config defines the trusted proxies and the header declaring the client IP of the ip variable
+	clientip.Config
+}
+
+// Rule is a named CEL expression
+type Rule struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if res.ErrorStatus == 0 {
+		res.ErrorStatus = http.StatusForbidden
+	}
+	return res, nil
+}
+
+// AllRules returns the inline rules followed by the ones loaded from the rule files
+func (c Config) AllRules() ([]Rule, error) {
+	rules := append([]Rule{}, c.Rules...)
+	fromFiles, err := LoadRules(c.RuleFiles...)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, fromFiles...), nil
+}
+
+// LoadRules reads the rules from the received files
+func LoadRules(files ...string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(files))
+	for _, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		base := filepath.Base(path)
+		rules = append(rules, Rule{
+			Name:       strings.TrimSuffix(base, filepath.Ext(base)),
+			Expression: string(b),
+		})
+	}
+	return rules, nil
+}
@@ -0,0 +1,173 @@
+package policy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	router "github.com/luraproject/lura/v2/router/gin"
+
+	"github.com/krakend/krakend-ce/v2/clientip"
+	"github.com/krakend/krakend-ce/v2/mtls"
+)
+
+// ClaimsFactory returns the function extracting the claims of the JWT sent to the endpoint. The
+// token is not validated again, so the returned function must only be used behind the JWT validator
+// and the factory must return nil for the endpoints without it.
+type ClaimsFactory func(*config.EndpointConfig) func(*http.Request) map[string]interface{}
+
+// HandlerFactory checks the configuration and, if required, wraps the handler factory with a
+// middleware evaluating the endpoint policies. It must be placed after the JWT validator and the
+// mTLS middleware, so their claims are available to the rules. The endpoints with rules using the
+// JWT variable must declare the JWT validator, or they reject all the requests.
+func HandlerFactory(hf router.HandlerFactory, l logging.Logger, claimsF ClaimsFactory) router.HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		next := hf(cfg, p)
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][Policies]"
+
+		pCfg, err := ParseConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			return next
+		}
+		if err != nil {
+			l.Warning(logPrefix, err.Error())
+			return next
+		}
+
+		rules, err := pCfg.AllRules()
+		if err != nil {
+			l.Error(logPrefix, "Unable to load the rules:", err.Error())
+			return deniedHandler(http.StatusInternalServerError, nil)
+		}
+
+		e, err := New(rules)
+		if err != nil {
+			l.Error(logPrefix, "Unable to compile the rules:", err.Error())
+			return deniedHandler(http.StatusInternalServerError, nil)
+		}
+
+		resolver, err := clientip.New(pCfg.Config)
+		if err != nil {
+			l.Error(logPrefix, err.Error())
+			return deniedHandler(http.StatusInternalServerError, nil)
+		}
+
+		var claims func(*http.Request) map[string]interface{}
+		if claimsF != nil {
+			claims = claimsF(cfg)
+		}
+		if claims == nil {
+			if e.Uses("JWT") {
+				l.Error(logPrefix, "The rules use the JWT claims but the endpoint has no JWT validator (auth/validator)")
+				return deniedHandler(http.StatusInternalServerError, nil)
+			}
+			claims = func(*http.Request) map[string]interface{} { return nil }
+		}
+
+		apiKey := func(*http.Request) map[string]interface{} { return nil }
+		if pCfg.APIKeys != nil {
+			keys, err := NewKeyStore(*pCfg.APIKeys)
+			if err != nil {
+				l.Error(logPrefix, "Unable to load the API keys:", err.Error())
+				return deniedHandler(http.StatusInternalServerError, nil)
+			}
+			apiKey = func(r *http.Request) map[string]interface{} { return keys.Lookup(r.Header) }
+		}
+		deny := deniedHandler(pCfg.ErrorStatus, pCfg.ErrorBody)
+
+		l.Debug(logPrefix, len(rules), "rule(s) loaded")
+
+		return func(c *gin.Context) {
+			rule, err := e.Eval(NewInput(c, resolver, claims(c.Request), apiKey(c.Request)))
+			if err != nil {
+				l.Error(logPrefix, "Unable to evaluate the rule", rule+":", err.Error())
+				deny(c)
+				return
+			}
+			if rule != "" {
+				if pCfg.Debug {
+					l.Debug(logPrefix, "Request denied by the rule", rule)
+				}
+				deny(c)
+				return
+			}
+			next(c)
+		}
+	}
+}
+
+// NewInput extracts the data available to the rules from the gin context. The IP of the client is
+// the one returned by the resolver, so the headers declaring it are only considered when sent by a
+// trusted proxy. It is empty when the resolver can not tell it.
+func NewInput(c *gin.Context, resolver *clientip.Resolver, jwtClaims, apiKey map[string]interface{}) Input {
+	params := make(map[string]string, len(c.Params))
+	for _, p := range c.Params {
+		params[p.Key] = p.Value
+	}
+	var ip string
+	if addr := resolver.ClientIP(c.Request); addr.IsValid() {
+		ip = addr.String()
+	}
+	return Input{
+		Method:      c.Request.Method,
+		Path:        c.Request.URL.Path,
+		Params:      params,
+		Headers:     c.Request.Header,
+		QueryString: c.Request.URL.Query(),
+		IP:          ip,
+		JWT:         jwtClaims,
+		MTLS:        mtls.Claims(c),
+		APIKey:      apiKey,
+	}
+}
+
+// JWTClaims returns a function decoding the claims of the token sent in the received header
+// (Authorization by default) or cookie, without validating it.
+func JWTClaims(header, cookie string) func(*http.Request) map[string]interface{} {
+	if header == "" {
+		header = "Authorization"
+	}
+	if cookie == "" {
+		cookie = "access_token"
+	}
+	return func(r *http.Request) map[string]interface{} {
+		token := r.Header.Get(header)
+		if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+			token = token[7:]
+		}
+		if token == "" {
+			if c, err := r.Cookie(cookie); err == nil {
+				token = c.Value
+			}
+		}
+		parts := strings.Split(token, ".")
+		if len(parts) != 3 {
+			return nil
+		}
+		b, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil
+		}
+		claims := map[string]interface{}{}
+		if err := json.Unmarshal(b, &claims); err != nil {
+			return nil
+		}
+		return claims
+	}
+}
+
+func deniedHandler(status int, body interface{}) gin.HandlerFunc {
+	if body == nil {
+		return func(c *gin.Context) {
+			c.AbortWithStatus(status)
+		}
+	}
+	return func(c *gin.Context) {
+		c.AbortWithStatusJSON(status, body)
+	}
+}
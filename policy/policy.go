@@ -0,0 +1,157 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+)
+
+// Input is the data available to the rules. Every field is exposed to the CEL expressions
+// with the following names:
+//
+//	req_method      string
+//	req_path        string
+//	req_params      map(string, string)
+//	req_headers     map(string, list(string))
+//	req_querystring map(string, list(string))
+//	req_ip          string
+//	JWT             map(string, dyn)
+//	mtls            map(string, dyn)
+//	api_key         map(string, dyn)
+type Input struct {
+	Method      string                 `json:"method"`
+	Path        string                 `json:"path"`
+	Params      map[string]string      `json:"params"`
+	Headers     map[string][]string    `json:"headers"`
+	QueryString map[string][]string    `json:"querystring"`
+	IP          string                 `json:"ip"`
+	JWT         map[string]interface{} `json:"jwt"`
+	MTLS        map[string]interface{} `json:"mtls"`
+	APIKey      map[string]interface{} `json:"api_key"`
+}
+
+func (in Input) activation() map[string]interface{} {
+	return map[string]interface{}{
+		"req_method":      in.Method,
+		"req_path":        in.Path,
+		"req_params":      nonNilStrings(in.Params),
+		"req_headers":     nonNilLists(in.Headers),
+		"req_querystring": nonNilLists(in.QueryString),
+		"req_ip":          in.IP,
+		"JWT":             nonNilMap(in.JWT),
+		"mtls":            nonNilMap(in.MTLS),
+		"api_key":         nonNilMap(in.APIKey),
+	}
+}
+
+// Evaluator checks a request against a set of compiled rules
+type Evaluator struct {
+	rules []compiledRule
+	// uses are the variables referenced by the rules
+	uses map[string]bool
+}
+
+type compiledRule struct {
+	name string
+	prg  cel.Program
+}
+
+// New compiles the received rules
+func New(rules []Rule) (*Evaluator, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("req_method", cel.StringType),
+		cel.Variable("req_path", cel.StringType),
+		cel.Variable("req_params", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("req_headers", cel.MapType(cel.StringType, cel.ListType(cel.StringType))),
+		cel.Variable("req_querystring", cel.MapType(cel.StringType, cel.ListType(cel.StringType))),
+		cel.Variable("req_ip", cel.StringType),
+		cel.Variable("JWT", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("mtls", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("api_key", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Evaluator{rules: make([]compiledRule, 0, len(rules)), uses: map[string]bool{}}
+	for i, r := range rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i)
+		}
+		ast, iss := env.Compile(r.Expression)
+		if iss != nil && iss.Err() != nil {
+			return nil, fmt.Errorf("compiling the rule %s: %w", name, iss.Err())
+		}
+		if !ast.OutputType().IsAssignableType(cel.BoolType) {
+			return nil, fmt.Errorf("the rule %s does not return a bool but %s", name, ast.OutputType())
+		}
+		for _, ref := range ast.NativeRep().ReferenceMap() {
+			e.uses[ref.Name] = true
+		}
+		prg, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("building the rule %s: %w", name, err)
+		}
+		e.rules = append(e.rules, compiledRule{name: name, prg: prg})
+	}
+	return e, nil
+}
+
+// Uses reports if any rule references the variable
+func (e *Evaluator) Uses(variable string) bool {
+	return e.uses[variable]
+}
+
+// Eval returns the name of the first rule denying the request, or an empty string when all the
+// rules accept it. Errors are returned with the name of the failing rule and must be considered
+// as a denial.
+func (e *Evaluator) Eval(in Input) (string, error) {
+	activation := in.activation()
+	for _, r := range e.rules {
+		out, _, err := r.prg.Eval(activation)
+		if err != nil {
+			return r.name, err
+		}
+		if ok, isBool := out.Value().(bool); !isBool || !ok {
+			return r.name, nil
+		}
+	}
+	return "", nil
+}
+
+// Results evaluates every rule and returns the result of each one, keyed by the rule name
+func (e *Evaluator) Results(in Input) map[string]interface{} {
+	activation := in.activation()
+	res := make(map[string]interface{}, len(e.rules))
+	for _, r := range e.rules {
+		out, _, err := r.prg.Eval(activation)
+		if err != nil {
+			res[r.name] = err
+			continue
+		}
+		res[r.name] = out.Value()
+	}
+	return res
+}
+
+func nonNilStrings(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
+
+func nonNilLists(m map[string][]string) map[string][]string {
+	if m == nil {
+		return map[string][]string{}
+	}
+	return m
+}
+
+func nonNilMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	return m
+}
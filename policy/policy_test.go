@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+func TestEvaluator(t *testing.T) {
+	e, err := New([]Rule{
+		{Name: "method", Expression: `req_method in ["GET", "HEAD"]`},
+		{Name: "owner", Expression: `has(JWT.sub) && JWT.sub == req_params["User"]`},
+		{Name: "tenant", Expression: `"X-Tenant" in req_headers && req_headers["X-Tenant"][0] == "acme"`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := Input{
+		Method:  "GET",
+		Params:  map[string]string{"User": "alice"},
+		Headers: map[string][]string{"X-Tenant": {"acme"}},
+		JWT:     map[string]interface{}{"sub": "alice"},
+	}
+
+	if rule, err := e.Eval(in); err != nil || rule != "" {
+		t.Errorf("unexpected result: %s, %v", rule, err)
+	}
+
+	in.Method = "POST"
+	if rule, err := e.Eval(in); err != nil || rule != "method" {
+		t.Errorf("unexpected result: %s, %v", rule, err)
+	}
+
+	in.Method = "GET"
+	in.JWT = nil
+	if rule, err := e.Eval(in); err != nil || rule != "owner" {
+		t.Errorf("unexpected result: %s, %v", rule, err)
+	}
+
+	if _, err := New([]Rule{{Name: "no-bool", Expression: `req_method`}}); err == nil {
+		t.Error("expecting an error for a non boolean rule")
+	}
+}
+
+func TestEvaluator_apiKey(t *testing.T) {
+	keys, err := NewKeyStore(APIKeys{Keys: []APIKey{
+		{ID: "ci", Key: "s3cr3t", Metadata: map[string]interface{}{"scopes": []interface{}{"deploy"}}},
+		{ID: "ops", SHA256: "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := New([]Rule{{Name: "scope", Expression: `has(api_key.scopes) && "deploy" in api_key.scopes`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Uses("JWT") {
+		t.Error("the rules do not use the JWT")
+	}
+
+	h := http.Header{}
+	h.Set("X-Api-Key", "s3cr3t")
+	if rule, err := e.Eval(Input{APIKey: keys.Lookup(h)}); err != nil || rule != "" {
+		t.Errorf("unexpected result: %s, %v", rule, err)
+	}
+
+	h.Set("X-Api-Key", "test")
+	md := keys.Lookup(h)
+	if md["id"] != "ops" {
+		t.Errorf("unexpected metadata: %v", md)
+	}
+	if rule, err := e.Eval(Input{APIKey: md}); err != nil || rule != "scope" {
+		t.Errorf("unexpected result: %s, %v", rule, err)
+	}
+
+	h.Set("X-Api-Key", "unknown")
+	if md := keys.Lookup(h); md != nil {
+		t.Errorf("unexpected metadata: %v", md)
+	}
+
+	if _, err := NewKeyStore(APIKeys{Keys: []APIKey{{ID: "a", Key: "x"}, {ID: "b", Key: "x"}}}); err == nil {
+		t.Error("expecting an error for a duplicated key")
+	}
+}
+
+func TestHandlerFactory_jwtWithoutValidator(t *testing.T) {
+	cfg := &config.EndpointConfig{
+		Endpoint: "/users/:id",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+			"rules": []interface{}{map[string]interface{}{"name": "owner", "expression": `JWT.sub == req_params["Id"]`}},
+		}},
+	}
+	hf := HandlerFactory(func(*config.EndpointConfig, proxy.Proxy) gin.HandlerFunc {
+		return func(c *gin.Context) { c.Status(http.StatusOK) }
+	}, logging.NoOp, func(*config.EndpointConfig) func(*http.Request) map[string]interface{} { return nil })
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET(cfg.Endpoint, hf(cfg, nil))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest("GET", "/users/alice", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+}
+
+func TestHandlerFactory_clientIP(t *testing.T) {
+	rules := []interface{}{map[string]interface{}{"name": "internal", "expression": `req_ip == "10.0.0.1"`}}
+	for name, tc := range map[string]struct {
+		extra      map[string]interface{}
+		remoteAddr string
+		expected   int
+	}{
+		"spoofed header": {
+			extra:      map[string]interface{}{"rules": rules},
+			remoteAddr: "192.0.2.1:1234",
+			expected:   http.StatusForbidden,
+		},
+		"untrusted proxy": {
+			extra:      map[string]interface{}{"rules": rules, "trusted_proxies": []string{"192.0.2.2"}, "client_ip_header": "X-Forwarded-For"},
+			remoteAddr: "192.0.2.1:1234",
+			expected:   http.StatusForbidden,
+		},
+		"trusted proxy": {
+			extra:      map[string]interface{}{"rules": rules, "trusted_proxies": []string{"192.0.2.1"}, "client_ip_header": "X-Forwarded-For"},
+			remoteAddr: "192.0.2.1:1234",
+			expected:   http.StatusOK,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.EndpointConfig{Endpoint: "/internal", ExtraConfig: config.ExtraConfig{Namespace: tc.extra}}
+			hf := HandlerFactory(func(*config.EndpointConfig, proxy.Proxy) gin.HandlerFunc {
+				return func(c *gin.Context) { c.Status(http.StatusOK) }
+			}, logging.NoOp, nil)
+
+			gin.SetMode(gin.TestMode)
+			engine := gin.New()
+			engine.GET(cfg.Endpoint, hf(cfg, nil))
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/internal", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("X-Forwarded-For", "10.0.0.1")
+			engine.ServeHTTP(w, req)
+			if w.Code != tc.expected {
+				t.Errorf("unexpected status code: %d", w.Code)
+			}
+		})
+	}
+}
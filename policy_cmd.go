@@ -0,0 +1,175 @@
+package krakend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	cmd "github.com/krakend/krakend-cobra/v2"
+	jose "github.com/krakend/krakend-jose/v2"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/spf13/cobra"
+
+	"github.com/krakend/krakend-ce/v2/policy"
+)
+
+var (
+	policyRequestPath string
+	policyEndpoint    string
+
+	testPolicyCmd = &cobra.Command{
+		Use:   "test-policy [flags] [rule files | config file]",
+		Short: "Evaluates one or more policy rules against a sample request.",
+		Long: `Evaluates the rules of the given files or, with the --endpoint flag, the rules declared at an
+endpoint of the given configuration file against a sample request.`,
+		Example: `krakend test-policy -r ./request.json ./policies/owner.cel ./policies/tenant.cel
+krakend test-policy -r ./request.json -e "GET /users/{id}" ./krakend.json`,
+	}
+
+	policyRequestFlag  cmd.FlagBuilder
+	policyEndpointFlag cmd.FlagBuilder
+)
+
+func init() {
+	policyRequestFlag = cmd.StringFlagBuilder(&policyRequestPath, "request", "r", "", "Path to the json file with the sample request (method, path, params, headers, querystring, ip, jwt, mtls and api_key).")
+	policyEndpointFlag = cmd.StringFlagBuilder(&policyEndpoint, "endpoint", "e", "", "Endpoint of the configuration file to take the rules from, as \"METHOD /path\". The method defaults to GET.")
+}
+
+// NewTestPolicyCmd returns the command evaluating the policy rules offline. The configuration
+// files are parsed with the given parser.
+func NewTestPolicyCmd(parser config.Parser) cmd.Command {
+	testPolicyCmd.Run = func(ccmd *cobra.Command, args []string) {
+		testPolicyFunc(ccmd, args, parser)
+	}
+	return cmd.NewCommand(testPolicyCmd, policyRequestFlag, policyEndpointFlag)
+}
+
+func testPolicyFunc(ccmd *cobra.Command, args []string, parser config.Parser) {
+	if policyEndpoint != "" && len(args) != 1 {
+		ccmd.Println("The path to the configuration file is required.")
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		ccmd.Println("At least one rule file is required.")
+		os.Exit(1)
+	}
+
+	var in policy.Input
+	if policyRequestPath != "" {
+		b, err := os.ReadFile(policyRequestPath)
+		if err != nil {
+			ccmd.Println(fmt.Sprintf("[KO] Unable to read the sample request: %s", err.Error()))
+			os.Exit(1)
+		}
+		if err := json.Unmarshal(b, &in); err != nil {
+			ccmd.Println(fmt.Sprintf("[KO] Unable to parse the sample request: %s", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	var (
+		rules     []policy.Rule
+		validator = true
+		err       error
+	)
+	if policyEndpoint == "" {
+		rules, err = policy.LoadRules(args...)
+	} else {
+		rules, validator, err = endpointRules(args[0], policyEndpoint, parser, &in)
+	}
+	if err != nil {
+		ccmd.Println(fmt.Sprintf("[KO] Unable to load the rules: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	e, err := policy.New(rules)
+	if err != nil {
+		ccmd.Println(fmt.Sprintf("[KO] %s", err.Error()))
+		os.Exit(1)
+	}
+	if !validator && e.Uses("JWT") {
+		ccmd.Println("[KO] The rules use the JWT claims but the endpoint has no JWT validator (auth/validator).")
+		os.Exit(1)
+	}
+
+	results := e.Results(in)
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	allowed := true
+	for _, name := range names {
+		if v, ok := results[name].(bool); ok && v {
+			ccmd.Println(fmt.Sprintf("[OK] %s", name))
+			continue
+		}
+		allowed = false
+		ccmd.Println(fmt.Sprintf("[KO] %s: %v", name, results[name]))
+	}
+
+	if !allowed {
+		ccmd.Println("[KO] The request is denied.")
+		os.Exit(1)
+	}
+	ccmd.Println("[OK] The request is allowed.")
+}
+
+// endpointRules returns the rules declared at the selected endpoint of the configuration file and
+// if the endpoint validates the JWT, resolving the API key of the sample request with the keys of
+// the endpoint
+func endpointRules(path, selector string, parser config.Parser, in *policy.Input) ([]policy.Rule, bool, error) {
+	cfg, err := parser.Parse(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	method, endpoint := http.MethodGet, selector
+	if parts := strings.Fields(selector); len(parts) == 2 {
+		method, endpoint = strings.ToUpper(parts[0]), parts[1]
+	}
+	endpoint = normalizeEndpoint(endpoint)
+
+	for _, e := range cfg.Endpoints {
+		if !strings.EqualFold(e.Method, method) || normalizeEndpoint(e.Endpoint) != endpoint {
+			continue
+		}
+		pCfg, err := policy.ParseConfig(e.ExtraConfig)
+		if err != nil {
+			return nil, false, fmt.Errorf("the endpoint %s %s: %w", e.Method, e.Endpoint, err)
+		}
+		rules, err := pCfg.AllRules()
+		if err != nil {
+			return nil, false, err
+		}
+		if pCfg.APIKeys != nil && in.APIKey == nil {
+			keys, err := policy.NewKeyStore(*pCfg.APIKeys)
+			if err != nil {
+				return nil, false, err
+			}
+			h := http.Header{}
+			for k, vs := range in.Headers {
+				h[http.CanonicalHeaderKey(k)] = vs
+			}
+			in.APIKey = keys.Lookup(h)
+		}
+		_, err = jose.GetSignatureConfig(e)
+		return rules, err == nil, nil
+	}
+	return nil, false, fmt.Errorf("unknown endpoint %s %s", method, endpoint)
+}
+
+// normalizeEndpoint replaces the {param} segments with the :param ones
+func normalizeEndpoint(endpoint string) string {
+	parts := strings.Split(endpoint, "/")
+	for i, part := range parts {
+		if len(part) > 2 && part[0] == '{' && part[len(part)-1] == '}' {
+			parts[i] = ":" + part[1:len(part)-1]
+		}
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,35 @@
+// Package problem builds RFC 7807 problem details (application/problem+json) documents for the
+// errors produced by the gateway.
+package problem
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContentType is the media type of the problem details documents
+const ContentType = "application/problem+json"
+
+// Document returns the problem details for the status and the request. The members of the
+// template override the default ones (type, title, detail and instance) and any other member is
+// added as an extension.
+func Document(r *http.Request, status int, detail string, tmpl map[string]interface{}) map[string]interface{} {
+	doc := map[string]interface{}{
+		"type":     "about:blank",
+		"title":    http.StatusText(status),
+		"status":   status,
+		"instance": r.URL.Path,
+	}
+	if detail != "" {
+		doc["detail"] = detail
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+		doc["trace_id"] = sc.TraceID().String()
+	}
+	for k, v := range tmpl {
+		doc[k] = v
+	}
+	doc["status"] = status
+	return doc
+}
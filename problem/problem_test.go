@@ -0,0 +1,42 @@
+package problem
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDocument(t *testing.T) {
+	r := httptest.NewRequest("GET", "/foo?bar=1", nil)
+	traceID := trace.TraceID{1, 2, 3}
+	r = r.WithContext(trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  trace.SpanID{1},
+	})))
+
+	doc := Document(r, 401, "invalid token", map[string]interface{}{
+		"type":   "https://example.com/probs/unauthorized",
+		"status": 200,
+		"realm":  "api",
+	})
+
+	expected := map[string]interface{}{
+		"type":     "https://example.com/probs/unauthorized",
+		"title":    "Unauthorized",
+		"status":   401,
+		"detail":   "invalid token",
+		"instance": "/foo",
+		"trace_id": traceID.String(),
+		"realm":    "api",
+	}
+	if len(doc) != len(expected) {
+		t.Errorf("unexpected document: %v", doc)
+	}
+	for k, v := range expected {
+		if doc[k] != v {
+			t.Errorf("unexpected %s: %v", k, doc[k])
+		}
+	}
+}
@@ -11,6 +11,9 @@ import (
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/logging"
 	"github.com/luraproject/lura/v2/proxy"
+
+	"github.com/krakend/krakend-ce/v2/graphql"
+	"github.com/krakend/krakend-ce/v2/openapi"
 )
 
 func internalNewProxyFactory(logger logging.Logger, backendFactory proxy.BackendFactory,
@@ -19,6 +22,8 @@ func internalNewProxyFactory(logger logging.Logger, backendFactory proxy.Backend
 	proxyFactory := proxy.NewDefaultFactory(backendFactory, logger)
 	proxyFactory = proxy.NewShadowFactory(proxyFactory)
 	proxyFactory = jsonschema.ProxyFactory(logger, proxyFactory)
+	proxyFactory = openapi.ProxyFactory(logger, *metricCollector.Registry, proxyFactory)
+	proxyFactory = graphql.ProxyFactory(logger, *metricCollector.Registry, proxyFactory)
 	proxyFactory = cel.ProxyFactory(logger, proxyFactory)
 	proxyFactory = lua.ProxyFactory(logger, proxyFactory)
 	proxyFactory = metricCollector.ProxyFactory("pipe", proxyFactory)
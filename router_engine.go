@@ -1,63 +1,86 @@
 package krakend
 
 import (
-	"encoding/json"
+	"context"
 
 	"github.com/gin-gonic/gin"
 
 	botdetector "github.com/krakend/krakend-botdetector/v2/gin"
 	httpsecure "github.com/krakend/krakend-httpsecure/v2/gin"
 	lua "github.com/krakend/krakend-lua/v2/router/gin"
+	metrics "github.com/krakend/krakend-metrics/v2/gin"
 	opencensus "github.com/krakend/krakend-opencensus/v2/router/gin"
 	"github.com/luraproject/lura/v2/config"
-	"github.com/luraproject/lura/v2/core"
 	luragin "github.com/luraproject/lura/v2/router/gin"
-	"github.com/luraproject/lura/v2/transport/http/server"
+	gometrics "github.com/rcrowley/go-metrics"
+
+	"github.com/krakend/krakend-ce/v2/errorbody"
+	"github.com/krakend/krakend-ce/v2/eventstream"
+	"github.com/krakend/krakend-ce/v2/ipfilter"
+	"github.com/krakend/krakend-ce/v2/limits"
+	"github.com/krakend/krakend-ce/v2/maintenance"
+	"github.com/krakend/krakend-ce/v2/openapi"
+	"github.com/krakend/krakend-ce/v2/streaming"
+	"github.com/krakend/krakend-ce/v2/waf"
 )
 
 // NewEngine creates a new gin engine with some default values and a secure middleware
 func NewEngine(cfg config.ServiceConfig, opt luragin.EngineOptions) *gin.Engine {
+	return NewEngineWithMetrics(cfg, opt, nil)
+}
+
+// NewEngineWithMetrics creates a gin engine like NewEngine, registering the metrics of its
+// middlewares at the metrics collector, if any
+func NewEngineWithMetrics(cfg config.ServiceConfig, opt luragin.EngineOptions, metricCollector *metrics.Metrics) *gin.Engine {
+	return NewEngineWithContext(context.Background(), cfg, opt, metricCollector)
+}
+
+// NewEngineWithContext creates a gin engine like NewEngineWithMetrics, releasing the resources of
+// its middlewares (like the watchers of the IP lists) when the context is cancelled
+func NewEngineWithContext(ctx context.Context, cfg config.ServiceConfig, opt luragin.EngineOptions, metricCollector *metrics.Metrics) *gin.Engine {
 	engine := luragin.NewEngine(cfg, opt)
 
-	engine.NoRoute(opencensus.HandlerFunc(&config.EndpointConfig{Endpoint: "NoRoute"}, defaultHandler, nil))
-	engine.NoMethod(opencensus.HandlerFunc(&config.EndpointConfig{Endpoint: "NoMethod"}, defaultHandler, nil))
-	if v, ok := cfg.ExtraConfig[luragin.Namespace]; ok && v != nil {
-		var ginOpts ginOptions
-		if b, err := json.Marshal(v); err == nil {
-			json.Unmarshal(b, &ginOpts)
-		}
-		if ginOpts.ErrorBody.Err404 != nil {
-			engine.NoRoute(opencensus.HandlerFunc(&config.EndpointConfig{Endpoint: "NoRoute"}, jsonHandler(404, ginOpts.ErrorBody.Err404), nil))
-		}
-		if ginOpts.ErrorBody.Err405 != nil {
-			engine.NoMethod(opencensus.HandlerFunc(&config.EndpointConfig{Endpoint: "NoMethod"}, jsonHandler(405, ginOpts.ErrorBody.Err405), nil))
-		}
-	}
+	// the streams need the response writer of the server, before the handlers wrap it
+	streaming.Register(cfg, opt.Logger, engine)
+	eventstream.Register(cfg, opt.Logger, engine)
 
 	logPrefix := "[SERVICE: Gin]"
+	errorRenderer, err := errorbody.New(cfg)
+	if err != nil {
+		opt.Logger.Error(logPrefix+"[ErrorBody]", "Unable to load the error bodies, keeping the default ones:", err.Error())
+		errorRenderer = &errorbody.Renderer{}
+	}
+	if errorRenderer.Enabled() {
+		engine.Use(errorRenderer.HandlerFunc)
+	}
+	engine.NoRoute(opencensus.HandlerFunc(&config.EndpointConfig{Endpoint: "NoRoute"}, errorRenderer.Handler(404), nil))
+	engine.NoMethod(opencensus.HandlerFunc(&config.EndpointConfig{Endpoint: "NoMethod"}, errorRenderer.Handler(405), nil))
+
 	if err := httpsecure.Register(cfg.ExtraConfig, engine); err != nil && err != httpsecure.ErrNoConfig {
 		opt.Logger.Warning(logPrefix+"[HTTPsecure]", err)
 	} else if err == nil {
 		opt.Logger.Debug(logPrefix + "[HTTPsecure] Successfully loaded module")
 	}
 
+	ipfilter.Register(ctx, cfg, opt.Logger, engine)
+
+	limits.Register(cfg, opt.Logger, engine, errorRenderer.Abort)
+
+	var registry gometrics.Registry
+	if metricCollector != nil {
+		registry = *metricCollector.Registry
+	}
+	waf.Register(cfg, opt.Logger, engine, registry)
+
 	lua.Register(opt.Logger, cfg.ExtraConfig, engine)
 
 	botdetector.Register(cfg, opt.Logger, engine)
 
-	return engine
-}
+	maintenance.Register(cfg, opt.Logger, engine, errorRenderer.Abort)
 
-func defaultHandler(c *gin.Context) {
-	c.Header(core.KrakendHeaderName, core.KrakendHeaderValue)
-	c.Header(server.CompleteResponseHeaderName, server.HeaderIncompleteResponseValue)
-}
+	openapi.Register(cfg, opt.Logger, engine)
 
-func jsonHandler(status int, v interface{}) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		defaultHandler(c)
-		c.JSON(status, v)
-	}
+	return engine
 }
 
 type engineFactory struct{}
@@ -66,11 +89,10 @@ func (engineFactory) NewEngine(cfg config.ServiceConfig, opt luragin.EngineOptio
 	return NewEngine(cfg, opt)
 }
 
-type ginOptions struct {
-	// ErrorBody sets the json body to return to handlers like NoRoute (404) and NoMethod (405)
-	// Example: "404": { "error": "Not Found", "status": 404 }
-	ErrorBody struct {
-		Err404 interface{} `json:"404"`
-		Err405 interface{} `json:"405"`
-	} `json:"error_body"`
+func (engineFactory) NewEngineWithMetrics(cfg config.ServiceConfig, opt luragin.EngineOptions, m *metrics.Metrics) *gin.Engine {
+	return NewEngineWithMetrics(cfg, opt, m)
+}
+
+func (engineFactory) NewEngineWithContext(ctx context.Context, cfg config.ServiceConfig, opt luragin.EngineOptions, m *metrics.Metrics) *gin.Engine {
+	return NewEngineWithContext(ctx, cfg, opt, m)
 }
@@ -0,0 +1,58 @@
+// Package streaming forwards the response of the backend of an endpoint as it arrives, without
+// buffering it, so server-sent events (text/event-stream) and chunked responses reach the client
+// as soon as the backend flushes them. The rest of the handler chain (authentication, filters,
+// rate limits...) still applies before connecting to the backend.
+package streaming
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key used to store the streaming config at the endpoint ExtraConfig
+const Namespace = "streaming"
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// Config defines the streaming of an endpoint. It requires a single backend.
+type Config struct {
+	// IdleTimeout closes the stream when the backend does not send anything during this time.
+	// Default: no limit
+	IdleTimeout string `json:"idle_timeout"`
+	// MaxDuration closes the stream after this time. Default: no limit
+	MaxDuration string `json:"max_duration"`
+
+	idleTimeout time.Duration
+	maxDuration time.Duration
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if res.IdleTimeout != "" {
+		if res.idleTimeout, err = time.ParseDuration(res.IdleTimeout); err != nil {
+			return res, err
+		}
+	}
+	if res.MaxDuration != "" {
+		if res.maxDuration, err = time.ParseDuration(res.MaxDuration); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,74 @@
+package streaming
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	router "github.com/luraproject/lura/v2/router/gin"
+)
+
+// HandlerFactory checks the configuration and, if required, replaces the handler of the endpoint
+// with the streaming one. It should be the innermost layer of the handler factory, so the rest of
+// the middlewares apply before connecting to the backend. The client certificates of the backends
+// are reloaded until the context is cancelled.
+func HandlerFactory(ctx context.Context, hf router.HandlerFactory, l logging.Logger) router.HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][Streaming]"
+
+		sCfg, err := ParseConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			return hf(cfg, p)
+		}
+		if err != nil {
+			l.Error(logPrefix, err.Error())
+			return abort
+		}
+
+		st, err := New(ctx, cfg, sCfg, l)
+		if err != nil {
+			l.Error(logPrefix, "Unable to create the streaming handler:", err.Error())
+			return abort
+		}
+
+		l.Debug(logPrefix, "Streaming the responses of", cfg.Backend[0].URLPattern)
+		return st.HandlerFunc
+	}
+}
+
+func abort(c *gin.Context) {
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
+
+// Register removes the write deadline of the server for the requests to the streaming endpoints,
+// as the streams last longer than the write timeout of the service. It must be registered at the
+// engine, before the handler factories (like the metrics one) wrap the response writer.
+func Register(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine) {
+	paths := map[string]bool{}
+	for _, e := range cfg.Endpoints {
+		if _, ok := e.ExtraConfig[Namespace]; ok {
+			paths[e.Endpoint] = true
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	engine.Use(NoWriteDeadline(paths, l, "[SERVICE: Gin][Streaming]"))
+}
+
+// NoWriteDeadline returns a middleware removing the write deadline of the server for the requests
+// routed to the paths
+func NoWriteDeadline(paths map[string]bool, l logging.Logger, logPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !paths[c.FullPath()] {
+			return
+		}
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			l.Warning(logPrefix, "Unable to remove the write deadline of", c.FullPath(), err.Error())
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+
+	"github.com/krakend/krakend-ce/v2/httpclient"
+)
+
+const bufferSize = 32 * 1024
+
+var errBackends = errors.New("the streaming endpoint requires a single backend")
+
+// Streamer forwards the requests of an endpoint to its backend and copies the response as it
+// arrives
+type Streamer struct {
+	cfg         Config
+	hosts       []string
+	pattern     string
+	method      string
+	headers     []string
+	queryString []string
+	client      *http.Client
+	next        atomic.Uint64
+	l           logging.Logger
+	logPrefix   string
+}
+
+// New returns a Streamer for the endpoint. The backend is reached with the client declared at its
+// http client config, if any, reloading its client certificates until the context is cancelled.
+func New(ctx context.Context, cfg *config.EndpointConfig, sCfg Config, l logging.Logger) (*Streamer, error) {
+	if len(cfg.Backend) != 1 || len(cfg.Backend[0].Host) == 0 {
+		return nil, errBackends
+	}
+	b := cfg.Backend[0]
+
+	s := &Streamer{
+		cfg:         sCfg,
+		hosts:       b.Host,
+		pattern:     b.URLPattern,
+		method:      b.Method,
+		headers:     cfg.HeadersToPass,
+		queryString: cfg.QueryString,
+		// no timeout, as the streams can last for long
+		client:    &http.Client{},
+		l:         l,
+		logPrefix: "[ENDPOINT: " + cfg.Endpoint + "][Streaming]",
+	}
+	cf, err := httpclient.NewHTTPClientFactory(ctx, b, l)
+	if err == nil {
+		s.client = cf(ctx)
+	} else if err != httpclient.ErrNoConfig {
+		return nil, err
+	}
+	return s, nil
+}
+
+// HandlerFunc sends the request to the backend and flushes every chunk of the response as soon as
+// it is received. The backend request is cancelled when the client goes away.
+func (s *Streamer) HandlerFunc(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	if s.cfg.maxDuration > 0 {
+		var cancelMax context.CancelFunc
+		ctx, cancelMax = context.WithTimeout(ctx, s.cfg.maxDuration)
+		defer cancelMax()
+	}
+
+	req, err := s.newRequest(ctx, c)
+	if err != nil {
+		s.l.Error(s.logPrefix, "Unable to create the backend request:", err.Error())
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var idle *time.Timer
+	if s.cfg.idleTimeout > 0 {
+		idle = time.AfterFunc(s.cfg.idleTimeout, cancel)
+		defer idle.Stop()
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			// the client went away
+			c.Abort()
+			return
+		}
+		s.l.Warning(s.logPrefix, "Unable to reach the backend:", err.Error())
+		c.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	h := c.Writer.Header()
+	for k, vs := range resp.Header {
+		h[k] = vs
+	}
+	httpclient.RemoveHopHeaders(h)
+	if isEventStream(resp.Header.Get("Content-Type")) {
+		// ask the reverse proxies in front of the gateway not to buffer the events
+		h.Set("X-Accel-Buffering", "no")
+	}
+	c.Status(resp.StatusCode)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	buf := make([]byte, bufferSize)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if idle != nil {
+				idle.Reset(s.cfg.idleTimeout)
+			}
+			if _, werr := c.Writer.Write(buf[:n]); werr != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+		if err != nil {
+			if err != io.EOF && c.Request.Context().Err() == nil {
+				s.l.Debug(s.logPrefix, "Stream closed:", err.Error())
+			}
+			return
+		}
+	}
+}
+
+func (s *Streamer) newRequest(ctx context.Context, c *gin.Context) (*http.Request, error) {
+	method := s.method
+	if method == "" {
+		method = c.Request.Method
+	}
+	var body io.Reader
+	if c.Request.Body != nil && c.Request.ContentLength != 0 {
+		body = c.Request.Body
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.backendURL(c), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = c.Request.ContentLength
+
+	for _, k := range s.headers {
+		if k == "*" {
+			for name, vs := range c.Request.Header {
+				req.Header[name] = vs
+			}
+			break
+		}
+		if vs := c.Request.Header.Values(k); len(vs) > 0 {
+			req.Header[http.CanonicalHeaderKey(k)] = vs
+		}
+	}
+	httpclient.RemoveHopHeaders(req.Header)
+	return req, nil
+}
+
+func (s *Streamer) backendURL(c *gin.Context) string {
+	host := s.hosts[int(s.next.Add(1)-1)%len(s.hosts)]
+
+	path := s.pattern
+	for _, param := range c.Params {
+		// the params of the backend url patterns are title cased by the config parser
+		key := strings.ToUpper(param.Key[:1]) + param.Key[1:]
+		path = strings.ReplaceAll(path, "{{."+key+"}}", url.PathEscape(param.Value))
+	}
+
+	q := url.Values{}
+	for _, k := range s.queryString {
+		if k == "*" {
+			q = c.Request.URL.Query()
+			break
+		}
+		if vs, ok := c.Request.URL.Query()[k]; ok {
+			q[k] = vs
+		}
+	}
+	if len(q) == 0 {
+		return host + path
+	}
+	return host + path + "?" + q.Encode()
+}
+
+func isEventStream(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/event-stream"
+}
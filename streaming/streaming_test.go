@@ -0,0 +1,175 @@
+package streaming
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	metrics "github.com/krakend/krakend-metrics/v2"
+	metricsgin "github.com/krakend/krakend-metrics/v2/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+func TestHandlerFactory(t *testing.T) {
+	release := make(chan struct{})
+	closed := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(closed)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("X-Topic", r.URL.RequestURI()+" "+r.Header.Get("Last-Event-Id"))
+		fmt.Fprint(w, "id: 1\ndata: first\n\n")
+		w.(http.Flusher).Flush()
+		// the second event is sent once the client got the first one
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprint(w, "id: 2\ndata: second\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer backend.Close()
+
+	gw := gateway(t, map[string]interface{}{}, backend.URL)
+	defer gw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", gw.URL+"/events/news?since=1&x=2", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("X-Topic") != "/topics/news?since=1 0" || resp.Header.Get("X-Accel-Buffering") != "no" {
+		t.Errorf("unexpected headers: %v", resp.Header)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	for _, expected := range []string{"id: 1", "data: first", "", "id: 2", "data: second"} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSuffix(line, "\n") != expected {
+			t.Errorf("unexpected line: %q", line)
+		}
+		if expected == "" {
+			close(release)
+		}
+	}
+
+	cancel()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("the backend request was not cancelled")
+	}
+}
+
+func TestHandlerFactory_idleTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer backend.Close()
+
+	gw := gateway(t, map[string]interface{}{"idle_timeout": "50ms"}, backend.URL)
+	defer gw.Close()
+
+	c := &http.Client{Timeout: 5 * time.Second}
+	resp, err := c.Get(gw.URL + "/events/news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err == nil {
+		t.Error("expecting the end of the stream")
+	}
+}
+
+func TestHandlerFactory_badGateway(t *testing.T) {
+	gw := gateway(t, map[string]interface{}{}, "http://127.0.0.1:1")
+	defer gw.Close()
+
+	resp, err := http.Get(gw.URL + "/events/news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+}
+
+func gateway(t *testing.T, sCfg map[string]interface{}, host string) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.EndpointConfig{
+		Endpoint:      "/events/:topic",
+		Method:        "GET",
+		QueryString:   []string{"since"},
+		HeadersToPass: []string{"Last-Event-ID"},
+		ExtraConfig:   config.ExtraConfig{Namespace: sCfg},
+		Backend:       []*config.Backend{{Host: []string{host}, URLPattern: "/topics/{{.Topic}}", Method: "GET"}},
+	}
+	hf := HandlerFactory(context.Background(), func(*config.EndpointConfig, proxy.Proxy) gin.HandlerFunc {
+		t.Error("unexpected call to the next handler factory")
+		return nil
+	}, logging.NoOp)
+
+	engine := gin.New()
+	engine.GET(cfg.Endpoint, hf(cfg, nil))
+	return httptest.NewServer(engine)
+}
+
+func TestRegister_writeTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "first\n")
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		fmt.Fprint(w, "second\n")
+	}))
+	defer backend.Close()
+
+	gin.SetMode(gin.TestMode)
+	cfg := &config.EndpointConfig{
+		Endpoint:    "/stream",
+		Method:      "GET",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{}},
+		Backend:     []*config.Backend{{Host: []string{backend.URL}, URLPattern: "/"}},
+	}
+	engine := gin.New()
+	Register(config.ServiceConfig{Endpoints: []*config.EndpointConfig{cfg}}, logging.NoOp, engine)
+	// the metrics of the router wrap the response writer of every endpoint
+	registry := gometrics.NewRegistry()
+	hf := metricsgin.NewHTTPHandlerFactory(metrics.NewRouterMetrics(&registry), HandlerFactory(context.Background(), nil, logging.NoOp))
+	engine.GET(cfg.Endpoint, hf(cfg, nil))
+
+	gw := httptest.NewUnstartedServer(engine)
+	gw.Config.WriteTimeout = 100 * time.Millisecond
+	gw.Start()
+	defer gw.Close()
+
+	resp, err := http.Get(gw.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil || string(b) != "first\nsecond\n" {
+		t.Errorf("unexpected response %q: %v", string(b), err)
+	}
+}
@@ -0,0 +1,232 @@
+// Package unixsock serves the gateway on a Unix domain socket along with the TCP listener, so the
+// sidecars sharing the host can reach it without a TCP hop.
+package unixsock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+
+	"github.com/krakend/krakend-ce/v2/listener"
+)
+
+// Namespace is the key used to store the unix socket config at the service ExtraConfig
+const Namespace = "server/unix_socket"
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+var errNoPath = errors.New("the unix socket requires a path")
+
+// Config defines the unix socket listener. The connections are always served in cleartext.
+type Config struct {
+	// Path of the socket file. A stale socket at the same path is removed.
+	Path string `json:"path"`
+	// Mode is the octal file mode of the socket, like "0660". Default: the one set by the umask
+	Mode string `json:"mode"`
+	// Owner and Group are the names or the numeric ids of the owner of the socket. Default: the ones
+	// of the process
+	Owner string `json:"owner"`
+	Group string `json:"group"`
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if res.Path == "" {
+		return res, errNoPath
+	}
+	return res, nil
+}
+
+// Extension returns a listener.Extension serving the handler of the server at the unix socket when
+// it is configured
+func Extension(l logging.Logger) listener.Extension {
+	return func(_ context.Context, cfg config.ServiceConfig, s *http.Server) (func(context.Context) error, error) {
+		uCfg, err := ParseConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		us := &http.Server{
+			// the handler of the server can still be wrapped by the next extensions
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s.Handler.ServeHTTP(w, r)
+			}),
+			ReadTimeout:       s.ReadTimeout,
+			ReadHeaderTimeout: s.ReadHeaderTimeout,
+			WriteTimeout:      s.WriteTimeout,
+			IdleTimeout:       s.IdleTimeout,
+			MaxHeaderBytes:    s.MaxHeaderBytes,
+			ErrorLog:          s.ErrorLog,
+		}
+
+		return func(ctx context.Context) error {
+			ln, err := Listen(uCfg)
+			if err != nil {
+				return err
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				l.Info("[SERVICE: Unix socket] Listening on", uCfg.Path)
+				done <- us.Serve(ln)
+			}()
+
+			select {
+			case err := <-done:
+				return err
+			case <-ctx.Done():
+				return us.Shutdown(context.Background())
+			}
+		}, nil
+	}
+}
+
+// Listen creates the socket with the configured permissions. The socket is bound at a private
+// directory next to the path and moved to it once its permissions are set, so no one can connect
+// before. An existing socket is only replaced when no one is listening on it anymore. The socket
+// file is removed when the listener is closed.
+func Listen(cfg Config) (net.Listener, error) {
+	if fi, err := os.Lstat(cfg.Path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s already exists and it is not a socket", cfg.Path)
+		}
+		if err := removeStale(cfg.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(cfg.Path), ".sock")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := cfg
+	tmp.Path = filepath.Join(dir, "s")
+	ln, err := net.Listen("unix", tmp.Path)
+	if err != nil {
+		return nil, err
+	}
+	// the file is moved, so the listener can not remove it
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := setPermissions(tmp); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp.Path, cfg.Path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &unixListener{Listener: ln, path: cfg.Path}, nil
+}
+
+// removeStale removes the socket left by a process that is gone, failing when the socket is still
+// in use or its state can not be checked
+func removeStale(path string) error {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("checking the socket %s: %w", path, err)
+	}
+	return os.Remove(path)
+}
+
+// unixListener removes the socket file when closed
+type unixListener struct {
+	net.Listener
+	path string
+}
+
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	if rmErr := os.Remove(l.path); err == nil && !os.IsNotExist(rmErr) {
+		err = rmErr
+	}
+	return err
+}
+
+func setPermissions(cfg Config) error {
+	if cfg.Mode != "" {
+		mode, err := strconv.ParseUint(cfg.Mode, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid socket mode %s: %w", cfg.Mode, err)
+		}
+		if err := os.Chmod(cfg.Path, os.FileMode(mode)); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Owner == "" && cfg.Group == "" {
+		return nil
+	}
+	uid, gid := -1, -1
+	if cfg.Owner != "" {
+		id, err := lookupID(cfg.Owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return err
+		}
+		uid = id
+	}
+	if cfg.Group != "" {
+		id, err := lookupID(cfg.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return err
+		}
+		gid = id
+	}
+	return os.Chown(cfg.Path, uid, gid)
+}
+
+func lookupID(v string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(v); err == nil {
+		return id, nil
+	}
+	id, err := lookup(v)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(id)
+}
@@ -0,0 +1,131 @@
+package unixsock
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+)
+
+func TestExtension(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "krakend.sock")
+	// a stale socket left by a previous process
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("unix sockets are not available:", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s := &http.Server{Handler: http.NotFoundHandler()}
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{
+		"path": path,
+		"mode": "0600",
+	}}}
+	run, err := Extension(logging.NoOp)(context.Background(), cfg, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the handler is replaced after the extension is set up
+	s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run(ctx) }()
+
+	c := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	var body string
+	for i := 0; i < 20; i++ {
+		resp, err := c.Get("http://localhost/foo")
+		if err != nil {
+			time.Sleep(20 * time.Millisecond)
+			continue
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		body = string(b)
+		break
+	}
+	if body != "/foo" {
+		t.Errorf("unexpected response: %q", body)
+	}
+
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("unexpected socket file: %v %v", fi, err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the listener did not stop")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the socket file should be removed")
+	}
+}
+
+func TestListen_notASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "krakend.sock")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(Config{Path: path}); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestListen_inUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "krakend.sock")
+	live, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("unix sockets are not available:", err)
+	}
+	defer live.Close()
+
+	if _, err := Listen(Config{Path: path}); err == nil {
+		t.Error("expecting an error")
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("the live socket should be kept: %s", err.Error())
+	}
+	conn.Close()
+}
+
+func TestListen_privateDir(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "krakend.sock")
+	ln, err := Listen(Config{Path: path, Mode: "0600"})
+	if err != nil {
+		t.Skip("unix sockets are not available:", err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("unexpected socket file: %v %v", fi, err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("the private directory should be removed: %v", files)
+	}
+	if err := ln.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the socket file should be removed")
+	}
+}
@@ -0,0 +1,137 @@
+// Package vhost groups the endpoints by the Host header of the requests. Every group of hosts gets
+// its own gin engine, so the same path can be published on several domains, and its own engine
+// level config (error bodies, filters...) and server certificate.
+package vhost
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key used to store the virtual hosts config at the service ExtraConfig and the
+// name of the group of hosts at the endpoint ExtraConfig
+const Namespace = "router/vhosts"
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// Config defines the groups of hosts
+type Config struct {
+	Hosts []Host `json:"hosts"`
+}
+
+// Host is a group of hosts sharing the same endpoints
+type Host struct {
+	// Name identifies the group at the endpoint config: "router/vhosts": { "host": "<name>" }
+	Name string `json:"name"`
+	// Hosts is a list of exact host names or wildcards (*.example.com)
+	Hosts []string `json:"hosts"`
+	// TLS is the server certificate for the hosts, selected by SNI. It requires the service to be
+	// served over TLS.
+	TLS *KeyPair `json:"tls"`
+	// ExtraConfig replaces the service ExtraConfig entries with the same key when building the engine
+	// of the group. Example: the router namespace with custom NoRoute and NoMethod bodies.
+	ExtraConfig config.ExtraConfig `json:"extra_config"`
+}
+
+// KeyPair contains the paths of a certificate and its private key
+type KeyPair struct {
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+type endpointConfig struct {
+	Host string `json:"host"`
+}
+
+// ParseConfig extracts the module config from the service ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	if err := decode(cfg, &res); err != nil {
+		return res, err
+	}
+
+	names := map[string]struct{}{}
+	hosts := map[string]struct{}{}
+	for i, h := range res.Hosts {
+		if h.Name == "" || len(h.Hosts) == 0 {
+			return res, fmt.Errorf("the virtual host #%d requires a name and a list of hosts", i)
+		}
+		if _, ok := names[h.Name]; ok {
+			return res, fmt.Errorf("duplicated virtual host name: %s", h.Name)
+		}
+		names[h.Name] = struct{}{}
+		for j, host := range h.Hosts {
+			host = strings.ToLower(host)
+			if _, ok := hosts[host]; ok {
+				return res, fmt.Errorf("duplicated host: %s", host)
+			}
+			hosts[host] = struct{}{}
+			res.Hosts[i].Hosts[j] = host
+		}
+	}
+	return res, nil
+}
+
+// Split returns the service config with the endpoints not assigned to any group of hosts and the
+// service config of every group, indexed by its name
+func Split(cfg config.ServiceConfig, vCfg Config) (config.ServiceConfig, map[string]config.ServiceConfig, error) {
+	if cfg.TLS == nil || cfg.TLS.IsDisabled {
+		for _, h := range vCfg.Hosts {
+			if h.TLS != nil {
+				return cfg, nil, fmt.Errorf("virtual host %s: the certificate requires the tls config of the service", h.Name)
+			}
+		}
+	}
+
+	groups := make(map[string]config.ServiceConfig, len(vCfg.Hosts))
+	for _, h := range vCfg.Hosts {
+		hCfg := cfg
+		hCfg.Endpoints = nil
+		hCfg.ExtraConfig = make(config.ExtraConfig, len(cfg.ExtraConfig)+len(h.ExtraConfig))
+		for k, v := range cfg.ExtraConfig {
+			hCfg.ExtraConfig[k] = v
+		}
+		for k, v := range h.ExtraConfig {
+			hCfg.ExtraConfig[k] = v
+		}
+		groups[h.Name] = hCfg
+	}
+
+	defaultCfg := cfg
+	defaultCfg.Endpoints = nil
+	for _, e := range cfg.Endpoints {
+		var eCfg endpointConfig
+		err := decode(e.ExtraConfig, &eCfg)
+		if err == ErrNoConfig {
+			defaultCfg.Endpoints = append(defaultCfg.Endpoints, e)
+			continue
+		}
+		if err != nil {
+			return defaultCfg, nil, fmt.Errorf("endpoint %s: %w", e.Endpoint, err)
+		}
+		hCfg, ok := groups[eCfg.Host]
+		if !ok {
+			return defaultCfg, nil, fmt.Errorf("endpoint %s: unknown virtual host %s", e.Endpoint, eCfg.Host)
+		}
+		hCfg.Endpoints = append(hCfg.Endpoints, e)
+		groups[eCfg.Host] = hCfg
+	}
+	return defaultCfg, groups, nil
+}
+
+func decode(cfg config.ExtraConfig, v interface{}) error {
+	e, ok := cfg[Namespace]
+	if !ok {
+		return ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,141 @@
+package vhost
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+
+	"github.com/krakend/krakend-ce/v2/certloader"
+	"github.com/krakend/krakend-ce/v2/listener"
+)
+
+// Dispatcher sends the requests to the handler of the group of hosts matching the Host header.
+// Exact names take precedence over wildcards and the longest wildcard wins. The requests for
+// unknown hosts are sent to the default handler.
+type Dispatcher struct {
+	def       http.Handler
+	exact     map[string]http.Handler
+	wildcards []wildcard
+}
+
+type wildcard struct {
+	suffix  string
+	handler http.Handler
+}
+
+// NewDispatcher returns a dispatcher for the groups of hosts. The handlers are indexed by the name
+// of the group.
+func NewDispatcher(vCfg Config, handlers map[string]http.Handler, def http.Handler) *Dispatcher {
+	d := &Dispatcher{def: def, exact: map[string]http.Handler{}}
+	for _, h := range vCfg.Hosts {
+		handler, ok := handlers[h.Name]
+		if !ok {
+			continue
+		}
+		for _, host := range h.Hosts {
+			if strings.HasPrefix(host, "*.") {
+				d.wildcards = append(d.wildcards, wildcard{suffix: host[1:], handler: handler})
+				continue
+			}
+			d.exact[host] = handler
+		}
+	}
+	sort.SliceStable(d.wildcards, func(i, j int) bool {
+		return len(d.wildcards[i].suffix) > len(d.wildcards[j].suffix)
+	})
+	return d
+}
+
+func (d *Dispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.handler(r.Host).ServeHTTP(w, r)
+}
+
+func (d *Dispatcher) handler(host string) http.Handler {
+	host = hostname(host)
+	if h, ok := d.exact[host]; ok {
+		return h
+	}
+	for _, wc := range d.wildcards {
+		if strings.HasSuffix(host, wc.suffix) {
+			return wc.handler
+		}
+	}
+	return d.def
+}
+
+// NewRunServer wraps the handler received by the next run server function with a dispatcher for
+// the groups of hosts
+func NewRunServer(vCfg Config, handlers map[string]http.Handler, next func(context.Context, config.ServiceConfig, http.Handler) error) func(context.Context, config.ServiceConfig, http.Handler) error {
+	return func(ctx context.Context, cfg config.ServiceConfig, h http.Handler) error {
+		return next(ctx, cfg, NewDispatcher(vCfg, handlers, h))
+	}
+}
+
+// TLSModifier returns a listener.TLSModifier selecting the certificate of the group of hosts
+// matching the SNI of the client. The certificates are reloaded when rotated. When no group
+// matches, the previous certificate selection applies.
+func TLSModifier(l logging.Logger) listener.TLSModifier {
+	return func(ctx context.Context, cfg config.ServiceConfig, tlsCfg *tls.Config) error {
+		vCfg, err := ParseConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		keys := map[string]*certloader.Keypair{}
+		var wildcards []string
+		for _, h := range vCfg.Hosts {
+			if h.TLS == nil {
+				continue
+			}
+			k, err := certloader.NewKeypair(ctx, l, h.TLS.PublicKey, h.TLS.PrivateKey)
+			if err != nil {
+				return err
+			}
+			for _, host := range h.Hosts {
+				keys[host] = k
+				if strings.HasPrefix(host, "*.") {
+					wildcards = append(wildcards, host)
+				}
+			}
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		sort.SliceStable(wildcards, func(i, j int) bool { return len(wildcards[i]) > len(wildcards[j]) })
+
+		next := tlsCfg.GetCertificate
+		tlsCfg.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := strings.ToLower(hello.ServerName)
+			if k, ok := keys[name]; ok {
+				return k.Certificate(), nil
+			}
+			for _, wc := range wildcards {
+				if strings.HasSuffix(name, wc[1:]) {
+					return keys[wc].Certificate(), nil
+				}
+			}
+			if next != nil {
+				return next(hello)
+			}
+			// let the tls package select one of the static certificates
+			return nil, nil
+		}
+		return nil
+	}
+}
+
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,97 @@
+package vhost
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestSplit(t *testing.T) {
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"hosts": []interface{}{
+					map[string]interface{}{"name": "a", "hosts": []string{"api.a.com", "*.A.com"}},
+					map[string]interface{}{
+						"name":         "b",
+						"hosts":        []string{"api.b.com"},
+						"extra_config": map[string]interface{}{"foo": "bar"},
+					},
+				},
+			},
+			"foo": "baz",
+		},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/users", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"host": "a"}}},
+			{Endpoint: "/users", ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"host": "b"}}},
+			{Endpoint: "/status"},
+		},
+	}
+
+	vCfg, err := ParseConfig(cfg.ExtraConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, groups, err := Split(cfg, vCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(def.Endpoints) != 1 || def.Endpoints[0].Endpoint != "/status" {
+		t.Errorf("unexpected default endpoints: %v", def.Endpoints)
+	}
+	if len(groups["a"].Endpoints) != 1 || len(groups["b"].Endpoints) != 1 {
+		t.Errorf("unexpected groups: %v", groups)
+	}
+	if groups["a"].ExtraConfig["foo"] != "baz" || groups["b"].ExtraConfig["foo"] != "bar" || cfg.ExtraConfig["foo"] != "baz" {
+		t.Error("unexpected extra config")
+	}
+
+	cfg.Endpoints[0].ExtraConfig[Namespace] = map[string]interface{}{"host": "c"}
+	if _, _, err := Split(cfg, vCfg); err == nil {
+		t.Error("expecting an error for an unknown virtual host")
+	}
+}
+
+func TestSplit_tlsWithoutServiceTLS(t *testing.T) {
+	vCfg := Config{Hosts: []Host{
+		{Name: "a", Hosts: []string{"api.a.com"}, TLS: &KeyPair{PublicKey: "a.pem", PrivateKey: "a.key"}},
+	}}
+	for _, tlsCfg := range []*config.TLS{nil, {IsDisabled: true}} {
+		if _, _, err := Split(config.ServiceConfig{TLS: tlsCfg}, vCfg); err == nil {
+			t.Errorf("expecting an error for the service TLS %v", tlsCfg)
+		}
+	}
+	if _, _, err := Split(config.ServiceConfig{TLS: &config.TLS{}}, vCfg); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	vCfg := Config{Hosts: []Host{
+		{Name: "a", Hosts: []string{"*.a.com"}},
+		{Name: "api", Hosts: []string{"api.a.com", "*.api.a.com"}},
+	}}
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte(name)) })
+	}
+	d := NewDispatcher(vCfg, map[string]http.Handler{"a": handler("a"), "api": handler("api")}, handler("default"))
+
+	for host, expected := range map[string]string{
+		"api.a.com:8080":  "api",
+		"API.A.COM":       "api",
+		"v1.api.a.com":    "api",
+		"www.a.com":       "a",
+		"a.com":           "default",
+		"www.example.com": "default",
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		d.ServeHTTP(w, req)
+		if w.Body.String() != expected {
+			t.Errorf("%s: unexpected handler %s", host, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package waf inspects the requests with a rule set written in a subset of the ModSecurity SecRule
+// language, so the rules of the OWASP CRS relying only on the supported variables, operators,
+// transformations and actions can be used as they are.
+//
+// The rules are evaluated independently: there are no transaction variables, so the setvar action,
+// the TX collection and the anomaly scoring mode of the CRS (where the rules add to a score checked
+// by a final blocking rule) are not supported. The rule sets using them are rejected when loaded.
+// Each matching rule with a deny or block action interrupts the request by itself.
+//
+// The matches of every rule are counted in the metrics registry as waf.rule.<id>.matches and
+// waf.rule.<id>.blocked.
+package waf
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key used to store the WAF config at the ExtraConfig struct
+const Namespace = "security/waf"
+
+const (
+	// ModeBlocking interrupts the requests matching a rule with a deny or block action
+	ModeBlocking = "blocking"
+	// ModeDetectionOnly logs the matches without interrupting any request
+	ModeDetectionOnly = "detection_only"
+	// ModeOff disables the WAF
+	ModeOff = "off"
+
+	defaultMaxBodySize = 128 * 1024
+	defaultStatus      = 403
+)
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// Config defines the rule set and how the WAF behaves
+type Config struct {
+	// Rules contains inline directives (SecRule, SecRuleEngine and SecRuleRemoveById)
+	Rules []string `json:"rules"`
+	// RuleFiles is a list of paths or glob patterns of files with directives, loaded after Rules
+	RuleFiles []string `json:"rule_files"`
+	// Mode overrides the SecRuleEngine directive. Default: blocking
+	Mode string `json:"mode"`
+	// MaxBodySize is the max number of bytes of the body to inspect. Default: 128KB
+	MaxBodySize int64 `json:"max_body_size"`
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	switch res.Mode {
+	case "", ModeBlocking, ModeDetectionOnly, ModeOff:
+	default:
+		return res, errors.New("unknown mode: " + res.Mode)
+	}
+	if res.MaxBodySize <= 0 {
+		res.MaxBodySize = defaultMaxBodySize
+	}
+	return res, nil
+}
@@ -0,0 +1,62 @@
+package waf
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+const logPrefix = "[SERVICE: Gin][WAF]"
+
+// Register checks the configuration and, if required, registers a WAF middleware at the gin engine.
+// The matches are counted in the registry, if any.
+func Register(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine, registry gometrics.Registry) {
+	wCfg, err := ParseConfig(cfg.ExtraConfig)
+	if err == ErrNoConfig {
+		return
+	}
+	if err != nil {
+		l.Warning(logPrefix, err.Error())
+		return
+	}
+	w, err := New(wCfg, registry)
+	if err != nil {
+		l.Error(logPrefix, "Unable to load the rules, rejecting all the requests:", err.Error())
+		engine.Use(erroredHandler)
+		return
+	}
+	if !w.Enabled() {
+		l.Info(logPrefix, "The rule engine is off")
+		return
+	}
+
+	l.Debug(logPrefix, fmt.Sprintf("The WAF has been registered successfully with %d rules in %s mode", len(w.rules), w.mode))
+	engine.Use(middleware(w, l))
+}
+
+func middleware(w *WAF, l logging.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		matches, err := w.Inspect(c.Request)
+		if err != nil {
+			l.Warning(logPrefix, "Unable to read the request:", err.Error())
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		for _, m := range matches {
+			l.Warning(logPrefix, fmt.Sprintf("Rule %d matched at %s %s (%s): %s blocked: %t", m.RuleID, c.Request.Method, c.Request.URL.Path, m.Variable, m.Msg, m.Blocked))
+			if m.Blocked {
+				c.AbortWithStatus(m.Status)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func erroredHandler(c *gin.Context) {
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
@@ -0,0 +1,142 @@
+package waf
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/krakend/krakend-ce/v2/clientip"
+)
+
+type operator func(value string) bool
+
+func parseOperator(op, dir string) (operator, bool, error) {
+	negated := false
+	if strings.HasPrefix(op, "!") {
+		negated = true
+		op = op[1:]
+	}
+	if !strings.HasPrefix(op, "@") {
+		op = "@rx " + op
+	}
+	name, arg, _ := strings.Cut(op[1:], " ")
+	arg = strings.TrimSpace(arg)
+	if strings.Contains(arg, "%{") {
+		return nil, false, fmt.Errorf("macro expansion is not supported: %s", arg)
+	}
+
+	switch name {
+	case "rx":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, false, err
+		}
+		return re.MatchString, negated, nil
+	case "streq":
+		return func(v string) bool { return v == arg }, negated, nil
+	case "contains":
+		return func(v string) bool { return strings.Contains(v, arg) }, negated, nil
+	case "containsWord":
+		re := regexp.MustCompile(`\b` + regexp.QuoteMeta(arg) + `\b`)
+		return re.MatchString, negated, nil
+	case "beginsWith":
+		return func(v string) bool { return strings.HasPrefix(v, arg) }, negated, nil
+	case "endsWith":
+		return func(v string) bool { return strings.HasSuffix(v, arg) }, negated, nil
+	case "within":
+		return func(v string) bool { return strings.Contains(arg, v) }, negated, nil
+	case "pm":
+		return phraseMatch(strings.Fields(arg)), negated, nil
+	case "pmFromFile", "pmf":
+		phrases, err := readPhrases(arg, dir)
+		if err != nil {
+			return nil, false, err
+		}
+		return phraseMatch(phrases), negated, nil
+	case "eq", "ge", "gt", "le", "lt":
+		return numeric(name, arg), negated, nil
+	case "ipMatch":
+		prefixes, err := clientip.ParsePrefixes(strings.Split(arg, ","))
+		if err != nil {
+			return nil, false, err
+		}
+		return func(v string) bool {
+			ip, err := netip.ParseAddr(v)
+			if err != nil {
+				return false
+			}
+			return clientip.Contains(prefixes, ip.Unmap())
+		}, negated, nil
+	case "unconditionalMatch":
+		return func(string) bool { return true }, negated, nil
+	case "noMatch":
+		return func(string) bool { return false }, negated, nil
+	}
+	return nil, false, fmt.Errorf("unsupported operator @%s", name)
+}
+
+// phraseMatch matches if the value contains any of the phrases, ignoring the case
+func phraseMatch(phrases []string) operator {
+	for i, p := range phrases {
+		phrases[i] = strings.ToLower(p)
+	}
+	return func(v string) bool {
+		v = strings.ToLower(v)
+		for _, p := range phrases {
+			if strings.Contains(v, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func numeric(name, arg string) operator {
+	expected, _ := strconv.Atoi(arg)
+	return func(v string) bool {
+		n, _ := strconv.Atoi(strings.TrimSpace(v))
+		switch name {
+		case "eq":
+			return n == expected
+		case "ge":
+			return n >= expected
+		case "gt":
+			return n > expected
+		case "le":
+			return n <= expected
+		default:
+			return n < expected
+		}
+	}
+}
+
+func readPhrases(files, dir string) ([]string, error) {
+	var res []string
+	for _, path := range strings.Fields(files) {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			line := strings.TrimSpace(s.Text())
+			if line == "" || line[0] == '#' {
+				continue
+			}
+			res = append(res, line)
+		}
+		f.Close()
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,368 @@
+package waf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rule is a parsed SecRule
+type Rule struct {
+	ID     int
+	Msg    string
+	Action string
+	Status int
+
+	vars       []variable
+	op         operator
+	negated    bool
+	transforms []transform
+	chain      *Rule
+}
+
+type variable struct {
+	collection string
+	key        string
+	keyRe      *regexp.Regexp
+	count      bool
+	exclude    bool
+}
+
+// ruleSet is the result of parsing a list of directives
+type ruleSet struct {
+	rules   []*Rule
+	engine  string
+	removed [][2]int
+}
+
+var actionsWithoutArgs = map[string]struct{}{
+	"auditlog": {}, "noauditlog": {}, "log": {}, "nolog": {}, "capture": {},
+}
+
+var metadataActions = map[string]struct{}{
+	"phase": {}, "severity": {}, "tag": {}, "rev": {}, "ver": {}, "maturity": {}, "accuracy": {},
+	"logdata": {},
+}
+
+// parse adds the directives of the source to the rule set. The dir is used to resolve the
+// relative paths of the operators reading files.
+func (rs *ruleSet) parse(src, dir string) error {
+	var chainParent *Rule
+	for i, line := range logicalLines(src) {
+		args, err := splitArgs(line)
+		if err != nil {
+			return fmt.Errorf("directive %d: %w", i+1, err)
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		switch args[0] {
+		case "SecRule":
+			if len(args) < 3 || len(args) > 4 {
+				return fmt.Errorf("directive %d: SecRule requires variables, operator and actions", i+1)
+			}
+			actions := ""
+			if len(args) == 4 {
+				actions = args[3]
+			}
+			r, chained, err := parseRule(args[1], args[2], actions, dir, chainParent != nil)
+			if err != nil {
+				return fmt.Errorf("directive %d: %w", i+1, err)
+			}
+			if chainParent != nil {
+				chainParent.chain = r
+			} else {
+				rs.rules = append(rs.rules, r)
+			}
+			chainParent = nil
+			if chained {
+				chainParent = r
+			}
+
+		case "SecRuleEngine":
+			if len(args) != 2 {
+				return fmt.Errorf("directive %d: SecRuleEngine requires a single value", i+1)
+			}
+			switch strings.ToLower(args[1]) {
+			case "on":
+				rs.engine = ModeBlocking
+			case "detectiononly":
+				rs.engine = ModeDetectionOnly
+			case "off":
+				rs.engine = ModeOff
+			default:
+				return fmt.Errorf("directive %d: unknown SecRuleEngine value %s", i+1, args[1])
+			}
+
+		case "SecRuleRemoveById":
+			for _, v := range args[1:] {
+				from, to, err := parseIDRange(v)
+				if err != nil {
+					return fmt.Errorf("directive %d: %w", i+1, err)
+				}
+				rs.removed = append(rs.removed, [2]int{from, to})
+			}
+
+		case "SecMarker", "SecComponentSignature":
+
+		default:
+			return fmt.Errorf("directive %d: unsupported directive %s", i+1, args[0])
+		}
+	}
+	if chainParent != nil {
+		return fmt.Errorf("the last chain is not closed")
+	}
+	return nil
+}
+
+// parseFile loads the directives of the files matching the pattern
+func (rs *ruleSet) parseFile(pattern string) error {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no rule files found at %s", pattern)
+	}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := rs.parse(string(b), filepath.Dir(path)); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// active returns the rules not removed by a SecRuleRemoveById directive
+func (rs *ruleSet) active() []*Rule {
+	res := make([]*Rule, 0, len(rs.rules))
+	for _, r := range rs.rules {
+		removed := false
+		for _, ids := range rs.removed {
+			if r.ID >= ids[0] && r.ID <= ids[1] {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+func parseRule(vars, op, actions, dir string, chained bool) (*Rule, bool, error) {
+	r := &Rule{Status: defaultStatus}
+
+	for _, v := range strings.Split(vars, "|") {
+		parsed, err := parseVariable(strings.TrimSpace(v))
+		if err != nil {
+			return nil, false, err
+		}
+		r.vars = append(r.vars, parsed)
+	}
+
+	var err error
+	if r.op, r.negated, err = parseOperator(op, dir); err != nil {
+		return nil, false, err
+	}
+
+	hasChain := false
+	for _, a := range splitActions(actions) {
+		name, value, _ := strings.Cut(a, ":")
+		name = strings.TrimSpace(name)
+		value = strings.Trim(strings.TrimSpace(value), "'")
+
+		switch name {
+		case "id":
+			if r.ID, err = strconv.Atoi(value); err != nil || r.ID <= 0 {
+				return nil, false, fmt.Errorf("invalid rule id %s", value)
+			}
+		case "msg":
+			r.Msg = value
+		case "deny", "block", "pass":
+			r.Action = name
+		case "status":
+			if r.Status, err = strconv.Atoi(value); err != nil {
+				return nil, false, fmt.Errorf("invalid status %s", value)
+			}
+		case "t":
+			if value == "none" {
+				r.transforms = nil
+				continue
+			}
+			t, ok := transforms[value]
+			if !ok {
+				return nil, false, fmt.Errorf("unsupported transformation %s", value)
+			}
+			r.transforms = append(r.transforms, t)
+		case "chain":
+			hasChain = true
+		case "":
+		default:
+			_, noArgs := actionsWithoutArgs[name]
+			_, metadata := metadataActions[name]
+			if !noArgs && !metadata {
+				return nil, false, fmt.Errorf("unsupported action %s", name)
+			}
+			if name == "phase" && value != "1" && value != "2" && value != "request" {
+				return nil, false, fmt.Errorf("unsupported phase %s", value)
+			}
+		}
+	}
+
+	if chained {
+		if r.ID != 0 || r.Action != "" {
+			return nil, false, fmt.Errorf("chained rules can not declare an id or a disruptive action")
+		}
+		return r, hasChain, nil
+	}
+	if r.ID == 0 {
+		return nil, false, fmt.Errorf("the rule has no id")
+	}
+	if r.Action == "" {
+		r.Action = "pass"
+	}
+	return r, hasChain, nil
+}
+
+func parseVariable(v string) (variable, error) {
+	res := variable{}
+	if strings.HasPrefix(v, "!") {
+		res.exclude = true
+		v = v[1:]
+	}
+	if strings.HasPrefix(v, "&") {
+		res.count = true
+		v = v[1:]
+	}
+
+	name, key, hasKey := strings.Cut(v, ":")
+	res.collection = strings.ToUpper(name)
+	if _, ok := collections[res.collection]; !ok {
+		return res, fmt.Errorf("unsupported variable %s", name)
+	}
+	if !hasKey {
+		if res.exclude {
+			return res, fmt.Errorf("exclusions require a key: %s", v)
+		}
+		return res, nil
+	}
+
+	key = strings.Trim(key, "'")
+	if len(key) > 1 && key[0] == '/' && key[len(key)-1] == '/' {
+		re, err := regexp.Compile(key[1 : len(key)-1])
+		if err != nil {
+			return res, err
+		}
+		res.keyRe = re
+		return res, nil
+	}
+	res.key = key
+	return res, nil
+}
+
+func parseIDRange(v string) (int, int, error) {
+	from, to, isRange := strings.Cut(v, "-")
+	f, err := strconv.Atoi(from)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid rule id %s", v)
+	}
+	if !isRange {
+		return f, f, nil
+	}
+	t, err := strconv.Atoi(to)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid rule id range %s", v)
+	}
+	return f, t, nil
+}
+
+// logicalLines joins the lines ending with a backslash and drops the comments and the empty lines
+func logicalLines(src string) []string {
+	var res []string
+	current := ""
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.HasSuffix(line, "\\") {
+			current += line[:len(line)-1]
+			continue
+		}
+		line = strings.TrimSpace(current + line)
+		current = ""
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		res = append(res, line)
+	}
+	if line := strings.TrimSpace(current); line != "" && line[0] != '#' {
+		res = append(res, line)
+	}
+	return res
+}
+
+// splitArgs splits a directive by spaces. Double quoted arguments can contain spaces and escaped
+// quotes. Any other backslash is kept, so regular expressions can be written as they are.
+func splitArgs(line string) ([]string, error) {
+	var res []string
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == ' ' || line[i] == '\t':
+			i++
+		case line[i] == '"':
+			var sb strings.Builder
+			i++
+			closed := false
+			for ; i < len(line); i++ {
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '"' {
+					sb.WriteByte('"')
+					i++
+					continue
+				}
+				if line[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(line[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unclosed quote")
+			}
+			res = append(res, sb.String())
+		default:
+			start := i
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+			res = append(res, line[start:i])
+		}
+	}
+	return res, nil
+}
+
+// splitActions splits the action list by commas not enclosed in single quotes
+func splitActions(actions string) []string {
+	var res []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(actions); i++ {
+		switch actions[i] {
+		case '\'':
+			inQuotes = !inQuotes
+		case ',':
+			if !inQuotes {
+				res = append(res, strings.TrimSpace(actions[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(res, strings.TrimSpace(actions[start:]))
+}
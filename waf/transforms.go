@@ -0,0 +1,96 @@
+package waf
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"html"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type transform func(string) string
+
+var transforms = map[string]transform{
+	"lowercase":          strings.ToLower,
+	"uppercase":          strings.ToUpper,
+	"trim":               strings.TrimSpace,
+	"trimLeft":           func(v string) string { return strings.TrimLeftFunc(v, unicode.IsSpace) },
+	"trimRight":          func(v string) string { return strings.TrimRightFunc(v, unicode.IsSpace) },
+	"compressWhitespace": func(v string) string { return strings.Join(strings.Fields(v), " ") },
+	"removeWhitespace":   func(v string) string { return strings.Join(strings.Fields(v), "") },
+	"removeNulls":        func(v string) string { return strings.ReplaceAll(v, "\x00", "") },
+	"replaceNulls":       func(v string) string { return strings.ReplaceAll(v, "\x00", " ") },
+	"urlDecode":          func(v string) string { return urlDecode(v, false) },
+	"urlDecodeUni":       func(v string) string { return urlDecode(v, true) },
+	"htmlEntityDecode":   html.UnescapeString,
+	"base64Decode":       base64Decode,
+	"hexDecode":          hexDecode,
+	"length":             func(v string) string { return strconv.Itoa(len(v)) },
+	"normalisePath":      normalizePath,
+	"normalizePath":      normalizePath,
+	"normalisePathWin":   func(v string) string { return normalizePath(strings.ReplaceAll(v, "\\", "/")) },
+	"normalizePathWin":   func(v string) string { return normalizePath(strings.ReplaceAll(v, "\\", "/")) },
+}
+
+// urlDecode decodes the value like ModSecurity does: the invalid escape sequences are kept as they
+// are instead of failing
+func urlDecode(v string, unicodeEscapes bool) string {
+	if !strings.ContainsAny(v, "%+") {
+		return v
+	}
+	var sb strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch {
+		case v[i] == '+':
+			sb.WriteByte(' ')
+		case v[i] == '%' && unicodeEscapes && i+5 < len(v) && (v[i+1] == 'u' || v[i+1] == 'U'):
+			if r, err := strconv.ParseUint(v[i+2:i+6], 16, 32); err == nil {
+				sb.WriteRune(rune(r))
+				i += 5
+				continue
+			}
+			sb.WriteByte(v[i])
+		case v[i] == '%' && i+2 < len(v):
+			if b, err := strconv.ParseUint(v[i+1:i+3], 16, 8); err == nil {
+				sb.WriteByte(byte(b))
+				i += 2
+				continue
+			}
+			sb.WriteByte(v[i])
+		default:
+			sb.WriteByte(v[i])
+		}
+	}
+	return sb.String()
+}
+
+func base64Decode(v string) string {
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		if b, err = base64.RawStdEncoding.DecodeString(v); err != nil {
+			return v
+		}
+	}
+	return string(b)
+}
+
+func hexDecode(v string) string {
+	b, err := hex.DecodeString(v)
+	if err != nil {
+		return v
+	}
+	return string(b)
+}
+
+func normalizePath(v string) string {
+	if v == "" {
+		return v
+	}
+	res := path.Clean(v)
+	if strings.HasSuffix(v, "/") && res != "/" {
+		res += "/"
+	}
+	return res
+}
@@ -0,0 +1,350 @@
+package waf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// collections lists the supported variables
+var collections = map[string]struct{}{
+	"ARGS": {}, "ARGS_NAMES": {}, "ARGS_GET": {}, "ARGS_GET_NAMES": {}, "ARGS_POST": {},
+	"ARGS_POST_NAMES": {}, "QUERY_STRING": {}, "REQUEST_URI": {}, "REQUEST_FILENAME": {},
+	"REQUEST_METHOD": {}, "REQUEST_PROTOCOL": {}, "REQUEST_HEADERS": {}, "REQUEST_HEADERS_NAMES": {},
+	"REQUEST_COOKIES": {}, "REQUEST_COOKIES_NAMES": {}, "REQUEST_BODY": {}, "REMOTE_ADDR": {},
+}
+
+// Match describes a rule matching a request
+type Match struct {
+	RuleID   int
+	Msg      string
+	Variable string
+	// Blocked is true when the request has been interrupted by the rule
+	Blocked bool
+	Status  int
+}
+
+// WAF evaluates a rule set against the requests
+type WAF struct {
+	rules       []*Rule
+	mode        string
+	maxBodySize int64
+	// matches and blocked count the requests by rule id
+	matches map[int]gometrics.Counter
+	blocked map[int]gometrics.Counter
+}
+
+// New parses the rules and files of the config and returns a WAF ready to inspect requests. The
+// matches are counted in the registry, if any.
+func New(cfg Config, registry gometrics.Registry) (*WAF, error) {
+	rs := &ruleSet{}
+	if err := rs.parse(strings.Join(cfg.Rules, "\n"), "."); err != nil {
+		return nil, err
+	}
+	for _, pattern := range cfg.RuleFiles {
+		if err := rs.parseFile(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	w := &WAF{
+		rules:       rs.active(),
+		mode:        cfg.Mode,
+		maxBodySize: cfg.MaxBodySize,
+	}
+	if w.mode == "" {
+		w.mode = rs.engine
+	}
+	if w.mode == "" {
+		w.mode = ModeBlocking
+	}
+	if w.maxBodySize <= 0 {
+		w.maxBodySize = defaultMaxBodySize
+	}
+
+	w.matches = make(map[int]gometrics.Counter, len(w.rules))
+	w.blocked = make(map[int]gometrics.Counter, len(w.rules))
+	for _, rule := range w.rules {
+		w.matches[rule.ID], w.blocked[rule.ID] = gometrics.NilCounter{}, gometrics.NilCounter{}
+		if registry != nil {
+			w.matches[rule.ID] = gometrics.GetOrRegisterCounter(fmt.Sprintf("waf.rule.%d.matches", rule.ID), registry)
+			w.blocked[rule.ID] = gometrics.GetOrRegisterCounter(fmt.Sprintf("waf.rule.%d.blocked", rule.ID), registry)
+		}
+	}
+	return w, nil
+}
+
+// Enabled returns false if the rule engine has been turned off
+func (w *WAF) Enabled() bool {
+	return w.mode != ModeOff
+}
+
+// Inspect evaluates the rules against the request in the declared order, until a rule interrupts
+// it. The inspected part of the body is restored, so the request can be consumed after this.
+func (w *WAF) Inspect(r *http.Request) ([]Match, error) {
+	tx, err := w.newTransaction(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Match
+	for _, rule := range w.rules {
+		target, ok := rule.matches(tx)
+		if !ok {
+			continue
+		}
+		m := Match{
+			RuleID:   rule.ID,
+			Msg:      rule.Msg,
+			Variable: target,
+			Blocked:  w.mode == ModeBlocking && rule.Action != "pass",
+			Status:   rule.Status,
+		}
+		w.matches[m.RuleID].Inc(1)
+		res = append(res, m)
+		if m.Blocked {
+			w.blocked[m.RuleID].Inc(1)
+			break
+		}
+	}
+	return res, nil
+}
+
+type field struct {
+	key   string
+	value string
+}
+
+type transaction map[string][]field
+
+func (w *WAF) newTransaction(r *http.Request) (transaction, error) {
+	tx := transaction{
+		"QUERY_STRING":     {{value: r.URL.RawQuery}},
+		"REQUEST_URI":      {{value: r.URL.RequestURI()}},
+		"REQUEST_FILENAME": {{value: r.URL.Path}},
+		"REQUEST_METHOD":   {{value: r.Method}},
+		"REQUEST_PROTOCOL": {{value: r.Proto}},
+	}
+	if r.RequestURI != "" {
+		tx["REQUEST_URI"] = []field{{value: r.RequestURI}}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		tx["REMOTE_ADDR"] = []field{{value: host}}
+	} else {
+		tx["REMOTE_ADDR"] = []field{{value: r.RemoteAddr}}
+	}
+
+	tx["ARGS_GET"] = fromValues(r.URL.Query())
+
+	headers := []field{{key: "Host", value: r.Host}}
+	for _, k := range sortedKeys(r.Header) {
+		for _, v := range r.Header[k] {
+			headers = append(headers, field{key: k, value: v})
+		}
+	}
+	tx["REQUEST_HEADERS"] = headers
+
+	var cookies []field
+	for _, c := range r.Cookies() {
+		cookies = append(cookies, field{key: c.Name, value: c.Value})
+	}
+	tx["REQUEST_COOKIES"] = cookies
+
+	body, err := w.readBody(r)
+	if err != nil {
+		return nil, err
+	}
+	tx["REQUEST_BODY"] = []field{{value: string(body)}}
+	tx["ARGS_POST"] = bodyArgs(r.Header.Get("Content-Type"), body)
+
+	tx["ARGS"] = append(append([]field{}, tx["ARGS_GET"]...), tx["ARGS_POST"]...)
+	for _, c := range []string{"ARGS", "ARGS_GET", "ARGS_POST", "REQUEST_HEADERS", "REQUEST_COOKIES"} {
+		names := make([]field, 0, len(tx[c]))
+		for _, f := range tx[c] {
+			names = append(names, field{key: f.key, value: f.key})
+		}
+		tx[c+"_NAMES"] = names
+	}
+	return tx, nil
+}
+
+// readBody reads up to maxBodySize bytes of the body and puts them back in front of the rest
+func (w *WAF) readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, w.maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+	return b, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func bodyArgs(contentType string, body []byte) []field {
+	if len(body) == 0 {
+		return nil
+	}
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		values, _ := url.ParseQuery(string(body))
+		return fromValues(values)
+
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return nil
+		}
+		var res []field
+		flatten("json", v, &res)
+		return res
+
+	case mediaType == "multipart/form-data":
+		var res []field
+		mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				return res
+			}
+			if p.FileName() != "" {
+				continue
+			}
+			v, _ := io.ReadAll(p)
+			res = append(res, field{key: p.FormName(), value: string(v)})
+		}
+	}
+	return nil
+}
+
+// flatten adds the leaves of the json document with their dotted path as key (json.a.0.b)
+func flatten(prefix string, v interface{}, res *[]field) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(t) {
+			flatten(prefix+"."+k, t[k], res)
+		}
+	case []interface{}:
+		for i, e := range t {
+			flatten(prefix+"."+strconv.Itoa(i), e, res)
+		}
+	case nil:
+		*res = append(*res, field{key: prefix})
+	default:
+		*res = append(*res, field{key: prefix, value: fmt.Sprint(t)})
+	}
+}
+
+func fromValues(values url.Values) []field {
+	var res []field
+	for _, k := range sortedKeys(values) {
+		for _, v := range values[k] {
+			res = append(res, field{key: k, value: v})
+		}
+	}
+	return res
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// matches returns the name of the first variable matching the rule and its chain
+func (r *Rule) matches(tx transaction) (string, bool) {
+	for _, t := range r.targets(tx) {
+		v := t.value
+		for _, fn := range r.transforms {
+			v = fn(v)
+		}
+		if r.op(v) == r.negated {
+			continue
+		}
+		if r.chain != nil {
+			if _, ok := r.chain.matches(tx); !ok {
+				return "", false
+			}
+		}
+		return t.key, true
+	}
+	return "", false
+}
+
+// targets returns the values selected by the variables of the rule, using the variable name and
+// the key as the key of every field
+func (r *Rule) targets(tx transaction) []field {
+	var res []field
+	for _, v := range r.vars {
+		if v.exclude {
+			continue
+		}
+		selected := v.selected(tx[v.collection])
+		if v.count {
+			res = append(res, field{key: "&" + v.collection, value: strconv.Itoa(len(selected))})
+			continue
+		}
+		for _, f := range selected {
+			if r.excluded(v.collection, f.key) {
+				continue
+			}
+			key := v.collection
+			if f.key != "" {
+				key += ":" + f.key
+			}
+			res = append(res, field{key: key, value: f.value})
+		}
+	}
+	return res
+}
+
+func (r *Rule) excluded(collection, key string) bool {
+	for _, v := range r.vars {
+		if v.exclude && v.collection == collection && v.matchesKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v variable) selected(fields []field) []field {
+	if v.key == "" && v.keyRe == nil {
+		return fields
+	}
+	var res []field
+	for _, f := range fields {
+		if v.matchesKey(f.key) {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+func (v variable) matchesKey(key string) bool {
+	if v.keyRe != nil {
+		return v.keyRe.MatchString(key)
+	}
+	return strings.EqualFold(v.key, key)
+}
@@ -0,0 +1,129 @@
+package waf
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+const testRules = `
+SecRuleEngine On
+
+# reject some well known scanners
+SecRule REQUEST_HEADERS:User-Agent "@pm nikto sqlmap" \
+	"id:1001,phase:1,deny,status:403,msg:'Scanner detected',t:lowercase"
+
+SecRule ARGS|!ARGS:comment "(?i)union\s+select" \
+	"id:1002,phase:2,block,msg:'SQL injection',t:urlDecodeUni,t:compressWhitespace"
+
+SecRule REQUEST_METHOD "@streq DELETE" "id:1003,pass,msg:'Delete request',chain"
+	SecRule &REQUEST_HEADERS:Authorization "@eq 0"
+
+SecRule REQUEST_FILENAME "@beginsWith /admin" "id:1004,deny,status:404"
+SecRuleRemoveById 1004
+`
+
+func TestWAF(t *testing.T) {
+	registry := gometrics.NewRegistry()
+	w, err := New(Config{Rules: []string{testRules}}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.rules) != 3 {
+		t.Fatalf("unexpected number of rules: %d", len(w.rules))
+	}
+
+	for _, tc := range []struct {
+		name    string
+		method  string
+		url     string
+		body    string
+		ctype   string
+		headers map[string]string
+		rule    int
+		blocked bool
+	}{
+		{name: "clean", method: "GET", url: "/foo?q=bar"},
+		{name: "scanner", method: "GET", url: "/", headers: map[string]string{"User-Agent": "Mozilla/5.0 SQLMap/1.0"}, rule: 1001, blocked: true},
+		{name: "sqli query", method: "GET", url: "/foo?id=1%20UNION%20%20SELECT%20pass", rule: 1002, blocked: true},
+		{name: "sqli excluded", method: "GET", url: "/foo?comment=union+select"},
+		{name: "sqli form", method: "POST", url: "/foo", body: "id=1+union+select+1", ctype: "application/x-www-form-urlencoded", rule: 1002, blocked: true},
+		{name: "sqli json", method: "POST", url: "/foo", body: `{"a":[{"b":"union select"}]}`, ctype: "application/json", rule: 1002, blocked: true},
+		{name: "anonymous delete", method: "DELETE", url: "/foo", rule: 1003},
+		{name: "authorized delete", method: "DELETE", url: "/foo", headers: map[string]string{"Authorization": "Bearer x"}},
+		{name: "removed rule", method: "GET", url: "/admin"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			if tc.ctype != "" {
+				req.Header.Set("Content-Type", tc.ctype)
+			}
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+
+			matches, err := w.Inspect(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.rule == 0 {
+				if len(matches) > 0 {
+					t.Fatalf("unexpected matches: %+v", matches)
+				}
+				return
+			}
+			if len(matches) != 1 || matches[0].RuleID != tc.rule || matches[0].Blocked != tc.blocked {
+				t.Fatalf("unexpected matches: %+v", matches)
+			}
+
+			b, _ := io.ReadAll(req.Body)
+			if string(b) != tc.body {
+				t.Errorf("the body has not been restored: %q", string(b))
+			}
+		})
+	}
+
+	for name, want := range map[string]int64{
+		"waf.rule.1002.matches": 3,
+		"waf.rule.1002.blocked": 3,
+		"waf.rule.1003.matches": 1,
+		"waf.rule.1003.blocked": 0,
+	} {
+		c, ok := registry.Get(name).(gometrics.Counter)
+		if !ok || c.Count() != want {
+			t.Errorf("unexpected value of %s: %v", name, registry.Get(name))
+		}
+	}
+}
+
+func TestWAF_detectionOnly(t *testing.T) {
+	w, err := New(Config{Mode: ModeDetectionOnly, Rules: []string{testRules}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err := w.Inspect(httptest.NewRequest("GET", "/foo?id=union%20select", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Blocked {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
+
+func TestNew_unsupported(t *testing.T) {
+	for _, rule := range []string{
+		`SecRule ARGS "@detectSQLi" "id:1,deny"`,
+		`SecRule TX:score "@gt 5" "id:1,deny"`,
+		`SecRule ARGS "foo" "id:1,deny,setvar:tx.score=+5"`,
+		`SecRule ARGS "foo" "deny"`,
+		`SecRule ARGS "foo" "id:1,deny,chain"`,
+		`SecAction "id:1,pass"`,
+	} {
+		if _, err := New(Config{Rules: []string{rule}}, nil); err == nil {
+			t.Errorf("expecting an error for %s", rule)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Package websocket proxies the WebSocket connections of the endpoints to their backends. The
+// handshake goes through the whole handler chain, so the authentication, the filters and the
+// rate limits of the endpoint apply before upgrading the connection.
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+// Namespace is the key used to store the websocket config at the endpoint ExtraConfig
+const Namespace = "websocket"
+
+const (
+	defaultMaxMessageSize = 64 * 1024
+	defaultIdleTimeout    = 5 * time.Minute
+)
+
+// ErrNoConfig is returned when there is no config defined for the module
+var ErrNoConfig = errors.New("no config defined for the module")
+
+// Config defines the proxied WebSocket connections of an endpoint. The backends are reached at
+// their http hosts, replacing the scheme with ws or wss.
+type Config struct {
+	// MaxMessageSize is the max number of bytes of a message, in both directions. Default: 64KB
+	MaxMessageSize int64 `json:"max_message_size"`
+	// IdleTimeout closes the connections without messages in any direction. Default: 5m
+	IdleTimeout string `json:"idle_timeout"`
+	// MessageRate is the max number of messages per second a client can send through a
+	// connection, with bursts of MessageBurst messages (default: the rate). Default: unlimited
+	MessageRate  float64 `json:"message_rate"`
+	MessageBurst int     `json:"message_burst"`
+	// AllowedOrigins are the origins accepted for the handshake. Default: the host of the request.
+	// Use "*" to accept any origin.
+	AllowedOrigins []string `json:"allowed_origins"`
+	// FanIn connects the client to all the backends of the endpoint: the messages of the client
+	// are sent to every backend and the messages of the backends are merged. The client connection
+	// is closed as soon as any backend closes its one. Otherwise, only the first backend is used.
+	FanIn bool `json:"fan_in"`
+
+	idleTimeout time.Duration
+}
+
+// ParseConfig extracts the module config from the ExtraConfig
+func ParseConfig(cfg config.ExtraConfig) (Config, error) {
+	res := Config{}
+	e, ok := cfg[Namespace]
+	if !ok {
+		return res, ErrNoConfig
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return res, err
+	}
+	if res.MaxMessageSize <= 0 {
+		res.MaxMessageSize = defaultMaxMessageSize
+	}
+	res.idleTimeout = defaultIdleTimeout
+	if res.IdleTimeout != "" {
+		if res.idleTimeout, err = time.ParseDuration(res.IdleTimeout); err != nil {
+			return res, err
+		}
+	}
+	if res.MessageRate > 0 && res.MessageBurst <= 0 {
+		res.MessageBurst = int(res.MessageRate)
+		if res.MessageBurst < 1 {
+			res.MessageBurst = 1
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,45 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	router "github.com/luraproject/lura/v2/router/gin"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// HandlerFactory checks the configuration and, if required, replaces the handler of the endpoint
+// with the WebSocket proxy. It should be the innermost layer of the handler factory, so the rest of
+// the middlewares apply to the handshake. The connections and messages are counted in the registry,
+// if any. The client certificates of the backends are reloaded until the context is cancelled.
+func HandlerFactory(ctx context.Context, hf router.HandlerFactory, l logging.Logger, registry gometrics.Registry) router.HandlerFactory {
+	return func(cfg *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		logPrefix := "[ENDPOINT: " + cfg.Endpoint + "][WebSocket]"
+
+		wCfg, err := ParseConfig(cfg.ExtraConfig)
+		if err == ErrNoConfig {
+			return hf(cfg, p)
+		}
+		if err != nil {
+			l.Error(logPrefix, err.Error())
+			return abort
+		}
+
+		wp, err := New(ctx, cfg, wCfg, l, registry)
+		if err != nil {
+			l.Error(logPrefix, "Unable to create the proxy:", err.Error())
+			return abort
+		}
+
+		l.Debug(logPrefix, "Proxying the connections to", len(wp.backends), "backend(s)")
+		return wp.HandlerFunc
+	}
+}
+
+func abort(c *gin.Context) {
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
@@ -0,0 +1,387 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ws "github.com/gorilla/websocket"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	gometrics "github.com/rcrowley/go-metrics"
+	"golang.org/x/time/rate"
+
+	"github.com/krakend/krakend-ce/v2/httpclient"
+)
+
+const closeTimeout = time.Second
+
+var (
+	errNoBackends = errors.New("the websocket endpoint requires at least one backend")
+	errMethod     = errors.New("the websocket endpoint must use the GET method")
+
+	// the handshake headers are set by the dialer
+	handshakeHeaders = map[string]struct{}{
+		"Connection":               {},
+		"Upgrade":                  {},
+		"Sec-Websocket-Key":        {},
+		"Sec-Websocket-Version":    {},
+		"Sec-Websocket-Extensions": {},
+		"Sec-Websocket-Protocol":   {},
+	}
+)
+
+// Proxy connects the WebSocket clients of an endpoint to its backends
+type Proxy struct {
+	cfg         Config
+	backends    []*target
+	headers     []string
+	queryString []string
+	upgrader    ws.Upgrader
+	l           logging.Logger
+	logPrefix   string
+
+	connections gometrics.Counter
+	upstream    gometrics.Counter
+	downstream  gometrics.Counter
+}
+
+type target struct {
+	hosts   []string
+	dialers []*ws.Dialer
+	pattern string
+	next    atomic.Uint64
+}
+
+// New returns a Proxy for the endpoint. The open connections and the proxied messages are counted
+// in the registry, if any, as websocket.<endpoint>.connections and
+// websocket.<endpoint>.messages.<upstream|downstream>.
+// The backends are dialed with the TLS settings and the unix sockets of their http client config,
+// reloading their client certificates until the context is cancelled.
+func New(ctx context.Context, cfg *config.EndpointConfig, wCfg Config, l logging.Logger, registry gometrics.Registry) (*Proxy, error) {
+	if cfg.Method != "" && cfg.Method != http.MethodGet {
+		return nil, errMethod
+	}
+	p := &Proxy{
+		cfg:         wCfg,
+		headers:     cfg.HeadersToPass,
+		queryString: cfg.QueryString,
+		l:           l,
+		logPrefix:   "[ENDPOINT: " + cfg.Endpoint + "][WebSocket]",
+	}
+	for _, b := range cfg.Backend {
+		if len(b.Host) == 0 {
+			continue
+		}
+		t, err := newTarget(ctx, b, cfg.Timeout, l)
+		if err != nil {
+			return nil, err
+		}
+		p.backends = append(p.backends, t)
+	}
+	if len(p.backends) == 0 {
+		return nil, errNoBackends
+	}
+	if !wCfg.FanIn {
+		p.backends = p.backends[:1]
+	}
+	p.upgrader = ws.Upgrader{
+		HandshakeTimeout: cfg.Timeout,
+		CheckOrigin:      checkOrigin(wCfg.AllowedOrigins),
+	}
+
+	p.connections, p.upstream, p.downstream = gometrics.NilCounter{}, gometrics.NilCounter{}, gometrics.NilCounter{}
+	if registry != nil {
+		prefix := "websocket." + cfg.Endpoint
+		p.connections = gometrics.GetOrRegisterCounter(prefix+".connections", registry)
+		p.upstream = gometrics.GetOrRegisterCounter(prefix+".messages.upstream", registry)
+		p.downstream = gometrics.GetOrRegisterCounter(prefix+".messages.downstream", registry)
+	}
+	return p, nil
+}
+
+// HandlerFunc dials the backends and, once connected, upgrades the client connection and proxies
+// the messages until any side closes its connection or the connection is idle
+func (p *Proxy) HandlerFunc(c *gin.Context) {
+	if !ws.IsWebSocketUpgrade(c.Request) {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if !p.upgrader.CheckOrigin(c.Request) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	header := p.backendHeader(c.Request)
+	backends := make([]*ws.Conn, 0, len(p.backends))
+	for _, t := range p.backends {
+		dialer, u := p.backendURL(t, c)
+		conn, resp, err := dialer.DialContext(c.Request.Context(), u, header)
+		if resp != nil && resp.Body != nil {
+			resp.Body.Close()
+		}
+		if err != nil {
+			p.l.Warning(p.logPrefix, "Unable to connect to the backend:", err.Error())
+			for _, b := range backends {
+				b.Close()
+			}
+			c.AbortWithStatus(http.StatusBadGateway)
+			return
+		}
+		backends = append(backends, conn)
+	}
+
+	var respHeader http.Header
+	if sp := backends[0].Subprotocol(); sp != "" {
+		respHeader = http.Header{"Sec-Websocket-Protocol": {sp}}
+	}
+	client, err := p.upgrader.Upgrade(c.Writer, c.Request, respHeader)
+	if err != nil {
+		// the upgrader already replied to the client
+		p.l.Debug(p.logPrefix, "Unable to upgrade the connection:", err.Error())
+		for _, b := range backends {
+			b.Close()
+		}
+		c.Abort()
+		return
+	}
+
+	p.connections.Inc(1)
+	defer p.connections.Dec(1)
+
+	newSession(p, client, backends).run()
+}
+
+// newTarget returns the target of the backend, with a dialer for each of its hosts
+func newTarget(ctx context.Context, b *config.Backend, timeout time.Duration, l logging.Logger) (*target, error) {
+	tlsCfg, err := httpclient.NewTLSConfig(ctx, b, l)
+	if err != nil {
+		return nil, err
+	}
+	t := &target{pattern: b.URLPattern}
+	for _, host := range b.Host {
+		d := &ws.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: timeout, TLSClientConfig: tlsCfg}
+		if socket, ok := httpclient.UnixSocket(host); ok {
+			// the proxies of the environment do not apply to the sockets
+			d.Proxy = nil
+			d.NetDialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			}
+			host = "http://localhost"
+		}
+		t.hosts = append(t.hosts, host)
+		t.dialers = append(t.dialers, d)
+	}
+	return t, nil
+}
+
+// backendURL returns the url of the next host of the target and its dialer
+func (p *Proxy) backendURL(t *target, c *gin.Context) (*ws.Dialer, string) {
+	i := int(t.next.Add(1)-1) % len(t.hosts)
+	host := t.hosts[i]
+	switch {
+	case strings.HasPrefix(host, "https://"):
+		host = "wss://" + strings.TrimPrefix(host, "https://")
+	case strings.HasPrefix(host, "http://"):
+		host = "ws://" + strings.TrimPrefix(host, "http://")
+	}
+
+	path := t.pattern
+	for _, param := range c.Params {
+		// the params of the backend url patterns are title cased by the config parser
+		key := strings.ToUpper(param.Key[:1]) + param.Key[1:]
+		path = strings.ReplaceAll(path, "{{."+key+"}}", url.PathEscape(param.Value))
+	}
+
+	q := url.Values{}
+	for _, k := range p.queryString {
+		if k == "*" {
+			q = c.Request.URL.Query()
+			break
+		}
+		if vs, ok := c.Request.URL.Query()[k]; ok {
+			q[k] = vs
+		}
+	}
+	if len(q) == 0 {
+		return t.dialers[i], host + path
+	}
+	return t.dialers[i], host + path + "?" + q.Encode()
+}
+
+func (p *Proxy) backendHeader(r *http.Request) http.Header {
+	h := http.Header{}
+	for _, k := range p.headers {
+		if k == "*" {
+			for name, vs := range r.Header {
+				h[name] = vs
+			}
+			break
+		}
+		if vs := r.Header.Values(k); len(vs) > 0 {
+			h[http.CanonicalHeaderKey(k)] = vs
+		}
+	}
+	for name := range handshakeHeaders {
+		h.Del(name)
+	}
+	if sp := ws.Subprotocols(r); len(sp) > 0 {
+		h["Sec-Websocket-Protocol"] = []string{strings.Join(sp, ", ")}
+	}
+	return h
+}
+
+func checkOrigin(allowed []string) func(*http.Request) bool {
+	if len(allowed) == 0 {
+		return sameOrigin
+	}
+	origins := make(map[string]struct{}, len(allowed))
+	for _, o := range allowed {
+		if o == "*" {
+			return func(*http.Request) bool { return true }
+		}
+		origins[strings.ToLower(o)] = struct{}{}
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		_, ok := origins[strings.ToLower(origin)]
+		return ok
+	}
+}
+
+// sameOrigin accepts the requests without origin or with the same host, like the default check of
+// the upgrader
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// session proxies the messages of a client connection
+type session struct {
+	p        *Proxy
+	client   *ws.Conn
+	backends []*ws.Conn
+	limiter  *rate.Limiter
+	idle     *time.Timer
+	clientMu sync.Mutex
+	once     sync.Once
+}
+
+func newSession(p *Proxy, client *ws.Conn, backends []*ws.Conn) *session {
+	s := &session{p: p, client: client, backends: backends}
+	if p.cfg.MessageRate > 0 {
+		s.limiter = rate.NewLimiter(rate.Limit(p.cfg.MessageRate), p.cfg.MessageBurst)
+	}
+	client.SetReadLimit(p.cfg.MaxMessageSize)
+	for _, b := range backends {
+		b.SetReadLimit(p.cfg.MaxMessageSize)
+	}
+	return s
+}
+
+func (s *session) run() {
+	s.idle = time.AfterFunc(s.p.cfg.idleTimeout, func() {
+		s.close(ws.CloseGoingAway, "idle timeout")
+	})
+	defer s.idle.Stop()
+
+	var wg sync.WaitGroup
+	wg.Add(len(s.backends) + 1)
+	go func() {
+		defer wg.Done()
+		s.upstream()
+	}()
+	for _, b := range s.backends {
+		go func(b *ws.Conn) {
+			defer wg.Done()
+			s.downstream(b)
+		}(b)
+	}
+	wg.Wait()
+}
+
+// upstream sends the messages of the client to all the backends
+func (s *session) upstream() {
+	for {
+		mt, msg, err := s.client.ReadMessage()
+		if err != nil {
+			s.closeOnError(err)
+			return
+		}
+		if s.limiter != nil && !s.limiter.Allow() {
+			s.close(ws.ClosePolicyViolation, "message rate exceeded")
+			return
+		}
+		s.idle.Reset(s.p.cfg.idleTimeout)
+		s.p.upstream.Inc(1)
+		for _, b := range s.backends {
+			if err := b.WriteMessage(mt, msg); err != nil {
+				s.closeOnError(err)
+				return
+			}
+		}
+	}
+}
+
+// downstream sends the messages of a backend to the client
+func (s *session) downstream(b *ws.Conn) {
+	for {
+		mt, msg, err := b.ReadMessage()
+		if err != nil {
+			s.closeOnError(err)
+			return
+		}
+		s.idle.Reset(s.p.cfg.idleTimeout)
+		s.p.downstream.Inc(1)
+		s.clientMu.Lock()
+		err = s.client.WriteMessage(mt, msg)
+		s.clientMu.Unlock()
+		if err != nil {
+			s.closeOnError(err)
+			return
+		}
+	}
+}
+
+// closeOnError closes all the connections, forwarding the close code received from the peer
+func (s *session) closeOnError(err error) {
+	var ce *ws.CloseError
+	switch {
+	case errors.As(err, &ce) && ce.Code != ws.CloseNoStatusReceived && ce.Code != ws.CloseAbnormalClosure:
+		s.close(ce.Code, ce.Text)
+	case errors.Is(err, ws.ErrReadLimit):
+		s.close(ws.CloseMessageTooBig, "message too big")
+	default:
+		s.close(ws.CloseGoingAway, "")
+	}
+}
+
+func (s *session) close(code int, text string) {
+	s.once.Do(func() {
+		msg := ws.FormatCloseMessage(code, text)
+		deadline := time.Now().Add(closeTimeout)
+		for _, conn := range append([]*ws.Conn{s.client}, s.backends...) {
+			conn.WriteControl(ws.CloseMessage, msg, deadline)
+			conn.Close()
+		}
+		s.p.l.Debug(s.p.logPrefix, fmt.Sprintf("Connection closed (%d) %s", code, text))
+	})
+}
@@ -0,0 +1,237 @@
+package websocket
+
+import (
+	"context"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ws "github.com/gorilla/websocket"
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/logging"
+	"github.com/luraproject/lura/v2/proxy"
+	gometrics "github.com/rcrowley/go-metrics"
+
+	"github.com/krakend/krakend-ce/v2/httpclient"
+)
+
+func TestHandlerFactory(t *testing.T) {
+	backend := echoServer(t, "backend")
+	defer backend.Close()
+
+	registry := gometrics.NewRegistry()
+	gw := gatewayWithRegistry(t, registry, map[string]interface{}{"max_message_size": 64}, backend.URL)
+	defer gw.Close()
+
+	header := http.Header{"X-Token": {"secret"}, "X-Other": {"ignored"}}
+	dialer := ws.Dialer{Subprotocols: []string{"chat"}}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(gw.URL, "http")+"/rooms/lobby?lang=en&x=1", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if resp.Header.Get("Sec-Websocket-Protocol") != "chat" {
+		t.Errorf("unexpected subprotocol: %s", resp.Header.Get("Sec-Websocket-Protocol"))
+	}
+
+	if err := conn.WriteMessage(ws.TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg) != "backend /chat/lobby?lang=en secret  hello" {
+		t.Errorf("unexpected message: %q", string(msg))
+	}
+	for name, want := range map[string]int64{"connections": 1, "messages.upstream": 1, "messages.downstream": 1} {
+		if c := gometrics.GetOrRegisterCounter("websocket./rooms/:room."+name, registry); c.Count() != want {
+			t.Errorf("unexpected value of %s: %d", name, c.Count())
+		}
+	}
+
+	conn.WriteMessage(ws.TextMessage, []byte(strings.Repeat("a", 128)))
+	_, _, err = conn.ReadMessage()
+	if !ws.IsCloseError(err, ws.CloseMessageTooBig) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestHandlerFactory_fanIn(t *testing.T) {
+	b1, b2 := echoServer(t, "b1"), echoServer(t, "b2")
+	defer b1.Close()
+	defer b2.Close()
+
+	gw := gateway(t, map[string]interface{}{"fan_in": true, "message_rate": 1, "message_burst": 1}, b1.URL, b2.URL)
+	defer gw.Close()
+
+	conn, _, err := ws.DefaultDialer.Dial("ws"+strings.TrimPrefix(gw.URL, "http")+"/rooms/lobby", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.WriteMessage(ws.TextMessage, []byte("hi"))
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[strings.Fields(string(msg))[0]] = true
+	}
+	if !got["b1"] || !got["b2"] {
+		t.Errorf("unexpected messages: %v", got)
+	}
+
+	conn.WriteMessage(ws.TextMessage, []byte("hi"))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := conn.ReadMessage(); !ws.IsCloseError(err, ws.ClosePolicyViolation) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestHandlerFactory_rejected(t *testing.T) {
+	gw := gateway(t, map[string]interface{}{}, "http://127.0.0.1:1")
+	defer gw.Close()
+
+	resp, err := http.Get(gw.URL + "/rooms/lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	header := http.Header{"Origin": {"http://evil.example.com"}}
+	if _, resp, err := ws.DefaultDialer.Dial("ws"+strings.TrimPrefix(gw.URL, "http")+"/rooms/lobby", header); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("unexpected response: %v", err)
+	}
+
+	if _, resp, err := ws.DefaultDialer.Dial("ws"+strings.TrimPrefix(gw.URL, "http")+"/rooms/lobby", nil); err == nil || resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("unexpected response: %v", err)
+	}
+}
+
+func gateway(t *testing.T, wCfg map[string]interface{}, hosts ...string) *httptest.Server {
+	return gatewayWithRegistry(t, nil, wCfg, hosts...)
+}
+
+func gatewayWithRegistry(t *testing.T, registry gometrics.Registry, wCfg map[string]interface{}, hosts ...string) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.EndpointConfig{
+		Endpoint:      "/rooms/:room",
+		Method:        "GET",
+		QueryString:   []string{"lang"},
+		HeadersToPass: []string{"X-Token"},
+		ExtraConfig:   config.ExtraConfig{Namespace: wCfg},
+	}
+	for _, h := range hosts {
+		cfg.Backend = append(cfg.Backend, &config.Backend{Host: []string{h}, URLPattern: "/chat/{{.Room}}"})
+	}
+	hf := HandlerFactory(context.Background(), func(*config.EndpointConfig, proxy.Proxy) gin.HandlerFunc {
+		t.Error("unexpected call to the next handler factory")
+		return nil
+	}, logging.NoOp, registry)
+
+	engine := gin.New()
+	engine.GET(cfg.Endpoint, hf(cfg, nil))
+	return httptest.NewServer(engine)
+}
+
+func TestHandlerFactory_backendTransport(t *testing.T) {
+	tlsBackend := httptest.NewTLSServer(echoHandler(t, "tls"))
+	defer tlsBackend.Close()
+	dir := t.TempDir()
+	caPath := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsBackend.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(dir, "backend.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip("unix sockets are not available:", err)
+	}
+	unixBackend := &http.Server{Handler: echoHandler(t, "unix")}
+	go unixBackend.Serve(ln)
+	defer unixBackend.Close()
+
+	gin.SetMode(gin.TestMode)
+	cfg := &config.EndpointConfig{
+		Endpoint:    "/rooms/:room",
+		Method:      "GET",
+		ExtraConfig: config.ExtraConfig{Namespace: map[string]interface{}{"fan_in": true}},
+		Backend: []*config.Backend{
+			{
+				Host:        []string{tlsBackend.URL},
+				URLPattern:  "/chat/{{.Room}}",
+				ExtraConfig: config.ExtraConfig{httpclient.Namespace: map[string]interface{}{"client_tls": map[string]interface{}{"ca_certs": []string{caPath}}}},
+			},
+			{Host: []string{httpclient.UnixScheme + socket}, URLPattern: "/chat/{{.Room}}"},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hf := HandlerFactory(ctx, func(*config.EndpointConfig, proxy.Proxy) gin.HandlerFunc {
+		t.Error("unexpected call to the next handler factory")
+		return nil
+	}, logging.NoOp, nil)
+	engine := gin.New()
+	engine.GET(cfg.Endpoint, hf(cfg, nil))
+	gw := httptest.NewServer(engine)
+	defer gw.Close()
+
+	conn, _, err := ws.DefaultDialer.Dial("ws"+strings.TrimPrefix(gw.URL, "http")+"/rooms/lobby", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.WriteMessage(ws.TextMessage, []byte("hi"))
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[strings.Join(strings.Fields(string(msg))[:2], " ")] = true
+	}
+	if !got["tls /chat/lobby"] || !got["unix /chat/lobby"] {
+		t.Errorf("unexpected messages: %v", got)
+	}
+}
+
+func echoServer(t *testing.T, name string) *httptest.Server {
+	return httptest.NewServer(echoHandler(t, name))
+}
+
+func echoHandler(t *testing.T, name string) http.Handler {
+	upgrader := ws.Upgrader{Subprotocols: []string{"chat"}}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		prefix := name + " " + r.URL.RequestURI() + " " + r.Header.Get("X-Token") + " " + r.Header.Get("X-Other") + " "
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(mt, append([]byte(prefix), msg...)); err != nil {
+				return
+			}
+		}
+	})
+}